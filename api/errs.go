@@ -101,3 +101,363 @@ func NewUnusedFunction(name string, points ...int) CompilerError {
 		CodeContext: points,
 	}
 }
+
+func NewUnknownDirective(name string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   10,
+		Title:       fmt.Sprintf("No such directive '%s'", name),
+		Desc:        "",
+		Fatal:       true,
+		CodeContext: points,
+	}
+}
+
+func NewDirectiveTargetError(name string, target string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   11,
+		Title:       fmt.Sprintf("Directive '%s' cannot be applied to a %s", name, target),
+		Desc:        "",
+		Fatal:       true,
+		CodeContext: points,
+	}
+}
+
+func NewNonConstantGlobal(name string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   12,
+		Title:       fmt.Sprintf("Global '%s' must be initialised with a constant value", name),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewConversionError(from, to string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   13,
+		Title:       fmt.Sprintf("Cannot implicitly convert '%s' to '%s'", from, to),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewInvalidCastError(from, to string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   14,
+		Title:       fmt.Sprintf("Cannot cast '%s' to '%s'", from, to),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewUnknownMethod(name string, parent string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   15,
+		Title:       fmt.Sprintf("No method '%s' on type '%s'", name, parent),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewReceiverMutabilityError(name string, parent string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   16,
+		Title:       fmt.Sprintf("Method '%s.%s' takes 'mut *self' but is called on a constant value", parent, name),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewArgumentCountError(name string, expected int, given int, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   17,
+		Title:       fmt.Sprintf("'%s' expects %d argument(s) but was given %d", name, expected, given),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewUnclaimedMethod(name string, parent string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   18,
+		Title:       fmt.Sprintf("Method '%s' is implemented for '%s' which is not a structure", name, parent),
+		Desc:        "",
+		Fatal:       true,
+		CodeContext: points,
+	}
+}
+
+func NewNotIndexable(typ string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   19,
+		Title:       fmt.Sprintf("Cannot index or slice a value of type '%s'", typ),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewUnboundedSlice(typ string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   20,
+		Title:       fmt.Sprintf("Slice of '%s' must have an upper bound", typ),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewNoLength(typ string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   21,
+		Title:       fmt.Sprintf("Cannot take the length of a value of type '%s'", typ),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewInvalidOperator(op string, typ string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   22,
+		Title:       fmt.Sprintf("Operator '%s' is not defined for '%s'", op, typ),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewJumpOutsideLoop(kind string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   23,
+		Title:       fmt.Sprintf("'%s' used outside of a loop", kind),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewUnknownLoopLabel(name string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   24,
+		Title:       fmt.Sprintf("No enclosing loop with the label '%s'", name),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewShadowedLoopLabel(name string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   25,
+		Title:       fmt.Sprintf("Loop label '%s' is already used by an enclosing loop", name),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewReturnInDefer(points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   26,
+		Title:       "Cannot return from inside a defer",
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewUndefinedLabel(name string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   27,
+		Title:       fmt.Sprintf("Jump to undefined label '%s'", name),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewDuplicateLabel(name string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   28,
+		Title:       fmt.Sprintf("Label '%s' is defined more than once", name),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewLabelInOtherFunction(name string, fn string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   29,
+		Title:       fmt.Sprintf("Label '%s' is defined in '%s', not this function", name, fn),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewJumpSkipsLocal(label string, local string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   30,
+		Title:       fmt.Sprintf("Jump to '%s' skips the initialisation of '%s'", label, local),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewJumpIntoDefers(label string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   31,
+		Title:       fmt.Sprintf("Jump to '%s' enters a scope with pending defers", label),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewMissingField(field string, parent string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   32,
+		Title:       fmt.Sprintf("Missing field '%s' in initializer for '%s'", field, parent),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewDuplicateField(field string, parent string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   33,
+		Title:       fmt.Sprintf("Field '%s' is initialized more than once for '%s'", field, parent),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewUnknownField(field string, parent string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   34,
+		Title:       fmt.Sprintf("No field '%s' in '%s'", field, parent),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewFieldCountError(parent string, expected int, given int, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   35,
+		Title:       fmt.Sprintf("'%s' has %d field(s) but was given %d", parent, expected, given),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewNonConstantDefault(name string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   36,
+		Title:       fmt.Sprintf("Default value of field '%s' must be constant", name),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewMalformedIR(what string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   37,
+		Title:       fmt.Sprintf("Malformed IR: %s", what),
+		Desc:        "",
+		Fatal:       true,
+		CodeContext: points,
+	}
+}
+
+func NewCannotInferType(name string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   38,
+		Title:       fmt.Sprintf("Couldn't infer the type of '%s'", name),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewTypeMismatch(expected, given string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   39,
+		Title:       fmt.Sprintf("Expected a value of type '%s' but found '%s'", expected, given),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewMissingReturnValue(name string, typ string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   40,
+		Title:       fmt.Sprintf("Function '%s' must return a value of type '%s'", name, typ),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewUnexpectedReturnValue(name string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   41,
+		Title:       fmt.Sprintf("Function '%s' doesn't return a value", name),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewNotCallable(name string, typ string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   42,
+		Title:       fmt.Sprintf("Can't call '%s' of type '%s'", name, typ),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewConstantOverflow(value string, typ string, points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   43,
+		Title:       fmt.Sprintf("Constant %s overflows '%s'", value, typ),
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewDivisionByZero(points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   44,
+		Title:       "Division by zero",
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
+
+func NewUnreachableCode(points ...int) CompilerError {
+	return CompilerError{
+		ErrorCode:   45,
+		Title:       "Unreachable code",
+		Desc:        "",
+		Fatal:       false,
+		CodeContext: points,
+	}
+}
@@ -2,7 +2,9 @@ package back
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/krug-lang/caasper/front"
 
@@ -11,6 +13,8 @@ import (
 )
 
 type emitter struct {
+	header string
+	slices string
 	decl   string
 	source string
 	target *string
@@ -26,12 +30,84 @@ type emitter struct {
 
 	// whether or not the output is minified.
 	minify bool
+
+	// env is the types of the locals in scope,
+	// this is used to resolve method calls and
+	// field accesses through pointers.
+	env *ir.Env
+
+	// types is the type of each value in the module,
+	// worked out by type inference. values that are made
+	// while generating code are typed with env instead.
+	types *ir.TypeMap
+
+	// mod is the module being generated, used to tell
+	// calls to krug functions apart from calls into C.
+	mod *ir.Module
+
+	// sliceTypes is the set of slice structs that
+	// have been written to the slices section.
+	sliceTypes map[string]bool
+
+	// whether or not indexes and slices of arrays
+	// and slices are checked at runtime.
+	boundsCheck bool
+
+	// loops are the loops being emitted, innermost last.
+	// loopCount is used to give each loop unique labels.
+	loops     []*loopFrame
+	loopCount int
+
+	// scopes are the blocks being emitted, innermost
+	// last, fn is the function that they belong to.
+	scopes []*deferScope
+	fn     *ir.Function
+
+	// labels are the blocks enclosing each label in
+	// the function, outermost first, see buildJump.
+	labels map[string][]*ir.Block
+}
+
+// deferScope is a block that is being emitted, pos is
+// the index of the instruction in the block being emitted.
+// only the defers that come before pos have been reached.
+type deferScope struct {
+	block *ir.Block
+	pos   int
+}
+
+// loopFrame is a loop that is being emitted. a labelled
+// break or next out of a nested loop can't be written with
+// break or continue, so it jumps to a label at the end of
+// the loop or the end of its body.
+type loopFrame struct {
+	label  *front.Token
+	id     int
+	breaks bool
+	nexts  bool
+
+	// depth is the number of scopes outside
+	// of the loop, see emitter.unwind
+	depth int
+}
+
+// name returns the C label for the given kind of jump.
+func (l *loopFrame) name(kind string) string {
+	return fmt.Sprintf("krug_%s_%s_%d", l.label.Value, kind, l.id)
 }
 
 func (e *emitter) error(err api.CompilerError) {
 	e.errors = append(e.errors, err)
 }
 
+// typeOf returns the type of the given value.
+func (e *emitter) typeOf(v *ir.Value) *ir.Type {
+	if typ := e.types.TypeOf(v); typ != nil {
+		return typ
+	}
+	return e.env.TypeOf(v)
+}
+
 func (e *emitter) retarget(to *string) {
 	e.target = to
 }
@@ -86,6 +162,10 @@ func (e *emitter) writeFloat(t *ir.FloatingType) string {
 }
 
 func (e *emitter) writePointer(t *ir.PointerType) string {
+	// pointers to arrays are written as T (*)[N]
+	if t.Base.Kind == ir.ArrayKind {
+		return e.declarator(&ir.Type{Kind: ir.PointerKind, Pointer: t}, "")
+	}
 	return fmt.Sprintf("%s*", e.writeType(t.Base))
 }
 
@@ -101,9 +181,51 @@ func (e *emitter) emitTupleType(tup *ir.TupleType) string {
 	return fmt.Sprintf("struct { %s }", structTypes)
 }
 
-// HM
 func (e *emitter) writeArray(typ *ir.ArrayType) string {
-	return fmt.Sprintf("%s", e.writeType(typ.Base))
+	return e.declarator(&ir.Type{Kind: ir.ArrayKind, ArrayType: typ}, "")
+}
+
+// mangleType turns the given C type into
+// something that can be used in an identifier.
+func mangleType(typ string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, typ)
+}
+
+// writeSlice returns the name of the struct that slices
+// of the given type compile to, the struct is declared
+// the first time it's used.
+func (e *emitter) writeSlice(typ *ir.SliceType) string {
+	name := "krug_slice_" + mangleType(e.writeType(typ.Base))
+	if e.sliceTypes[name] {
+		return name
+	}
+	e.sliceTypes[name] = true
+
+	data := e.declarator(&ir.Type{Kind: ir.PointerKind, Pointer: ir.NewPointerType(typ.Base)}, "data")
+	e.slices += fmt.Sprintf("typedef struct { %s; uint64_t len; } %s;\n", data, name)
+	return name
+}
+
+// declarator writes the declaration of name with the given type,
+// array sizes go after the name because C, e.g. int32_t name[4][3]
+// and a pointer to an array is written as int32_t (*name)[4].
+// an empty name gives the abstract type, e.g. for casts.
+func (e *emitter) declarator(typ *ir.Type, name string) string {
+	switch typ.Kind {
+	case ir.ArrayKind:
+		size := e.buildExpr(typ.ArrayType.Size)
+		return e.declarator(typ.ArrayType.Base, fmt.Sprintf("%s[%s]", name, size))
+	case ir.PointerKind:
+		if base := typ.Pointer.Base; base.Kind == ir.ArrayKind {
+			return e.declarator(base, fmt.Sprintf("(*%s)", name))
+		}
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %s", e.writeType(typ), name))
 }
 
 func (e *emitter) writeType(typ *ir.Type) string {
@@ -121,6 +243,10 @@ func (e *emitter) writeType(typ *ir.Type) string {
 		return e.writeFloat(typ.FloatingType)
 	case ir.VoidKind:
 		return "void"
+	case ir.StringKind:
+		return "krug_str"
+	case ir.BoolKind:
+		return "bool"
 
 	case ir.TupleKind:
 		return e.emitTupleType(typ.Tuple)
@@ -130,6 +256,8 @@ func (e *emitter) writeType(typ *ir.Type) string {
 
 	case ir.ArrayKind:
 		return e.writeArray(typ.ArrayType)
+	case ir.SliceKind:
+		return e.writeSlice(typ.Slice)
 	case ir.PointerKind:
 		return e.writePointer(typ.Pointer)
 
@@ -141,30 +269,7 @@ func (e *emitter) writeType(typ *ir.Type) string {
 }
 
 func (e *emitter) emitLocal(loc *ir.Local) string {
-	// FIXME this is lazy i just copied
-	// and pasted this and rewrote old args as variables lol
-	mutable := loc.Mutable
-	t := loc.Type
-	name := loc.Name.Value
-
-	genType := e.writeType(t)
-
-	// we have to move the array to the end of the
-	// typed name because C
-	var result string
-	if t.Kind == ir.ArrayKind {
-		val := e.buildExpr(t.ArrayType.Size)
-		result = fmt.Sprintf("%s %s[%s]", genType, name, val)
-	} else {
-		result = fmt.Sprintf("%s %s", genType, name)
-	}
-
-	// append the mutability modifier
-	var modifier string
-	if !mutable {
-		modifier = "const "
-	}
-	return fmt.Sprintf("%s%s", modifier, result)
+	return e.emitTypedName(loc.Mutable, loc.Type, loc.Name.Value)
 }
 
 func (e *emitter) emitTypedName(mutable bool, t *ir.Type, name string) string {
@@ -173,17 +278,7 @@ func (e *emitter) emitTypedName(mutable bool, t *ir.Type, name string) string {
 		return "/*<nil-type>*/"
 	}
 
-	genType := e.writeType(t)
-
-	// we have to move the array to the end of the
-	// typed name because C
-	var result string
-	if t.Kind == ir.ArrayKind {
-		val := e.buildExpr(t.ArrayType.Size)
-		result = fmt.Sprintf("%s %s[%s]", genType, name, val)
-	} else {
-		result = fmt.Sprintf("%s %s", genType, name)
-	}
+	result := e.declarator(t, name)
 
 	// append the mutability modifier
 	var modifier string
@@ -209,6 +304,24 @@ func (e *emitter) buildAllocBuiltin(b *ir.Builtin) string {
 	return fmt.Sprintf("malloc(sizeof(%s) * %s)", val, num)
 }
 
+func (e *emitter) buildLen(b *ir.Builtin) string {
+	iden := b.Iden.Name.Value
+	typ := e.env.Lookup(iden)
+	if typ == nil {
+		e.error(api.NewUnimplementedError("compilation", "len! of a value with an unknown type"))
+		return "/*<nil-len>*/"
+	}
+
+	switch typ.Kind {
+	case ir.ArrayKind:
+		return fmt.Sprintf("((uint64_t)(%s))", e.buildExpr(typ.ArrayType.Size))
+	case ir.SliceKind, ir.StringKind:
+		return fmt.Sprintf("%s.len", iden)
+	}
+	e.error(api.NewNoLength(typ.String(), b.Iden.Name.Span...))
+	return "/*<nil-len>*/"
+}
+
 func (e *emitter) buildBuiltin(b *ir.Builtin) string {
 	iden := b.Iden.Name.Value
 	switch b.Name {
@@ -216,6 +329,8 @@ func (e *emitter) buildBuiltin(b *ir.Builtin) string {
 		return fmt.Sprintf("sizeof(%s)", iden)
 	case "alloc":
 		return e.buildAllocBuiltin(b)
+	case "len":
+		return e.buildLen(b)
 	case "free":
 		return fmt.Sprintf("free(%s)", iden)
 	case "move":
@@ -228,19 +343,88 @@ func (e *emitter) buildBuiltin(b *ir.Builtin) string {
 	}
 }
 
+// writeInitList writes the values of the initializer as a
+// C brace list, named fields use designated initializers.
+func (e *emitter) writeInitList(i *ir.Init) string {
+	vals := make([]string, len(i.Values))
+	for idx, val := range i.Values {
+		vals[idx] = e.buildInitValue(val)
+		if idx < len(i.Names) {
+			vals[idx] = fmt.Sprintf(".%s = %s", i.Names[idx].Value, vals[idx])
+		}
+	}
+	return fmt.Sprintf("{ %s }", strings.Join(vals, ", "))
+}
+
+// buildInitValue writes a value inside of a brace list, nested
+// initializers and strings are written as brace lists as well
+// so that the list stays constant for globals.
+func (e *emitter) buildInitValue(v *ir.Value) string {
+	switch v.Kind {
+	case ir.InitValue:
+		return e.writeInitList(v.Init)
+	case ir.StringValueValue:
+		lit := cString(v.StringValue.Value)
+		return fmt.Sprintf("{ (const uint8_t*) %s, sizeof(%s) - 1 }", lit, lit)
+	}
+	return e.buildExpr(v)
+}
+
+// writeInitExpr writes an initializer used as a value as a
+// compound literal. tuples are anonymous structs in C so
+// they can only be initialised when they are declared.
 func (e *emitter) writeInitExpr(i *ir.Init) string {
 	switch i.Kind {
 	case front.InitStructure:
-		return ""
-	case front.InitTuple:
-		return ""
-	case front.InitArray:
-		return ""
+		return fmt.Sprintf("((%s)%s)", i.LHand.Name.Value, e.writeInitList(i))
+	case front.InitTuple, front.InitArray:
+		e.error(api.NewUnimplementedError("compilation", "initializer outside of a declaration"))
+		return "/*<nil-init-expr>*/"
 	}
 	e.error(api.NewUnimplementedError("compilation", "unimplemented int-expr"))
 	return "/*<nil-int-expr>*/"
 }
 
+// cString writes the given krug string literal as a C
+// string literal, raw strings like `foo` become "foo" with
+// any quotes, backslashes and newlines escaped.
+func cString(lit string) string {
+	contained := lit[1 : len(lit)-1]
+	if lit[0] == '`' {
+		contained = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(contained)
+	}
+	return fmt.Sprintf(`"%s"`, contained)
+}
+
+func (e *emitter) isString(v *ir.Value) bool {
+	typ := e.typeOf(v)
+	return typ != nil && typ.Kind == ir.StringKind
+}
+
+// buildStringBinary writes a concatenation or comparison
+// of two strings as a call into the runtime.
+func (e *emitter) buildStringBinary(lh string, op string, rh string) string {
+	if op == "+" {
+		return fmt.Sprintf("krug_str_concat(%s, %s)", lh, rh)
+	}
+	return fmt.Sprintf("(krug_str_cmp(%s, %s) %s 0)", lh, rh, op)
+}
+
+func (e *emitter) buildCast(c *ir.Cast) string {
+	val := e.buildExpr(c.Val)
+
+	// strings are always null terminated, so the
+	// data can be passed to C as is.
+	from := e.typeOf(c.Val)
+	switch {
+	case from != nil && from.Kind == ir.StringKind && c.Type.Kind == ir.PointerKind:
+		return fmt.Sprintf("((uint8_t*)(%s).data)", val)
+	case from != nil && from.Kind == ir.PointerKind && c.Type.Kind == ir.StringKind:
+		return fmt.Sprintf("krug_str_from_cstr(%s)", val)
+	}
+	return fmt.Sprintf("((%s)(%s))", e.writeType(c.Type), val)
+}
+
 func (e *emitter) buildUnary(u *ir.UnaryExpression) string {
 	value := e.buildExpr(u.Val)
 	op := func(op string) string {
@@ -253,19 +437,112 @@ func (e *emitter) buildUnary(u *ir.UnaryExpression) string {
 	return fmt.Sprintf("(%s(%s))", op, value)
 }
 
+// writeSpan writes the given span as the arguments
+// of a bounds check, -1 is used if there is no span.
+func writeSpan(span []int) string {
+	if len(span) < 2 {
+		return "-1, -1"
+	}
+	return fmt.Sprintf("%d, %d", span[0], span[1])
+}
+
+// length returns the number of elements in the given value
+// if it's an array or slice, or "" if it's not known.
+func (e *emitter) length(val string, typ *ir.Type) string {
+	switch typ.Kind {
+	case ir.ArrayKind:
+		return e.buildExpr(typ.ArrayType.Size)
+	case ir.SliceKind:
+		return val + ".len"
+	}
+	return ""
+}
+
 func (e *emitter) buildIndex(i *ir.Index) string {
 	lhand := e.buildExpr(i.Left)
 	sub := e.buildExpr(i.Sub)
+
+	typ := e.typeOf(i.Left)
+	if typ == nil {
+		return fmt.Sprintf("%s[%s]", lhand, sub)
+	}
+
+	if length := e.length(lhand, typ); e.boundsCheck && length != "" {
+		sub = fmt.Sprintf("krug_check_index(%s, %s, %s)", sub, length, writeSpan(i.Span))
+	}
+
+	if typ.Kind == ir.SliceKind {
+		return fmt.Sprintf("%s.data[%s]", lhand, sub)
+	}
 	return fmt.Sprintf("%s[%s]", lhand, sub)
 }
 
+// buildSlice writes a slice as a statement expression that
+// evaluates the sliced value and its bounds once into temporaries,
+// the bounds are checked before the slice struct is built
+// when bounds checks are enabled.
+func (e *emitter) buildSlice(s *ir.Slice) string {
+	typ := e.typeOf(s.Left)
+	base := ir.ElementType(typ)
+	if base == nil {
+		e.error(api.NewUnimplementedError("compilation", "slice of a value with no elements"))
+		return "/*<nil-slice>*/"
+	}
+
+	var temps []string
+
+	// a slice is copied so that the data and length are
+	// read from the same value, arrays decay to a pointer.
+	left := e.buildExpr(s.Left)
+	data := "krug_slice_data"
+	ptr := &ir.Type{Kind: ir.PointerKind, Pointer: ir.NewPointerType(base)}
+	length := e.length(left, typ)
+	if typ.Kind == ir.SliceKind {
+		temps = append(temps, fmt.Sprintf("%s = %s;", e.declarator(typ, "krug_slice_src"), left))
+		temps = append(temps, fmt.Sprintf("%s = krug_slice_src.data;", e.declarator(ptr, data)))
+		length = "krug_slice_src.len"
+	} else {
+		temps = append(temps, fmt.Sprintf("%s = %s;", e.declarator(ptr, data), left))
+	}
+
+	low := "0"
+	if s.Low != nil {
+		low = e.buildExpr(s.Low)
+	}
+	temps = append(temps, fmt.Sprintf("uint64_t krug_slice_low = %s;", low))
+
+	high := length
+	if s.High != nil {
+		high = e.buildExpr(s.High)
+	}
+	if high == "" {
+		e.error(api.NewUnboundedSlice(typ.String(), s.Span...))
+		return "/*<nil-slice>*/"
+	}
+	temps = append(temps, fmt.Sprintf("uint64_t krug_slice_high = %s;", high))
+
+	if e.boundsCheck && length != "" {
+		temps = append(temps, fmt.Sprintf("krug_check_slice(krug_slice_low, krug_slice_high, %s, %s);", length, writeSpan(s.Span)))
+	}
+
+	name := e.writeSlice(ir.NewSliceType(base))
+	return fmt.Sprintf("({ %s (%s){ %s + krug_slice_low, krug_slice_high - krug_slice_low }; })",
+		strings.Join(temps, " "), name, data)
+}
+
 func (e *emitter) writePath(p *ir.Path) string {
 	var res string
-	for idx, p := range p.Values {
+	for idx, val := range p.Values {
 		if idx != 0 {
-			res += "."
+			// fields are accessed through pointers with ->
+			sep := "."
+			prefix := &ir.Value{Kind: ir.PathValue, Path: ir.NewPath(p.Values[:idx])}
+			if typ := e.typeOf(prefix); typ != nil && typ.Kind == ir.PointerKind {
+				sep = "->"
+			}
+			res += sep
 		}
-		res += e.buildExpr(p)
+		res += e.buildExpr(val)
 	}
 	return res
 }
@@ -276,25 +553,36 @@ func (e *emitter) buildExpr(l *ir.Value) string {
 		val := l.IntegerValue
 		return val.RawValue.String()
 
+	case ir.BooleanValueValue:
+		if l.BooleanValue.Value {
+			return "true"
+		}
+		return "false"
+
 	case ir.FloatingValueValue:
-		val := l.FloatingValue
-		return fmt.Sprintf("%f", val.Value)
+		// folded constants are written with as many
+		// digits as it takes to read them back exactly.
+		res := strconv.FormatFloat(l.FloatingValue.Value, 'g', -1, 64)
+		if !strings.ContainsAny(res, ".e") {
+			res += ".0"
+		}
+		return res
 
 	case ir.CharacterValueValue:
 		val := l.CharacterValue
 		return fmt.Sprintf(`%s`, val.Value)
 
 	case ir.StringValueValue:
-		val := l.StringValue
-		containedString := val.Value[1 : len(val.Value)-1]
-		// if it's a raw string for example, we convert
-		// it into a normal quote so `foo` becomes "foo"
-		return fmt.Sprintf(`"%s"`, containedString)
+		lit := cString(l.StringValue.Value)
+		return fmt.Sprintf("((krug_str){ (const uint8_t*) %s, sizeof(%s) - 1 })", lit, lit)
 
 	case ir.BinaryExpressionValue:
 		val := l.BinaryExpression
 		lh := e.buildExpr(val.LHand)
 		rh := e.buildExpr(val.RHand)
+		if e.isString(val.LHand) {
+			return e.buildStringBinary(lh, val.Op, rh)
+		}
 		return fmt.Sprintf("(%s%s%s)", lh, val.Op, rh)
 
 	case ir.GroupingValue:
@@ -316,23 +604,14 @@ func (e *emitter) buildExpr(l *ir.Value) string {
 	case ir.IndexValue:
 		return e.buildIndex(l.Index)
 
+	case ir.SliceValue:
+		return e.buildSlice(l.Slice)
+
 	case ir.AssignValue:
-		val := l.Assign
-		lh := e.buildExpr(val.LHand)
-		rh := e.buildExpr(val.RHand)
-		return fmt.Sprintf("%s %s %s", lh, val.Op, rh)
+		return e.writeAssign(l.Assign)
 
 	case ir.CallValue:
-		val := l.Call
-		lh := e.buildExpr(val.Left)
-		var argsList string
-		for idx, arg := range val.Params {
-			if idx != 0 {
-				argsList += ","
-			}
-			argsList += e.buildExpr(arg)
-		}
-		return fmt.Sprintf("%s(%s)", lh, argsList)
+		return e.buildCall(l.Call)
 
 	case ir.InitValue:
 		return e.writeInitExpr(l.Init)
@@ -340,6 +619,9 @@ func (e *emitter) buildExpr(l *ir.Value) string {
 	case ir.PathValue:
 		return e.writePath(l.Path)
 
+	case ir.CastValue:
+		return e.buildCast(l.Cast)
+
 	default:
 		e.error(api.NewUnimplementedError("compilation", "unimplemented expr"))
 		return "/*<nil-expr>*/"
@@ -353,54 +635,127 @@ func (e *emitter) removePointer(ptr *ir.Type) *ir.Type {
 	return ptr
 }
 
-func (e *emitter) buildInitializerFor(l *ir.Local, init *ir.Init) {
-	localName := l.Name.Value
-
-	switch init.Kind {
-	case front.InitTuple:
-		for idx, val := range init.Values {
-			genVal := e.buildExpr(val)
-			e.writetln(e.indentLevel, "%s._%d = %s;", localName, idx, genVal)
-		}
-
-	case front.InitStructure:
-		break
-
-	case front.InitArray:
-		// this is annoying but it works for now.
-		for idx, expr := range init.Values {
-			val := e.buildExpr(expr)
-			e.writetln(e.indentLevel, "%s[%d] = %s;", localName, idx, val)
-		}
-	}
-}
-
 func (e *emitter) buildLocal(l *ir.Local) {
 	localValue := ";"
 	if l.Val != nil {
-		if l.Val.Kind != ir.InitValue {
-			// initializer is emitted AFTER the variable.
-			localValue = fmt.Sprintf(" = %s;", e.buildExpr(l.Val))
+		// initializers are written as a brace list so
+		// that tuples and arrays can be initialised.
+		if l.Val.Kind == ir.InitValue {
+			localValue = fmt.Sprintf(" = %s;", e.writeInitList(l.Val.Init))
 		} else {
-			defer e.buildInitializerFor(l, l.Val.Init)
+			localValue = fmt.Sprintf(" = %s;", e.buildExpr(l.Val))
 		}
 	}
 
 	typedName := e.emitTypedName(l.Mutable, l.Type, l.Name.Value)
 	e.writetln(e.indentLevel, "%s%s", typedName, localValue)
+
+	e.env.DeclareLocal(l)
 }
 
 func (e *emitter) buildRet(r *ir.Return) {
-	res := ";"
-	if r.Val != nil {
-		res = fmt.Sprintf(" %s;", e.buildExpr(r.Val))
+	if r.Val == nil || !e.hasDefers(0) {
+		e.unwind(0)
+
+		res := ";"
+		if r.Val != nil {
+			res = fmt.Sprintf(" %s;", e.buildExpr(r.Val))
+		}
+		e.writetln(e.indentLevel, "return%s", res)
+		return
+	}
+
+	// the value is worked out before any defers run.
+	e.writetln(e.indentLevel, "{")
+	e.indentLevel++
+	typedName := e.emitTypedName(true, e.fn.ReturnType, "krug_ret")
+	e.writetln(e.indentLevel, "%s = %s;", typedName, e.buildExpr(r.Val))
+	e.unwind(0)
+	e.writetln(e.indentLevel, "return krug_ret;")
+	e.indentLevel--
+	e.writetln(e.indentLevel, "}")
+}
+
+// buildLoopBody writes the body of a loop, labelled loops
+// have their body wrapped so that there is somewhere for
+// a next from a nested loop to jump to.
+func (e *emitter) buildLoopBody(label *front.Token, body *ir.Block) {
+	frame := &loopFrame{label: label, id: e.loopCount, depth: len(e.scopes)}
+	e.loopCount++
+
+	e.loops = append(e.loops, frame)
+	defer func() {
+		e.loops = e.loops[:len(e.loops)-1]
+	}()
+
+	if label == nil {
+		e.buildBlock(body)
+		return
+	}
+
+	e.writetln(e.indentLevel, "{")
+	e.indentLevel++
+	e.buildBlock(body)
+	if frame.nexts {
+		e.writetln(e.indentLevel-1, "%s:;", frame.name("next"))
+	}
+	e.indentLevel--
+	e.writetln(e.indentLevel, "}")
+
+	if frame.breaks {
+		e.writetln(e.indentLevel-1, "%s:;", frame.name("break"))
+	}
+}
+
+// buildLoopJump writes a break or next. a jump out of the
+// innermost loop is written as a break or continue, anything
+// else is a goto to the end of the labelled loop.
+func (e *emitter) buildLoopJump(kind string, label *front.Token) {
+	stmt := "break"
+	if kind == "next" {
+		stmt = "continue"
+	}
+
+	if len(e.loops) == 0 {
+		e.error(api.NewJumpOutsideLoop(kind))
+		return
+	}
+
+	target := e.loops[len(e.loops)-1]
+	if label != nil {
+		target = nil
+		for i := len(e.loops) - 1; i >= 0; i-- {
+			if l := e.loops[i].label; l != nil && l.Value == label.Value {
+				target = e.loops[i]
+				break
+			}
+		}
+	}
+
+	if target == nil {
+		e.error(api.NewUnknownLoopLabel(label.Value, label.Span...))
+		return
+	}
+
+	// the defers of the loop body and any scopes
+	// inside of it run before we leave them.
+	e.unwind(target.depth)
+
+	switch {
+	case target == e.loops[len(e.loops)-1]:
+		e.writetln(e.indentLevel, "%s;", stmt)
+	case kind == "next":
+		target.nexts = true
+		e.writetln(e.indentLevel, "goto %s;", target.name(kind))
+	default:
+		target.breaks = true
+		e.writetln(e.indentLevel, "goto %s;", target.name(kind))
 	}
-	e.writetln(e.indentLevel, "return%s", res)
 }
 
 func (e *emitter) buildLoop(l *ir.Loop) {
 	e.writetln(e.indentLevel, "for(;;)")
-	e.buildBlock(l.Body)
+	e.buildLoopBody(l.Label, l.Body)
 }
 
 func (e *emitter) buildWhileLoop(w *ir.WhileLoop) {
@@ -410,7 +765,7 @@ func (e *emitter) buildWhileLoop(w *ir.WhileLoop) {
 		post = e.buildExpr(w.Post)
 	}
 	e.writetln(e.indentLevel, "for(;%s;%s)", cond, post)
-	e.buildBlock(w.Body)
+	e.buildLoopBody(w.Label, w.Body)
 }
 
 func (e *emitter) buildIfStat(iff *ir.IfStatement) {
@@ -428,25 +783,95 @@ func (e *emitter) buildIfStat(iff *ir.IfStatement) {
 	}
 }
 
-func (e *emitter) buildAssign(a *ir.Assign) {
+func (e *emitter) writeAssign(a *ir.Assign) string {
 	lh := e.buildExpr(a.LHand)
-	op := a.Op
 	rh := e.buildExpr(a.RHand)
-	e.writetln(e.indentLevel, "%s %s %s;", lh, op, rh)
+	if a.Op == "+=" && e.isString(a.LHand) {
+		return fmt.Sprintf("%s = krug_str_concat(%s, %s)", lh, lh, rh)
+	}
+	return fmt.Sprintf("%s %s %s", lh, a.Op, rh)
 }
 
-func (e *emitter) buildCall(c *ir.Call) {
-	var argList string
-	for idx, p := range c.Params {
-		if idx != 0 {
-			argList += ","
-		}
-		argList += e.buildExpr(p)
+func (e *emitter) buildAssign(a *ir.Assign) {
+	e.writetln(e.indentLevel, "%s;", e.writeAssign(a))
+}
+
+// mangleMethod returns the name of the C function
+// that the method of the given parent is emitted as.
+func mangleMethod(parent string, name string) string {
+	return fmt.Sprintf("%s_%s", parent, name)
+}
+
+// buildReceiver writes the receiver of a method call, taking
+// the address of or dereferencing the value as the method needs.
+func (e *emitter) buildReceiver(recv *ir.Value, method *ir.Function) string {
+	val := e.buildExpr(recv)
+
+	typ := e.typeOf(recv)
+	isPointer := typ != nil && typ.Kind == ir.PointerKind
+
+	switch {
+	case method.Receiver.Kind == front.ValueReceiver && isPointer:
+		return fmt.Sprintf("(*(%s))", val)
+	case method.Receiver.Kind != front.ValueReceiver && !isPointer:
+		return fmt.Sprintf("(&(%s))", val)
+	}
+	return val
+}
+
+// staticMethod returns the name of the method without a
+// receiver that the call is to, e.g. make in Foo.make(),
+// or an empty string if it isn't a call to one.
+func (e *emitter) staticMethod(c *ir.Call, parent *ir.Structure) string {
+	if parent == nil {
+		return ""
 	}
-	left := e.buildExpr(c.Left)
 
-	// FIXME hard coded mangle thing
-	e.writet(e.indentLevel, "%s(%s)", left, argList)
+	values := c.Left.Path.Values
+	name := values[len(values)-1].Identifier.Name.Value
+
+	impl, ok := e.mod.GetImpl(parent.Name.Value)
+	if !ok {
+		return ""
+	}
+	if method, ok := impl.Methods[name]; ok && method.Receiver == nil {
+		return name
+	}
+	return ""
+}
+
+func (e *emitter) buildCall(c *ir.Call) string {
+	var args []string
+
+	left := ""
+	recv, method, parent := e.env.MethodCall(c)
+	if method != nil {
+		// methods are emitted as functions, with the
+		// receiver passed as the first argument.
+		left = mangleMethod(method.Receiver.Parent, method.Name.Value)
+		args = append(args, e.buildReceiver(recv, method))
+	} else if name := e.staticMethod(c, parent); name != "" {
+		left = mangleMethod(parent.Name.Value, name)
+	} else {
+		left = e.buildExpr(c.Left)
+	}
+
+	// string literals passed to C functions, e.g.
+	// printf, are written as C string literals.
+	external := false
+	if iden := c.Left.Identifier; iden != nil {
+		_, ok := e.mod.Functions[iden.Name.Value]
+		external = !ok && e.env.Lookup(iden.Name.Value) == nil
+	}
+
+	for _, p := range c.Params {
+		if external && p.Kind == ir.StringValueValue {
+			args = append(args, cString(p.StringValue.Value))
+			continue
+		}
+		args = append(args, e.buildExpr(p))
+	}
+	return fmt.Sprintf("%s(%s)", left, strings.Join(args, ","))
 }
 
 func (e *emitter) buildInstr(i *ir.Instruction) {
@@ -496,14 +921,18 @@ func (e *emitter) buildInstr(i *ir.Instruction) {
 		return
 
 	case ir.BreakInstr:
-		e.writetln(e.indentLevel, "break;")
+		e.buildLoopJump("break", i.Break.Label)
+		return
+
+	case ir.NextInstr:
+		e.buildLoopJump("next", i.Next.Label)
 		return
 
 	case ir.JumpInstr:
-		e.writetln(e.indentLevel, "goto %s;", i.Jump.Location.Value)
+		e.buildJump(i.Jump)
 
 	case ir.LabelInstr:
-		e.writetln(e.indentLevel-1, "%s:", i.Label.Name.Value)
+		e.writetln(e.indentLevel-1, "%s:;", i.Label.Name.Value)
 
 	default:
 		e.error(api.NewUnimplementedError("compilation", "unhandled instr"))
@@ -515,33 +944,143 @@ func (e *emitter) buildBlock(b *ir.Block) {
 	e.writetln(e.indentLevel, "{")
 	e.indentLevel++
 
-	for _, instr := range b.Instr {
+	e.env.Push()
+	defer e.env.Pop()
+
+	scope := &deferScope{block: b}
+	e.scopes = append(e.scopes, scope)
+
+	for idx, instr := range b.Instr {
+		scope.pos = idx
 		e.buildInstr(instr)
 	}
 
-	for i := len(b.DeferStack) - 1; i >= 0; i-- {
-		item := b.DeferStack[i]
-		if item.Block != nil {
-			e.buildBlock(item.Block)
+	// when control falls off the end of the
+	// block every defer in it has been reached.
+	if !endsInJump(b) {
+		scope.pos = len(b.Instr)
+		e.runDefers(scope)
+	}
+
+	e.scopes = e.scopes[:len(e.scopes)-1]
+
+	e.indentLevel--
+	e.writetln(e.indentLevel, "}")
+}
+
+// endsInJump returns whether the last instruction
+// of the block leaves it, e.g. a return or break.
+func endsInJump(b *ir.Block) bool {
+	if len(b.Instr) == 0 {
+		return false
+	}
+
+	switch b.Instr[len(b.Instr)-1].Kind {
+	case ir.ReturnInstr, ir.BreakInstr, ir.NextInstr, ir.JumpInstr:
+		return true
+	}
+	return false
+}
+
+// runDefers writes the defers in the given scope that
+// have been reached, the most recent defer runs first.
+// the deferred code can't see the scopes it's run from,
+// otherwise a return in a defer would unwind them again.
+func (e *emitter) runDefers(s *deferScope) {
+	scopes := e.scopes
+	e.scopes = nil
+	defer func() {
+		e.scopes = scopes
+	}()
+
+	defers := s.block.DeferStack
+	for i := len(defers) - 1; i >= 0; i-- {
+		def := defers[i]
+		if def.After > s.pos {
+			continue
+		}
+
+		if def.Block != nil {
+			e.buildBlock(def.Block)
 		} else {
-			e.buildInstr(item.Stat)
+			e.buildInstr(def.Stat)
+		}
+	}
+}
+
+// hasDefers returns whether any defers have been reached
+// in the scopes from the given depth to the innermost scope.
+func (e *emitter) hasDefers(depth int) bool {
+	for _, s := range e.scopes[depth:] {
+		for _, def := range s.block.DeferStack {
+			if def.After <= s.pos {
+				return true
+			}
 		}
 	}
+	return false
+}
 
-	if b.Return != nil {
-		e.buildInstr(b.Return)
+// unwind writes the defers that have been reached in every
+// scope from the innermost scope out to the given depth, this
+// is done before control leaves them with a return, break or next.
+func (e *emitter) unwind(depth int) {
+	scopes := e.scopes
+	for i := len(scopes) - 1; i >= depth; i-- {
+		e.runDefers(scopes[i])
 	}
+}
 
-	e.indentLevel--
-	e.writetln(e.indentLevel, "}")
+// buildJump writes a goto to the label, first running
+// the defers of the scopes that the jump leaves.
+func (e *emitter) buildJump(j *ir.Jump) {
+	target := e.labels[j.Location.Value]
+
+	depth := 0
+	for depth < len(target) && depth < len(e.scopes) &&
+		e.scopes[depth].block == target[depth] {
+		depth++
+	}
+
+	e.unwind(depth)
+	e.writetln(e.indentLevel, "goto %s;", j.Location.Value)
+}
+
+// findLabels records the blocks enclosing each label in
+// the given block, deferred code isn't searched.
+func findLabels(b *ir.Block, outer []*ir.Block, res map[string][]*ir.Block) {
+	path := append(append([]*ir.Block{}, outer...), b)
+
+	for _, instr := range b.Instr {
+		switch instr.Kind {
+		case ir.LabelInstr:
+			res[instr.Label.Name.Value] = path
+
+		case ir.BlockInstr:
+			findLabels(instr.Block, path, res)
+
+		case ir.IfStatementInstr:
+			iff := instr.IfStatement
+			findLabels(iff.True, path, res)
+			for _, elif := range iff.ElseIf {
+				findLabels(elif.Body, path, res)
+			}
+			if iff.Else != nil {
+				findLabels(iff.Else, path, res)
+			}
+
+		case ir.WhileLoopInstr:
+			findLabels(instr.WhileLoop.Body, path, res)
+
+		case ir.LoopInstr:
+			findLabels(instr.Loop.Body, path, res)
+		}
+	}
 }
 
 func (e *emitter) emitStructure(st *ir.Structure) {
 	stName := st.Name.Value
 
-	// forward declare 'Struct name' as just 'name'
-	e.writetln(e.indentLevel, "typedef struct %s %s;", stName, stName)
-
 	e.writetln(e.indentLevel, "struct %s {", stName)
 	e.indentLevel++
 
@@ -555,6 +1094,21 @@ func (e *emitter) emitStructure(st *ir.Structure) {
 	e.writetln(e.indentLevel, "};")
 }
 
+// emitGlobal writes the given global as a file-scope
+// declaration. globals are always initialised with a constant
+// value so we can write the initialiser as is.
+func (e *emitter) emitGlobal(l *ir.Local) {
+	// compound literals aren't constant in C, so strings
+	// and initializers are initialised with a brace list.
+	value := ";"
+	if l.Val != nil {
+		value = fmt.Sprintf(" = %s;", e.buildInitValue(l.Val))
+	}
+
+	typedName := e.emitTypedName(l.Mutable, l.Type, l.Name.Value)
+	e.writeln("%s%s", typedName, value)
+}
+
 func (e *emitter) emitFunc(fn *ir.Function) {
 	generatedFuncName := fn.Name.Value
 
@@ -564,6 +1118,14 @@ func (e *emitter) emitFunc(fn *ir.Function) {
 		generatedFuncName = "krug_" + fn.Name.Value
 	}
 
+	e.emitFuncAs(generatedFuncName, fn)
+}
+
+func (e *emitter) emitMethod(parent string, fn *ir.Function) {
+	e.emitFuncAs(mangleMethod(parent, fn.Name.Value), fn)
+}
+
+func (e *emitter) emitFuncAs(generatedFuncName string, fn *ir.Function) {
 	writeArgList := func(fn *ir.Function) string {
 		var argList string
 
@@ -592,30 +1154,128 @@ func (e *emitter) emitFunc(fn *ir.Function) {
 
 	e.writeln("%s %s(%s)", returnType, generatedFuncName, argList)
 
+	e.fn = fn
+	e.labels = map[string][]*ir.Block{}
+	findLabels(fn.Body, nil, e.labels)
+
+	e.env.Push()
+	e.env.DeclareParams(fn)
 	e.buildBlock(fn.Body)
+	e.env.Pop()
+}
+
+// runtimeHeaders are the system headers that the
+// generated code relies on.
+var runtimeHeaders = []string{
+	"stdio.h",
+	"stdbool.h",
+	"stdint.h",
+	"stdlib.h",
+	"string.h",
+}
+
+// emitIncludes writes the runtime headers followed by
+// the headers requested via. #{include} directives.
+// system headers are written as <foo.h> and local
+// headers as "foo.h".
+func (e *emitter) emitIncludes(includes []*front.IncludeDirective) {
+	written := map[string]bool{}
+	for _, h := range runtimeHeaders {
+		e.writeln(`#include <%s>`, h)
+		written["<"+h+">"] = true
+	}
+
+	for _, inc := range includes {
+		header := fmt.Sprintf(`"%s"`, inc.Path)
+		if inc.System {
+			header = fmt.Sprintf("<%s>", inc.Path)
+		}
+
+		if written[header] {
+			continue
+		}
+		written[header] = true
+
+		e.writeln("#include %s", header)
+	}
+}
+
+// stringRuntime is the support for the str type. strings are
+// immutable and their data is always null terminated so that
+// it can be passed straight to C.
+const stringRuntime = `
+typedef struct { const uint8_t* data; uint64_t len; } krug_str;
+
+static inline krug_str krug_str_from_cstr(const uint8_t* s) {
+	return (krug_str){ s, strlen((const char*) s) };
+}
+
+static inline int krug_str_cmp(krug_str a, krug_str b) {
+	int res = memcmp(a.data, b.data, a.len < b.len ? a.len : b.len);
+	if (res != 0) {
+		return res;
+	}
+	return (a.len > b.len) - (a.len < b.len);
 }
 
-func Codegen(mod *ir.Module, tabSize int, minify bool) (string, []api.CompilerError) {
-	fmt.Println(mod)
+// the result of a concatenation is allocated
+// on the heap and owned by the caller.
+static inline krug_str krug_str_concat(krug_str a, krug_str b) {
+	uint8_t* data = malloc(a.len + b.len + 1);
+	memcpy(data, a.data, a.len);
+	memcpy(data + a.len, b.data, b.len);
+	data[a.len + b.len] = 0;
+	return (krug_str){ data, a.len + b.len };
+}`
+
+// boundsCheckRuntime is written when bounds checks are enabled,
+// a failed check reports the span of the index in the krug source.
+const boundsCheckRuntime = `
+static uint64_t krug_check_index(uint64_t idx, uint64_t len, int start, int end) {
+	if (idx >= len) {
+		fprintf(stderr, "krug: index %llu out of bounds for length %llu at span [%d, %d]\n",
+			(unsigned long long) idx, (unsigned long long) len, start, end);
+		abort();
+	}
+	return idx;
+}
 
+static void krug_check_slice(uint64_t low, uint64_t high, uint64_t len, int start, int end) {
+	if (low > high || high > len) {
+		fprintf(stderr, "krug: slice [%llu..%llu] out of bounds for length %llu at span [%d, %d]\n",
+			(unsigned long long) low, (unsigned long long) high, (unsigned long long) len, start, end);
+		abort();
+	}
+}`
+
+func Codegen(mod *ir.Module, types *ir.TypeMap, tabSize int, minify bool, boundsCheck bool) (string, []api.CompilerError) {
 	e := &emitter{
-		tabSize: tabSize,
-		minify:  minify,
-		errors: []api.CompilerError{},
+		tabSize:     tabSize,
+		minify:      minify,
+		errors:      []api.CompilerError{},
+		env:         ir.NewEnv(mod),
+		types:       types,
+		mod:         mod,
+		sliceTypes:  map[string]bool{},
+		boundsCheck: boundsCheck,
 	}
-	e.retarget(&e.decl)
+	e.retarget(&e.header)
+
+	e.emitIncludes(mod.Includes)
+	e.writeln("%s", stringRuntime)
 
-	headers := []string{
-		"stdio.h",
-		"stdbool.h",
-		"stdint.h",
-		"stdlib.h",
-		"string.h",
+	if boundsCheck {
+		e.writeln("%s", boundsCheckRuntime)
 	}
-	for _, h := range headers {
-		e.writeln(`#include <%s>`, h)
+
+	// forward declare 'struct name' as just 'name', this
+	// is done first so that slices of structures can be declared.
+	for _, name := range mod.StructureOrder {
+		e.writeln("typedef struct %s %s;", name.Value, name.Value)
 	}
 
+	e.retarget(&e.decl)
+
 	globalVariables := []string{
 		"static int arg_count;",
 		"static char** arguments;",
@@ -624,14 +1284,34 @@ func Codegen(mod *ir.Module, tabSize int, minify bool) (string, []api.CompilerEr
 		e.writeln(v)
 	}
 
-	for _, st := range mod.Structures {
-		e.emitStructure(st)
+	for _, name := range mod.StructureOrder {
+		e.emitStructure(mod.Structures[name.Value])
+	}
+
+	for _, instr := range mod.Global.Instr {
+		if instr.Kind == ir.LocalInstr {
+			e.emitGlobal(instr.Local)
+		}
 	}
 
 	e.retarget(&e.source)
 
-	for _, fn := range mod.Functions {
-		e.emitFunc(fn)
+	for _, name := range mod.FunctionOrder {
+		e.emitFunc(mod.Functions[name.Value])
+	}
+
+	// methods are only emitted for impls of structures that
+	// exist, otherwise there is no type for the receiver. the
+	// impls of unknown structures are reported by the build.
+	for _, name := range mod.ImplsOrder {
+		if _, ok := mod.GetStructure(name.Value); !ok {
+			continue
+		}
+
+		impl := mod.Impls[name.Value]
+		for _, method := range impl.Order {
+			e.emitMethod(name.Value, impl.Methods[method.Value])
+		}
 	}
 
 	const runtime = `
@@ -645,5 +1325,5 @@ int main(int argc, char** argv) {
 	e.retarget(&e.source)
 	e.writeln(runtime)
 
-	return string(e.decl + e.source), e.errors
+	return e.header + e.slices + e.decl + e.source, e.errors
 }
@@ -13,5 +13,8 @@ func (is *irService) register(router *gin.Engine) {
 	i := router.Group("/ir")
 	{
 		i.POST("/build", service.Build)
+		i.POST("/print", service.Print)
+		i.POST("/parse", service.ParseText)
+		i.POST("/verify", service.Verify)
 	}
 }
@@ -25,12 +25,48 @@ func (ms *middleService) register(router *gin.Engine) {
 			b.POST("/type", service.BuildType)
 		}
 
+		// module, offset -> [hover] -> symbol
+		// the type of the symbol at the offset, for editors.
+		m.POST("/hover", service.Hover)
+
 		m.POST("/unused_func", service.UnusedFunctions)
 
 		// TODO grouping for these?
 		m.POST("/borrow_check", middle.BorrowCheck)
 		m.POST("mut_check", middle.MutabilityCheck)
 
+		// module -> [conv_check]
+		// checks the conversions of operators and 'as' casts.
+		m.POST("/conv_check", service.ConversionCheck)
+
+		// module -> [method_check]
+		// resolves method calls against impls.
+		m.POST("/method_check", service.MethodCheck)
+
+		// module -> [loop_check]
+		// checks break and next and their labels.
+		m.POST("/loop_check", service.LoopCheck)
+
+		// module -> [defer_check]
+		// checks that deferred code doesn't return.
+		m.POST("/defer_check", service.DeferCheck)
+
+		// module -> [jump_check]
+		// checks labels and the jumps to them.
+		m.POST("/jump_check", service.JumpCheck)
+
+		// module -> [type_check]
+		// checks the types given to locals, calls and returns.
+		m.POST("/type_check", service.TypeCheck)
+
+		// module -> [const_fold] -> module
+		// evaluates constant expressions at compile time.
+		m.POST("/const_fold", service.ConstantFold)
+
+		// module -> [dead_code] -> module
+		// removes code that can never run.
+		m.POST("/dead_code", service.DeadCode)
+
 		r := m.Group("/resolve")
 		{
 			// semantic module{module, scope_map, type_map} -> [type_resolve]
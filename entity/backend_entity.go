@@ -14,4 +14,9 @@ type CodeGenerationRequest struct {
 	// this mode simply strips newlines from blocks and
 	// structs, etc.
 	Minify bool `json:"minify"`
+
+	// BoundsCheck is a flag that specifies whether or not
+	// indexes and slices of arrays and slices are checked
+	// at runtime, a failed check aborts the program.
+	BoundsCheck bool `json:"bounds_check"`
 }
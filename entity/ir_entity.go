@@ -4,4 +4,29 @@ package entity
 
 type IRBuildRequest struct {
 	TreeNodes string `json:"tree_nodes"`
+
+	// Cfg is the set of keys (and their values) that
+	// #{cfg(...)} directives are checked against, e.g.
+	// {"debug": "", "os": "linux"}
+	Cfg map[string]string `json:"cfg"`
+}
+
+// ir print
+
+type IRPrintRequest struct {
+	IRModule string `json:"ir_module"`
+}
+
+// ir parse
+
+type IRParseRequest struct {
+	// Source is the textual form of a module
+	// as written by /ir/print
+	Source string `json:"source"`
+}
+
+// ir verify
+
+type IRVerifyRequest struct {
+	IRModule string `json:"ir_module"`
 }
@@ -2,7 +2,14 @@ package entity
 
 // sema stuff
 
-type BuildTypeMapRequest struct{}
+type BuildTypeMapRequest struct {
+	IRModule string `json:"ir_module"`
+}
+
+type HoverRequest struct {
+	IRModule string `json:"ir_module"`
+	Offset   int    `json:"offset"`
+}
 
 type BuildScopeDictRequest struct {
 	IRModule string `json:"ir_module"`
@@ -35,6 +42,54 @@ type MutabilityCheckRequest struct {
 	ScopeMap string `json:"scope_map"`
 }
 
+// conversion check
+
+type ConversionCheckRequest struct {
+	IRModule string `json:"ir_module"`
+}
+
+// method check
+
+type MethodCheckRequest struct {
+	IRModule string `json:"ir_module"`
+}
+
+// loop check
+
+type LoopCheckRequest struct {
+	IRModule string `json:"ir_module"`
+}
+
+// defer check
+
+type DeferCheckRequest struct {
+	IRModule string `json:"ir_module"`
+}
+
+// jump check
+
+type JumpCheckRequest struct {
+	IRModule string `json:"ir_module"`
+}
+
+// type check
+
+type TypeCheckRequest struct {
+	IRModule string `json:"ir_module"`
+}
+
+// constant folding
+
+type ConstantFoldRequest struct {
+	IRModule string `json:"ir_module"`
+}
+
+// dead code elimination
+
+type DeadCodeRequest struct {
+	IRModule string `json:"ir_module"`
+}
+
 // resolution stuff
 
 type TypeResolveRequest struct{}
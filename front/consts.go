@@ -12,6 +12,7 @@ const (
 	FloatingConstant                   = "floatingConst"
 	StringConstant                     = "stringConst"
 	CharacterConstant                  = "charConst"
+	BooleanConstant                    = "boolConst"
 )
 
 type ConstantNode struct {
@@ -21,6 +22,7 @@ type ConstantNode struct {
 	FloatingConstantNode  *FloatingConstantNode  `json:"floatingConst,omitempty"`
 	StringConstantNode    *StringConstantNode    `json:"stringConst,omitempty"`
 	CharacterConstantNode *CharacterConstantNode `json:"charConst,omitempty"`
+	BooleanConstantNode   *BooleanConstantNode   `json:"boolConst,omitempty"`
 }
 
 type VariableReferenceNode struct {
@@ -42,3 +44,7 @@ type StringConstantNode struct {
 type CharacterConstantNode struct {
 	Value string `json:"value"`
 }
+
+type BooleanConstantNode struct {
+	Value bool `json:"value"`
+}
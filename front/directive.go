@@ -1,33 +1,45 @@
 package front
 
-type valueKind string
+// ArgumentKind is the kind of value that is passed
+// as an argument to a directive.
+type ArgumentKind string
 
 const (
-	stringValue    valueKind = "str"
-	integerValue             = "int"
-	characterValue           = "val"
-	floatingValue            = "float"
+	StringArgument     ArgumentKind = "str"
+	IntegerArgument                 = "int"
+	CharacterArgument               = "char"
+	FloatingArgument                = "float"
+	IdentifierArgument              = "iden"
 )
 
-type value struct {
-	kind  valueKind
-	value Token
+// DirectiveArgument is an argument passed to a directive,
+// e.g. "foo" or 32. if the argument is named, i.e.
+// key = "value", Name is set to the key.
+type DirectiveArgument struct {
+	Name  string       `json:"name,omitempty"`
+	Kind  ArgumentKind `json:"kind"`
+	Value string       `json:"value"`
+	Span  []int        `json:"span"`
 }
 
-type directiveKind string
+type DirectiveKind string
 
 const (
-	Include  directiveKind = "include"
+	Include  DirectiveKind = "include"
 	Link                   = "link"
 	NoMangle               = "no_mangle"
 	Align                  = "align"
 	Packed                 = "packed"
 	Clang                  = "clang"
+	Cfg                    = "cfg"
 )
 
 // #{include(string)}
+// a path wrapped in angle brackets, e.g. "<stdio.h>",
+// is a system include. anything else is a local include.
 type IncludeDirective struct {
-	Path string `json:"path"`
+	Path   string `json:"path"`
+	System bool   `json:"system"`
 }
 
 // #{link("/some/path")}
@@ -49,12 +61,26 @@ type NoMangleDirective struct{}
 // #{packed}
 type PackedDirective struct{}
 
+// #{cfg(debug)} or #{cfg(key = "value")}
+// the declaration or statement that follows is only
+// compiled when the key is set, or when the key is set
+// to the given value.
+type CfgDirective struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+// Directive is a parsed directive. directives that have been
+// registered by an embedding application only set the Kind and
+// the Arguments that were passed to them.
 type Directive struct {
-	Kind              directiveKind
-	IncludeDirective  *IncludeDirective  `json:"includeDirective,omitempty"`
-	LinkDirective     *LinkDirective     `json:"linkDirective,omitempty"`
-	AlignDirective    *AlignDirective    `json:"alignDirective,omitempty"`
-	NoMangleDirective *NoMangleDirective `json:"noMangleDirective,omitempty"`
-	PackedDirective   *PackedDirective   `json:"packedDirective,omitempty"`
-	ClangDirective    *ClangDirective    `json:"clangDirective,omitempty"`
+	Kind              DirectiveKind
+	Arguments         []*DirectiveArgument `json:"arguments,omitempty"`
+	IncludeDirective  *IncludeDirective    `json:"includeDirective,omitempty"`
+	LinkDirective     *LinkDirective       `json:"linkDirective,omitempty"`
+	AlignDirective    *AlignDirective      `json:"alignDirective,omitempty"`
+	NoMangleDirective *NoMangleDirective   `json:"noMangleDirective,omitempty"`
+	PackedDirective   *PackedDirective     `json:"packedDirective,omitempty"`
+	ClangDirective    *ClangDirective      `json:"clangDirective,omitempty"`
+	CfgDirective      *CfgDirective        `json:"cfgDirective,omitempty"`
 }
@@ -2,7 +2,6 @@ package front
 
 import (
 	"fmt"
-	"strconv"
 	"strings"
 
 	"github.com/krug-lang/caasper/api"
@@ -12,204 +11,301 @@ type directiveParser struct {
 	parser
 }
 
-func (p *directiveParser) hasNext() bool {
-	return p.pos < len(p.toks) && len(p.errors) == 0
+// unquote strips the surrounding quotes from the given
+// string token, e.g. "foo" or `foo` becomes foo.
+func unquote(tok Token) string {
+	if tok.Kind != String || len(tok.Value) < 2 {
+		return tok.Value
+	}
+	return tok.Value[1 : len(tok.Value)-1]
 }
 
-type parseFn func(p *directiveParser) *Directive
+// accept consumes the next token if it matches the given
+// value, otherwise an error is raised.
+func (p *directiveParser) accept(val string) bool {
+	if p.hasNext() && p.next().Matches(val) {
+		p.consume()
+		return true
+	}
+	p.expect(val)
+	return false
+}
 
-func (p *directiveParser) parseArgumentList() []value {
-	p.expect("(")
+// skipGroup skips to the closing brace of the directive
+// group that we're in, this is used to recover from errors.
+func (p *directiveParser) skipGroup() {
+	for p.hasNext() && !p.next().Matches("}") {
+		p.consume()
+	}
+}
 
-	vals := []value{}
+func (p *directiveParser) parseArgument() *DirectiveArgument {
+	start := p.pos
 
-	idx := 0
-	for p.hasNext() && !p.next().Matches(")") {
-		if idx != 0 {
-			p.expect(",")
-		}
+	var name string
+	tok := p.consume()
 
-		var kind valueKind
-		tok := p.consume()
-		switch tok.Kind {
-		case String:
-			kind = stringValue
-		case Number:
-			if strings.Index(tok.Value, ".") == -1 {
-				kind = integerValue
-			} else {
-				kind = floatingValue
-			}
-		case Char:
-			kind = characterValue
-		default:
-			panic(fmt.Sprintf("unhandled directive value %s", tok.Value))
+	// named argument, e.g. key = "value"
+	if tok.Kind == Identifier && p.hasNext() && p.next().Matches("=") {
+		p.expect("=")
+		if !p.hasNext() {
+			p.error(api.NewDirectiveParseError("expected value after '='", start, p.pos))
+			return nil
 		}
+		name, tok = tok.Value, p.consume()
+	}
 
-		vals = append(vals, value{kind, tok})
-		idx++
+	arg := &DirectiveArgument{
+		Name:  name,
+		Value: tok.Value,
+		Span:  tok.Span,
 	}
 
-	p.expect(")")
+	switch tok.Kind {
+	case String:
+		arg.Kind = StringArgument
+		arg.Value = unquote(tok)
+	case Number:
+		if strings.Index(tok.Value, ".") == -1 {
+			arg.Kind = IntegerArgument
+		} else {
+			arg.Kind = FloatingArgument
+		}
+	case Char:
+		arg.Kind = CharacterArgument
+	case Identifier:
+		arg.Kind = IdentifierArgument
+	default:
+		p.error(api.NewDirectiveParseError(fmt.Sprintf("unexpected '%s' in directive arguments", tok.Value), start, p.pos))
+		return nil
+	}
 
-	return vals
+	return arg
 }
 
-func parseInclude(p *directiveParser) *Directive {
-	start := p.pos
-
-	args := p.parseArgumentList()
-	if args == nil {
-		p.error(api.NewDirectiveParseError("expected argument list", start, p.pos))
-		return nil
+// parseArgumentList parses the (optional) argument list of a
+// directive, e.g. (a, b = "c"). if there is no argument list
+// then no arguments are returned.
+func (p *directiveParser) parseArgumentList() ([]*DirectiveArgument, bool) {
+	args := []*DirectiveArgument{}
+	if !p.hasNext() || !p.next().Matches("(") {
+		return args, true
 	}
 
-	if len(args) != 1 {
-		p.error(api.NewDirectiveParseError("not enough arguments supplied", start, p.pos))
-		return nil
-	}
+	p.expect("(")
+	for idx := 0; p.hasNext() && !p.next().Matches(")"); idx++ {
+		if idx != 0 && !p.accept(",") {
+			return nil, false
+		}
 
-	if args[0].kind != stringValue {
-		p.error(api.NewDirectiveParseError("include should have on parameter of type 'string'", start, p.pos))
-		return nil
+		arg := p.parseArgument()
+		if arg == nil {
+			return nil, false
+		}
+		args = append(args, arg)
 	}
 
-	path := args[0].value.Value
-	return &Directive{
-		Kind:             Include,
-		IncludeDirective: &IncludeDirective{path},
+	if !p.accept(")") {
+		return nil, false
 	}
+	return args, true
 }
 
-func parseLink(p *directiveParser) *Directive {
-	args := p.parseArgumentList()
-	if args == nil {
-		// TODO(ERROR)
-		return nil
+// matchArguments checks the given arguments against the schema of
+// the directive. the returned arguments are in the order of the schema,
+// followed by any extra arguments passed to a variadic directive.
+func (p *directiveParser) matchArguments(spec *DirectiveSpec, args []*DirectiveArgument, start int) ([]*DirectiveArgument, bool) {
+	schema := spec.Args
+	matched := make([]*DirectiveArgument, len(schema))
+	variadic := []*DirectiveArgument{}
+
+	argError := func(f string, d ...interface{}) ([]*DirectiveArgument, bool) {
+		what := fmt.Sprintf("directive '%s': %s", spec.Name, fmt.Sprintf(f, d...))
+		p.error(api.NewDirectiveParseError(what, start, p.pos))
+		return nil, false
 	}
 
-	flags := make([]string, len(args))
-	for i := 0; i < len(args); i++ {
-		flags[i] = args[i].value.Value
-	}
+	positional := 0
+	for _, arg := range args {
+		idx := -1
 
-	return &Directive{
-		Kind:          Link,
-		LinkDirective: &LinkDirective{flags},
-	}
-}
+		if arg.Name != "" {
+			for i, s := range schema {
+				if s.Name == arg.Name {
+					idx = i
+					break
+				}
+			}
 
-func parseNoMangle(p *directiveParser) *Directive {
-	if !p.next().Matches("}") {
-		return nil
+			// try and place it in an argument that can
+			// be passed by any name.
+			if idx == -1 {
+				for i, s := range schema {
+					if s.AnyName && matched[i] == nil {
+						idx = i
+						break
+					}
+				}
+			}
+
+			if idx == -1 {
+				return argError("no such argument '%s'", arg.Name)
+			}
+		} else {
+			idx = positional
+			if idx >= len(schema) {
+				if !spec.Variadic {
+					return argError("expected at most %d argument(s)", len(schema))
+				}
+				idx = len(schema) - 1
+			}
+			positional++
+		}
+
+		s := schema[idx]
+		if !s.accepts(arg.Kind) {
+			return argError("argument '%s' should be of kind %s", s.Name, s.Kinds)
+		}
+
+		if matched[idx] != nil {
+			if !spec.Variadic || idx != len(schema)-1 {
+				return argError("argument '%s' was given more than once", s.Name)
+			}
+			variadic = append(variadic, arg)
+			continue
+		}
+		matched[idx] = arg
 	}
 
-	return &Directive{
-		Kind:              NoMangle,
-		NoMangleDirective: &NoMangleDirective{},
+	for i, s := range schema {
+		if matched[i] != nil {
+			continue
+		}
+		if !s.Optional {
+			return argError("missing argument '%s'", s.Name)
+		}
+		matched[i] = s.Default
 	}
+
+	return append(matched, variadic...), true
 }
 
-func parseAlign(p *directiveParser) *Directive {
-	args := p.parseArgumentList()
-	if args == nil {
-		// TODO(ERROR)
-		return nil
-	}
+// parseSingleDirective parses a directive in a directive group, e.g. the
+// include("<stdio.h>") in #{include("<stdio.h>"), link("-lm")}.
+//
+// if target is set, the directive is checked to see if it can be applied
+// to the target. directives at the top level can always be module directives.
+func (p *directiveParser) parseSingleDirective(target DirectiveTarget, topLevel bool) *Directive {
+	start := p.pos
 
-	if len(args) != 1 {
-		// TODO(ERROR)
+	word := p.expectKind(Identifier)
+	if word.Kind != Identifier {
 		return nil
 	}
 
-	val := args[0].value.Value
-	alignment, err := strconv.ParseUint(val, 10, 64)
-	if err != nil {
-		// TODO(ERROR)
-		panic(err)
+	spec, ok := LookupDirective(word.Value)
+	if !ok {
+		p.error(api.NewUnknownDirective(word.Value, start, p.pos))
+		return nil
 	}
 
-	return &Directive{
-		Kind:           Align,
-		AlignDirective: &AlignDirective{alignment},
+	args, ok := p.parseArgumentList()
+	if !ok {
+		return nil
 	}
-}
 
-func parsePacked(p *directiveParser) *Directive {
-	if !p.next().Matches("}") {
+	matched, ok := p.matchArguments(spec, args, start)
+	if !ok {
 		return nil
 	}
 
-	return &Directive{
-		Kind:            Packed,
-		PackedDirective: &PackedDirective{},
-	}
-}
-
-func parseClang(p *directiveParser) *Directive {
-	if !p.next().Matches("}") {
+	if target != "" && !spec.allows(target) && !(topLevel && spec.allows(ModuleTarget)) {
+		p.error(api.NewDirectiveTargetError(spec.Name, string(target), start, p.pos))
 		return nil
 	}
 
-	return &Directive{
-		Kind:           Clang,
-		ClangDirective: &ClangDirective{},
+	dir := &Directive{Kind: DirectiveKind(spec.Name)}
+	if spec.Build != nil {
+		built, err := spec.Build(matched)
+		if err != nil {
+			p.error(api.NewDirectiveParseError(fmt.Sprintf("directive '%s': %s", spec.Name, err), start, p.pos))
+			return nil
+		}
+		dir = built
 	}
+	dir.Arguments = matched
+
+	return dir
 }
 
-func (p *directiveParser) parseDirective() []*Directive {
+// parseDirective parses a directive group, e.g. #{no_mangle, clang}
+func (p *directiveParser) parseDirective(target DirectiveTarget, topLevel bool) []*Directive {
 	p.expect("#")
 	p.expect("{")
 
-	luTable := map[string]parseFn{
-		"include":   parseInclude,
-		"link":      parseLink,
-		"no_mangle": parseNoMangle,
-		"align":     parseAlign,
-		"packed":    parsePacked,
-		"clang":     parseClang,
-	}
-
 	dirs := []*Directive{}
 
-	idx := 0
-	for p.hasNext() {
-		if idx != 0 {
-			p.expect(",")
-		}
-
-		word := p.expectKind(Identifier)
-		fmt.Println(word)
-		if res, ok := luTable[word.Value]; ok {
-			if dir := res(p); dir != nil {
-				dirs = append(dirs, dir)
-			}
-		} else {
-			// TODO(ERROR): unrecognize directive
-			panic(fmt.Sprintf("no such directive! %s", word))
+	for idx := 0; p.hasNext() && !p.next().Matches("}"); idx++ {
+		if idx != 0 && !p.accept(",") {
+			p.skipGroup()
+			break
 		}
 
-		if p.next().Matches("}") {
+		dir := p.parseSingleDirective(target, topLevel)
+		if dir == nil {
+			p.skipGroup()
 			break
 		}
-
-		idx++
+		dirs = append(dirs, dir)
 	}
 
 	p.expect("}")
 
-	fmt.Println(dirs)
-
 	return dirs
 }
 
+// directiveTarget works out what the directive group at the given
+// position is applied to, skipping over any directive groups that
+// directly follow it.
+func (p *parser) directiveTarget(pos int, topLevel bool) DirectiveTarget {
+	for pos < len(p.toks) && p.toks[pos].Matches("#") {
+		for pos < len(p.toks) && !p.toks[pos].Matches("}") {
+			pos++
+		}
+		pos++
+	}
+
+	if pos < len(p.toks) {
+		switch tok := p.toks[pos]; {
+		case tok.Matches(fn):
+			return FunctionTarget
+		case tok.Matches(typ):
+			return TypeTarget
+		case tok.Matches(impl):
+			return ImplTarget
+		case tok.Matches(trait):
+			return TraitTarget
+		case tok.Matches(let, mut):
+			return VariableTarget
+		}
+	}
+
+	if topLevel {
+		return ModuleTarget
+	}
+	return StatementTarget
+}
+
+// ParseDirectives parses all of the directives in the given
+// token stream. the directives are not checked against what
+// they are applied to.
 func ParseDirectives(toks []Token) ([]*Directive, []api.CompilerError) {
 	p := &directiveParser{parser{toks, 0, []api.CompilerError{}}}
 
 	nodes := []*Directive{}
 	for p.hasNext() {
 		if curr := p.next(); curr.Matches("#") {
-			nodes = append(nodes, p.parseDirective()...)
+			nodes = append(nodes, p.parseDirective("", false)...)
 		} else {
 			p.consume()
 		}
@@ -0,0 +1,256 @@
+package front
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DirectiveTarget is the kind of declaration or statement
+// that a directive is applied to.
+type DirectiveTarget string
+
+const (
+	// ModuleTarget is a directive that applies to the
+	// entire module, e.g. #{include("<stdio.h>")}. module
+	// directives can be written anywhere at the top level.
+	ModuleTarget    DirectiveTarget = "module"
+	FunctionTarget                  = "function"
+	TypeTarget                      = "type"
+	ImplTarget                      = "impl"
+	TraitTarget                     = "trait"
+	VariableTarget                  = "variable"
+	StatementTarget                 = "statement"
+)
+
+// ArgumentSchema describes a single argument that
+// a directive accepts.
+type ArgumentSchema struct {
+	// Name is the key that the argument can be passed
+	// by, e.g. align(alignment = 8)
+	Name string
+
+	// Kinds are the kinds of values that are accepted.
+	Kinds []ArgumentKind
+
+	// Optional arguments can be omitted, in which case
+	// Default (if set) is passed in their place.
+	Optional bool
+	Default  *DirectiveArgument
+
+	// AnyName arguments can be passed under any key, e.g.
+	// cfg(os = "linux"), the key is kept in the arguments Name.
+	AnyName bool
+}
+
+func (a ArgumentSchema) accepts(kind ArgumentKind) bool {
+	for _, k := range a.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// DirectiveBuilder builds a Directive from the arguments
+// that have been checked against the directives schema. the
+// arguments are in the order of the schema, omitted optional
+// arguments without a default are nil.
+type DirectiveBuilder func(args []*DirectiveArgument) (*Directive, error)
+
+// DirectiveSpec describes a directive, the arguments it
+// accepts, and what it can be applied to.
+type DirectiveSpec struct {
+	Name string
+	Args []ArgumentSchema
+
+	// Variadic directives accept any number of the
+	// last argument in Args, e.g. link("-lm", "-lc")
+	Variadic bool
+
+	// Targets that the directive can be applied to, if
+	// this is empty the directive can be applied to anything.
+	Targets []DirectiveTarget
+
+	// Build is optional, if it's not set the directive is
+	// built with only its Kind and Arguments set.
+	Build DirectiveBuilder
+}
+
+func (d *DirectiveSpec) allows(target DirectiveTarget) bool {
+	if len(d.Targets) == 0 {
+		return true
+	}
+	for _, t := range d.Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	directiveLock     sync.RWMutex
+	directiveRegistry = map[string]*DirectiveSpec{}
+)
+
+// RegisterDirective registers the given directive so that it can
+// be parsed. this should be called before any parsing happens,
+// e.g. in an init func of the embedding application.
+func RegisterDirective(spec DirectiveSpec) error {
+	if spec.Name == "" {
+		return fmt.Errorf("directive has no name")
+	}
+	if spec.Variadic && len(spec.Args) == 0 {
+		return fmt.Errorf("variadic directive '%s' has no arguments", spec.Name)
+	}
+
+	directiveLock.Lock()
+	defer directiveLock.Unlock()
+
+	if _, ok := directiveRegistry[spec.Name]; ok {
+		return fmt.Errorf("directive '%s' has already been registered", spec.Name)
+	}
+	directiveRegistry[spec.Name] = &spec
+	return nil
+}
+
+// LookupDirective returns the spec of the directive registered
+// with the given name.
+func LookupDirective(name string) (*DirectiveSpec, bool) {
+	directiveLock.RLock()
+	defer directiveLock.RUnlock()
+
+	spec, ok := directiveRegistry[name]
+	return spec, ok
+}
+
+func mustRegisterDirective(spec DirectiveSpec) {
+	if err := RegisterDirective(spec); err != nil {
+		panic(err)
+	}
+}
+
+func buildInclude(args []*DirectiveArgument) (*Directive, error) {
+	path := args[0].Value
+
+	// "<stdio.h>" is a system include, "foo.h" is local.
+	system := false
+	if strings.HasPrefix(path, "<") && strings.HasSuffix(path, ">") {
+		path = path[1 : len(path)-1]
+		system = true
+	}
+
+	return &Directive{
+		Kind:             Include,
+		IncludeDirective: &IncludeDirective{path, system},
+	}, nil
+}
+
+func buildLink(args []*DirectiveArgument) (*Directive, error) {
+	flags := make([]string, len(args))
+	for i, arg := range args {
+		flags[i] = arg.Value
+	}
+
+	return &Directive{
+		Kind:          Link,
+		LinkDirective: &LinkDirective{flags},
+	}, nil
+}
+
+func buildAlign(args []*DirectiveArgument) (*Directive, error) {
+	alignment, err := strconv.ParseUint(args[0].Value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid alignment '%s'", args[0].Value)
+	}
+
+	return &Directive{
+		Kind:           Align,
+		AlignDirective: &AlignDirective{alignment},
+	}, nil
+}
+
+func buildCfg(args []*DirectiveArgument) (*Directive, error) {
+	arg := args[0]
+
+	// #{cfg(debug)}
+	if arg.Kind == IdentifierArgument && arg.Name == "" {
+		return &Directive{
+			Kind:         Cfg,
+			CfgDirective: &CfgDirective{Key: arg.Value},
+		}, nil
+	}
+
+	// #{cfg(key = "value")}
+	if arg.Kind != StringArgument || arg.Name == "" {
+		return nil, fmt.Errorf(`cfg condition should be a key or key = "value"`)
+	}
+
+	return &Directive{
+		Kind: Cfg,
+		CfgDirective: &CfgDirective{
+			Key:   arg.Name,
+			Value: arg.Value,
+		},
+	}, nil
+}
+
+func init() {
+	mustRegisterDirective(DirectiveSpec{
+		Name:    string(Include),
+		Args:    []ArgumentSchema{{Name: "path", Kinds: []ArgumentKind{StringArgument}}},
+		Targets: []DirectiveTarget{ModuleTarget},
+		Build:   buildInclude,
+	})
+
+	mustRegisterDirective(DirectiveSpec{
+		Name:     Link,
+		Args:     []ArgumentSchema{{Name: "flags", Kinds: []ArgumentKind{StringArgument}}},
+		Variadic: true,
+		Targets:  []DirectiveTarget{ModuleTarget},
+		Build:    buildLink,
+	})
+
+	mustRegisterDirective(DirectiveSpec{
+		Name:    NoMangle,
+		Targets: []DirectiveTarget{FunctionTarget},
+		Build: func([]*DirectiveArgument) (*Directive, error) {
+			return &Directive{Kind: NoMangle, NoMangleDirective: &NoMangleDirective{}}, nil
+		},
+	})
+
+	mustRegisterDirective(DirectiveSpec{
+		Name:    Align,
+		Args:    []ArgumentSchema{{Name: "alignment", Kinds: []ArgumentKind{IntegerArgument}}},
+		Targets: []DirectiveTarget{TypeTarget},
+		Build:   buildAlign,
+	})
+
+	mustRegisterDirective(DirectiveSpec{
+		Name:    Packed,
+		Targets: []DirectiveTarget{TypeTarget},
+		Build: func([]*DirectiveArgument) (*Directive, error) {
+			return &Directive{Kind: Packed, PackedDirective: &PackedDirective{}}, nil
+		},
+	})
+
+	mustRegisterDirective(DirectiveSpec{
+		Name:    Clang,
+		Targets: []DirectiveTarget{FunctionTarget},
+		Build: func([]*DirectiveArgument) (*Directive, error) {
+			return &Directive{Kind: Clang, ClangDirective: &ClangDirective{}}, nil
+		},
+	})
+
+	mustRegisterDirective(DirectiveSpec{
+		Name: Cfg,
+		Args: []ArgumentSchema{{
+			Name:    "condition",
+			Kinds:   []ArgumentKind{IdentifierArgument, StringArgument},
+			AnyName: true,
+		}},
+		Build: buildCfg,
+	})
+}
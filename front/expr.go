@@ -18,6 +18,8 @@ const (
 	ListExpression                       = "listExpr"
 	InitializerExpression                = "initExpr"
 	TypeExpression                       = "typeExpr"
+	CastExpression                       = "castExpr"
+	SliceExpression                      = "sliceExpr"
 )
 
 type LambdaExpressionNode struct {
@@ -53,6 +55,20 @@ type GroupingNode struct {
 type IndexExpressionNode struct {
 	Left  *ExpressionNode
 	Value *ExpressionNode
+
+	// Span is the position of the brackets, this is
+	// used to report where a bounds check failed.
+	Span []int
+}
+
+// SliceExpressionNode is a view of part of an
+// array or slice, e.g. a[1..n]. either bound
+// can be omitted, e.g. a[..n] or a[1..]
+type SliceExpressionNode struct {
+	Left *ExpressionNode
+	Low  *ExpressionNode
+	High *ExpressionNode
+	Span []int
 }
 type CallExpressionNode struct {
 	Left   *ExpressionNode
@@ -61,6 +77,14 @@ type CallExpressionNode struct {
 type PathExpressionNode struct {
 	Values []*ExpressionNode
 }
+
+// CastExpressionNode is an explicit conversion
+// of a value to a type, e.g. x as f32
+type CastExpressionNode struct {
+	Value *ExpressionNode
+	Type  *ExpressionNode
+}
+
 type AssignStatementNode struct {
 	LHand *ExpressionNode
 	Op    string
@@ -82,6 +106,11 @@ type InitializerExpressionNode struct {
 	// this is the Structure name usually.
 	LHand Token
 
+	// Names is only set for named field initializers,
+	// e.g. :Person{name: "x", age: 3}, and has the
+	// field name for each of the values.
+	Names []Token
+
 	Values []*ExpressionNode
 }
 
@@ -103,4 +132,6 @@ type ExpressionNode struct {
 	AssignStatementNode       *AssignStatementNode       `json:"assignExpr,omitempty"`
 	InitializerExpressionNode *InitializerExpressionNode `json:"initExpr,omitempty"`
 	TypeExpressionNode        *TypeNode                  `json:"typeExpr,omitEmpty"`
+	CastExpressionNode        *CastExpressionNode        `json:"castExpr,omitempty"`
+	SliceExpressionNode       *SliceExpressionNode       `json:"sliceExpr,omitempty"`
 }
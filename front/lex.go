@@ -57,6 +57,12 @@ func (l *lexer) peek() rune {
 	return res
 }
 
+// hasPrefix returns whether the unconsumed input
+// starts with the given string.
+func (l *lexer) hasPrefix(s string) bool {
+	return strings.HasPrefix(string(l.input[l.pos:]), s)
+}
+
 func (l *lexer) consume() rune {
 	if l.pos >= len(l.input) {
 		l.width = 0
@@ -88,8 +94,11 @@ func lexIdentifier(l *lexer) stateFn {
 
 func lexNumber(l *lexer) stateFn {
 	l.acceptRun("0123456789")
-	if l.accept(".") {
-		l.acceptRun("012345689")
+
+	// the dot in 1..n is a range not a fraction.
+	if l.peek() == '.' && !l.hasPrefix("..") {
+		l.consume()
+		l.acceptRun("0123456789")
 	}
 	l.emit(Number)
 	return lexStart
@@ -133,6 +142,7 @@ var doubleSym = map[string]bool{
 	"||": true,
 	"<=": true,
 	">=": true,
+	"..": true,
 
 	"+=": true,
 	"-=": true,
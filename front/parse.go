@@ -26,6 +26,10 @@ const (
 	deferr          = "defer"
 	while           = "while"
 	iff             = "if"
+	as              = "as"
+	self            = "self"
+	tru             = "true"
+	fals            = "false"
 )
 
 type astParser struct {
@@ -53,6 +57,25 @@ func (p *astParser) parsePointerType() *TypeNode {
 	}
 }
 
+func (p *astParser) parseSliceType() *TypeNode {
+	start := p.pos
+
+	p.expect("[")
+	p.expect("]")
+	base := p.parseTypeExpression()
+	if base == nil {
+		p.error(api.NewParseError("slice type", start, p.pos))
+		return nil
+	}
+
+	return &TypeNode{
+		Kind: SliceType,
+		SliceTypeNode: &SliceTypeNode{
+			Base: base,
+		},
+	}
+}
+
 func (p *astParser) parseArrayType() *TypeNode {
 	start := p.pos
 
@@ -128,6 +151,8 @@ func (p *astParser) parseTypeExpression() *ExpressionNode {
 	switch {
 	case curr.Matches("*"):
 		res.TypeExpressionNode = p.parsePointerType()
+	case curr.Matches("[") && p.pos+1 < len(p.toks) && p.peek(1).Matches("]"):
+		res.TypeExpressionNode = p.parseSliceType()
 	case curr.Matches("["):
 		res.TypeExpressionNode = p.parseArrayType()
 	case curr.Matches("("):
@@ -164,13 +189,21 @@ func (p *astParser) parseStructureType() *TypeNode {
 			p.error(api.NewParseError("type", start, p.pos))
 		}
 
+		// fields can have a default value, e.g.
+		// retries int = 3,
+		var def *ExpressionNode
+		if p.next().Matches("=") {
+			p.consume()
+			def = p.parseExpression()
+		}
+
 		// NOTE: structure fields are mutable by default.
 		// immutable structure fields will not be an option
 		// in the future as it's too confusing and doesn't
 		// really make sense.
 		// IN ADDITION the fields are not owned by anything.
 		// FIXME how should this be?
-		fields = append(fields, &NamedType{true, name, false, typ})
+		fields = append(fields, &NamedType{true, name, false, typ, def})
 
 		// trailing commas are enforced.
 		p.expect(",")
@@ -186,6 +219,43 @@ func (p *astParser) parseStructureType() *TypeNode {
 	}
 }
 
+// parseReceiver parses the receiver of a method if there
+// is one, i.e. self, *self, or mut *self
+func (p *astParser) parseReceiver() *ReceiverNode {
+	start := p.pos
+
+	var toks []string
+	for pos := p.pos; pos < len(p.toks); pos++ {
+		tok := p.toks[pos]
+		toks = append(toks, tok.Value)
+		if !tok.Matches(mut, "*") {
+			break
+		}
+	}
+
+	var kind ReceiverKind
+	switch strings.Join(toks, " ") {
+	case "self":
+		kind = ValueReceiver
+	case "* self":
+		kind = PointerReceiver
+	case "mut * self":
+		kind = MutablePointerReceiver
+	case "mut self":
+		p.error(api.NewParseError("receiver, a mutable receiver must be a pointer: mut *self", start, p.pos+2))
+		return nil
+	default:
+		return nil
+	}
+
+	for range toks[1:] {
+		p.consume()
+	}
+	name := p.expect(self)
+
+	return &ReceiverNode{kind, name}
+}
+
 func (p *astParser) parseFunctionPrototypeDeclaration() *FunctionPrototypeDeclaration {
 	start := p.pos
 
@@ -195,6 +265,12 @@ func (p *astParser) parseFunctionPrototypeDeclaration() *FunctionPrototypeDeclar
 	args := []*NamedType{}
 
 	p.expect("(")
+
+	receiver := p.parseReceiver()
+	if receiver != nil && p.next().Matches(",") {
+		p.consume()
+	}
+
 	for idx := 0; p.hasNext(); idx++ {
 		if p.next().Matches(")") {
 			break
@@ -223,7 +299,7 @@ func (p *astParser) parseFunctionPrototypeDeclaration() *FunctionPrototypeDeclar
 			p.error(api.NewParseError("type after pointer", start, p.pos))
 		}
 
-		args = append(args, &NamedType{mutable, name, owned, typ})
+		args = append(args, &NamedType{mutable, name, owned, typ, nil})
 	}
 	p.expect(")")
 
@@ -239,6 +315,7 @@ func (p *astParser) parseFunctionPrototypeDeclaration() *FunctionPrototypeDeclar
 
 	return &FunctionPrototypeDeclaration{
 		Name:      name,
+		Receiver:  receiver,
 		Arguments: args,
 
 		// could be nil!
@@ -340,7 +417,7 @@ func (p *astParser) parseReturn() *ParseTreeNode {
 		return nil
 	}
 	start := p.pos
-	p.expect("return")
+	keyword := p.expect("return")
 
 	var res *ExpressionNode
 	if !p.next().Matches(";") {
@@ -354,21 +431,34 @@ func (p *astParser) parseReturn() *ParseTreeNode {
 		Kind: ReturnStatement,
 		ReturnStatementNode: &ReturnStatementNode{
 			Value: res,
+			Span:  keyword.Span,
 		},
 	}
 }
 
+// parseLoopTarget parses the optional label
+// of the loop that a break or next refers to.
+func (p *astParser) parseLoopTarget() *Token {
+	if p.hasNext() && p.next().Kind == Identifier {
+		label := p.consume()
+		return &label
+	}
+	return nil
+}
+
 func (p *astParser) parseNext() *ParseTreeNode {
 	p.expect("next")
 	return &ParseTreeNode{
-		Kind: NextStatement,
+		Kind:     NextStatement,
+		NextNode: &NextNode{p.parseLoopTarget()},
 	}
 }
 
 func (p *astParser) parseBreak() *ParseTreeNode {
 	p.expect("break")
 	return &ParseTreeNode{
-		Kind: BreakStatement,
+		Kind:      BreakStatement,
+		BreakNode: &BreakNode{p.parseLoopTarget()},
 	}
 }
 
@@ -615,8 +705,35 @@ func (p *astParser) parseLoop() *ParseTreeNode {
 	return nil
 }
 
+// parseLabeledLoop parses a loop with a label
+// that break and next can refer to, e.g.
+// outer: loop { ... break outer; }
+func (p *astParser) parseLabeledLoop() *ParseTreeNode {
+	start := p.pos
+
+	label := p.expectKind(Identifier)
+	p.expect(":")
+
+	var node *ParseTreeNode
+	switch curr := p.next(); {
+	case curr.Matches(loop):
+		if node = p.parseLoop(); node != nil {
+			node.LoopNode.Label = &label
+		}
+	case curr.Matches(while):
+		if node = p.parseWhileLoop(); node != nil {
+			node.WhileLoopNode.Label = &label
+		}
+	default:
+		p.error(api.NewParseError("loop after label", start, p.pos))
+	}
+	return node
+}
+
 func (p *astParser) parseStatement() *ParseTreeNode {
 	switch curr := p.next(); {
+	case curr.Kind == Identifier && p.pos+1 < len(p.toks) && p.peek(1).Matches(":"):
+		return p.parseLabeledLoop()
 	case curr.Matches(iff):
 		return p.parseIfElseChain()
 	case curr.Matches(loop):
@@ -630,6 +747,8 @@ func (p *astParser) parseStatement() *ParseTreeNode {
 			Kind:      BlockStatement,
 			BlockNode: p.parseStatBlock(),
 		}
+	case curr.Matches("#"):
+		return p.parseDirectiveNode(false)
 	}
 
 	stat := p.parseSemicolonStatement()
@@ -708,7 +827,10 @@ func (p *astParser) parseUnaryExpr() *ExpressionNode {
 	start := p.pos
 
 	op := p.consume()
-	right := p.parseLeft()
+
+	// we parse the operand here rather than the
+	// left so that -x as f32 is (-x) as f32.
+	right := p.parseOperandChain()
 	if right == nil {
 		p.error(api.NewParseError("unary expression", start, p.pos))
 	}
@@ -795,6 +917,16 @@ func (p *astParser) parseOperand() *ExpressionNode {
 			},
 		}
 	case Identifier:
+		if curr.Matches(tru, fals) {
+			return &ExpressionNode{
+				Kind: ConstantExpression,
+				ConstantNode: &ConstantNode{
+					Kind:                BooleanConstant,
+					BooleanConstantNode: &BooleanConstantNode{curr.Matches(tru)},
+				},
+			}
+		}
+
 		return &ExpressionNode{
 			Kind: ConstantExpression,
 			ConstantNode: &ConstantNode{
@@ -902,18 +1034,54 @@ func (p *astParser) parseCall(left *ExpressionNode) *ExpressionNode {
 	}
 }
 
+func (p *astParser) parseSlice(left *ExpressionNode, open Token, low *ExpressionNode) *ExpressionNode {
+	p.expect("..")
+
+	var high *ExpressionNode
+	if !p.next().Matches("]") {
+		high = p.parseExpression()
+	}
+	close := p.expect("]")
+
+	return &ExpressionNode{
+		Kind: SliceExpression,
+		SliceExpressionNode: &SliceExpressionNode{
+			left, low, high,
+			spanBetween(open, close),
+		},
+	}
+}
+
+// spanBetween returns the span from the start of the first
+// token to the end of the last, or nil if either is a BadToken.
+func spanBetween(first, last Token) []int {
+	if len(first.Span) == 0 || len(last.Span) == 0 {
+		return nil
+	}
+	return []int{first.Span[0], last.Span[1]}
+}
+
 func (p *astParser) parseIndex(left *ExpressionNode) *ExpressionNode {
 	start := p.pos
-	p.expect("[")
+	open := p.expect("[")
+	if p.next().Matches("..") {
+		return p.parseSlice(left, open, nil)
+	}
+
 	val := p.parseExpression()
 	if val == nil {
 		p.error(api.NewParseError("expression in array index", start, p.pos))
 	}
-	p.expect("]")
+	if p.next().Matches("..") {
+		return p.parseSlice(left, open, val)
+	}
+
+	close := p.expect("]")
 	return &ExpressionNode{
 		Kind: IndexExpression,
 		IndexExpressionNode: &IndexExpressionNode{
 			left, val,
+			spanBetween(open, close),
 		},
 	}
 }
@@ -935,6 +1103,7 @@ func (p *astParser) parseInitializer() *ExpressionNode {
 
 	p.expect("{")
 	var els []*ExpressionNode
+	var names []Token
 	for p.hasNext() {
 		if p.next().Matches(",") {
 			p.consume()
@@ -944,6 +1113,17 @@ func (p *astParser) parseInitializer() *ExpressionNode {
 			break
 		}
 
+		// the values are either all named or all positional.
+		start := p.pos
+		named := p.next().Kind == Identifier && p.pos+1 < len(p.toks) && p.peek(1).Matches(":")
+		if named {
+			names = append(names, p.expectKind(Identifier))
+			p.expect(":")
+		}
+		if named != (len(names) > 0) || (named && len(names) != len(els)+1) {
+			p.error(api.NewParseError("all fields named or all positional", start, p.pos))
+		}
+
 		expr := p.parseExpression()
 		if expr != nil {
 			els = append(els, expr)
@@ -956,6 +1136,7 @@ func (p *astParser) parseInitializer() *ExpressionNode {
 		InitializerExpressionNode: &InitializerExpressionNode{
 			Kind:   InitStructure,
 			LHand:  lhand,
+			Names:  names,
 			Values: els,
 		},
 	}
@@ -1000,21 +1181,62 @@ func (p *astParser) parsePrimaryExpr() *ExpressionNode {
 		return nil
 	}
 
-	switch curr := p.next(); {
-	case curr.Matches("["):
-		return p.parseIndex(left)
-	case curr.Matches("("):
-		return p.parseCall(left)
+	// indexes, slices and calls can be chained, e.g. a[1][2]
+	for p.hasNext() {
+		switch curr := p.next(); {
+		case curr.Matches("["):
+			left = p.parseIndex(left)
+		case curr.Matches("("):
+			left = p.parseCall(left)
+		default:
+			return left
+		}
 	}
+	return left
+}
+
+// parseCast parses any casts applied to the given
+// expression, e.g. x as i64 as f32
+func (p *astParser) parseCast(left *ExpressionNode) *ExpressionNode {
+	for p.hasNext() && p.next().Matches(as) {
+		start := p.pos
+		p.expect(as)
 
+		typ := p.parseTypeExpression()
+		if typ == nil {
+			p.error(api.NewParseError("type after cast", start, p.pos))
+			return nil
+		}
+
+		left = &ExpressionNode{
+			Kind: CastExpression,
+			CastExpressionNode: &CastExpressionNode{
+				Value: left,
+				Type:  typ,
+			},
+		}
+	}
 	return left
 }
 
+// parseOperandChain parses a primary expression followed
+// by any field accesses or method calls on it, e.g. a.b.c(d)
+func (p *astParser) parseOperandChain() *ExpressionNode {
+	expr := p.parsePrimaryExpr()
+	if expr != nil && p.hasNext() && p.next().Matches(".") {
+		return p.parseDotList(expr)
+	}
+	return expr
+}
+
 func (p *astParser) parseLeft() *ExpressionNode {
-	if expr := p.parsePrimaryExpr(); expr != nil {
-		return expr
+	if expr := p.parseOperandChain(); expr != nil {
+		return p.parseCast(expr)
+	}
+	if expr := p.parseUnaryExpr(); expr != nil {
+		return p.parseCast(expr)
 	}
-	return p.parseUnaryExpr()
+	return nil
 }
 
 var opPrec = map[string]int{
@@ -1062,7 +1284,7 @@ func (p *astParser) parsePrec(lastPrec int, left *ExpressionNode) *ExpressionNod
 		}
 
 		op := p.consume()
-		right := p.parsePrimaryExpr()
+		right := p.parseLeft()
 		if right == nil {
 			return nil
 		}
@@ -1120,9 +1342,11 @@ func (p *astParser) parseDotList(left *ExpressionNode) *ExpressionNode {
 
 	list = append(list, left)
 
+	// each value in the list is only an operand, e.g.
+	// a.b + c is (a.b) + c rather than a.(b + c)
 	for p.hasNext() && p.next().Matches(".") {
 		p.expect(".")
-		val := p.parseExpression()
+		val := p.parsePrimaryExpr()
 		if val == nil {
 			p.error(api.NewParseError("expression in dot-list", start, p.pos))
 		}
@@ -1178,13 +1402,24 @@ func (p *astParser) parseExpressionStatement() *ParseTreeNode {
 	return nil
 }
 
-func (p *astParser) skipDirective() {
-	p.expect("#")
-	p.expect("{")
-	for p.hasNext() && !p.next().Matches("}") {
-		p.consume()
+// parseDirectiveNode parses a directive group, e.g.
+// #{include("<stdio.h>"), link("-lm")}
+// using the directive parser over the same token stream.
+func (p *astParser) parseDirectiveNode(topLevel bool) *ParseTreeNode {
+	target := p.directiveTarget(p.pos, topLevel)
+
+	dp := &directiveParser{parser{p.toks, p.pos, []api.CompilerError{}}}
+	dirs := dp.parseDirective(target, topLevel)
+
+	p.pos = dp.pos
+	for _, err := range dp.errors {
+		p.error(err)
+	}
+
+	return &ParseTreeNode{
+		Kind:       DirectiveStatement,
+		Directives: dirs,
 	}
-	p.expect("}")
 }
 
 // parseNode returns whether the node was parsed
@@ -1200,8 +1435,7 @@ func (p *astParser) parseNode() (*ParseTreeNode, bool) {
 
 	switch curr := p.next(); {
 	case curr.Matches("#"):
-		p.skipDirective()
-		return nil, true
+		return p.parseDirectiveNode(true), true
 
 	case curr.Matches(trait):
 		res.TraitDeclaration = p.parseTraitDeclaration()
@@ -39,4 +39,194 @@ func TestLetStatementParses(t *testing.T) {
 
 	assert.NotEmpty(t, nodes)
 	assert.Empty(t, errs)
-}
\ No newline at end of file
+}
+
+func TestIncludeDirectiveParses(t *testing.T) {
+	input, _ := TokenizeInput(`#{include("<stdio.h>")} #{include("foo.h")}`, true)
+	nodes, errs := ParseTokenStream(input)
+	assert.Empty(t, errs)
+	assert.Len(t, nodes, 2)
+
+	system := nodes[0].Directives[0].IncludeDirective
+	assert.Equal(t, "stdio.h", system.Path)
+	assert.True(t, system.System)
+
+	local := nodes[1].Directives[0].IncludeDirective
+	assert.Equal(t, "foo.h", local.Path)
+	assert.False(t, local.System)
+}
+
+func TestCfgDirectiveParses(t *testing.T) {
+	input, _ := TokenizeInput(`#{cfg(debug), cfg(os = "linux")}`, true)
+	nodes, errs := ParseTokenStream(input)
+	assert.Empty(t, errs)
+	assert.Len(t, nodes, 1)
+
+	dirs := nodes[0].Directives
+	assert.Len(t, dirs, 2)
+	assert.Equal(t, "debug", dirs[0].CfgDirective.Key)
+	assert.Equal(t, "", dirs[0].CfgDirective.Value)
+	assert.Equal(t, "os", dirs[1].CfgDirective.Key)
+	assert.Equal(t, "linux", dirs[1].CfgDirective.Value)
+}
+
+func TestUnknownDirectiveErrors(t *testing.T) {
+	input, _ := TokenizeInput(`#{foo} fn main() {}`, true)
+	_, errs := ParseTokenStream(input)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, 10, errs[0].ErrorCode)
+}
+
+func TestDirectiveTargetErrors(t *testing.T) {
+	input, _ := TokenizeInput(`#{no_mangle} type Foo = struct { a int, };`, true)
+	_, errs := ParseTokenStream(input)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, 11, errs[0].ErrorCode)
+}
+
+func TestDirectiveArgumentErrors(t *testing.T) {
+	input, _ := TokenizeInput(`#{align("eight")} type Foo = struct { a int, };`, true)
+	_, errs := ParseTokenStream(input)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, 1, errs[0].ErrorCode)
+}
+
+func TestRegisterDirective(t *testing.T) {
+	err := RegisterDirective(DirectiveSpec{
+		Name: "test_inline",
+		Args: []ArgumentSchema{
+			{Name: "hint", Kinds: []ArgumentKind{IdentifierArgument}},
+			{Name: "depth", Kinds: []ArgumentKind{IntegerArgument}, Optional: true},
+		},
+		Targets: []DirectiveTarget{FunctionTarget},
+	})
+	assert.NoError(t, err)
+	t.Cleanup(func() { unregisterDirective("test_inline") })
+	assert.Error(t, RegisterDirective(DirectiveSpec{Name: "test_inline"}))
+
+	input, _ := TokenizeInput(`#{test_inline(depth = 2, always)} fn foo() {}`, true)
+	nodes, errs := ParseTokenStream(input)
+	assert.Empty(t, errs)
+
+	dir := nodes[0].Directives[0]
+	assert.Equal(t, DirectiveKind("test_inline"), dir.Kind)
+	assert.Equal(t, "always", dir.Arguments[0].Value)
+	assert.Equal(t, "2", dir.Arguments[1].Value)
+}
+
+func TestCastExpressionParses(t *testing.T) {
+	input, _ := TokenizeInput(`let x = -a as f32 * 2;`, true)
+	nodes, errs := ParseTokenStream(input)
+	assert.Empty(t, errs)
+
+	bin := nodes[0].LetStatementNode.Value
+	assert.Equal(t, ExpressionType(BinaryExpression), bin.Kind)
+
+	cast := bin.BinaryExpressionNode.LHand
+	assert.Equal(t, ExpressionType(CastExpression), cast.Kind)
+	assert.Equal(t, ExpressionType(UnaryExpression), cast.CastExpressionNode.Value.Kind)
+}
+
+func TestMethodReceiverParses(t *testing.T) {
+	input, _ := TokenizeInput(`impl Foo { fn a(self) {} fn b(*self, x int) {} fn c(mut *self) {} fn d(x int) {} }`, true)
+	nodes, errs := ParseTokenStream(input)
+	assert.Empty(t, errs)
+
+	fns := nodes[0].ImplDeclaration.Functions
+	assert.Equal(t, ReceiverKind(ValueReceiver), fns[0].Receiver.Kind)
+	assert.Equal(t, ReceiverKind(PointerReceiver), fns[1].Receiver.Kind)
+	assert.Len(t, fns[1].Arguments, 1)
+	assert.Equal(t, ReceiverKind(MutablePointerReceiver), fns[2].Receiver.Kind)
+	assert.Nil(t, fns[3].Receiver)
+}
+
+func TestDotListPrecedence(t *testing.T) {
+	input, _ := TokenizeInput(`a.b + c.d(e);`, true)
+	nodes, errs := ParseTokenStream(input)
+	assert.Empty(t, errs)
+
+	expr := nodes[0].ExpressionStatementNode
+	assert.Equal(t, ExpressionType(BinaryExpression), expr.Kind)
+	assert.Equal(t, ExpressionType(PathExpression), expr.BinaryExpressionNode.LHand.Kind)
+	assert.Equal(t, ExpressionType(PathExpression), expr.BinaryExpressionNode.RHand.Kind)
+}
+
+func TestSliceParses(t *testing.T) {
+	input, _ := TokenizeInput(`let s []i32 = a[1..n]; let t = s[..2][0];`, true)
+	nodes, errs := ParseTokenStream(input)
+	assert.Empty(t, errs)
+
+	let := nodes[0].LetStatementNode
+	assert.Equal(t, TypeNodeType(SliceType), let.Type.TypeExpressionNode.Kind)
+
+	slice := let.Value.SliceExpressionNode
+	assert.NotNil(t, slice)
+	assert.Equal(t, ConstantNodeType(IntegerConstant), slice.Low.ConstantNode.Kind)
+	assert.NotNil(t, slice.High)
+
+	index := nodes[1].LetStatementNode.Value
+	assert.Equal(t, ExpressionType(IndexExpression), index.Kind)
+	assert.Nil(t, index.IndexExpressionNode.Left.SliceExpressionNode.Low)
+}
+
+func TestBooleanLiteralParses(t *testing.T) {
+	input, _ := TokenizeInput(`let x = true; let y = false;`, true)
+	nodes, errs := ParseTokenStream(input)
+	assert.Empty(t, errs)
+
+	x := nodes[0].LetStatementNode.Value.ConstantNode
+	assert.Equal(t, ConstantNodeType(BooleanConstant), x.Kind)
+	assert.True(t, x.BooleanConstantNode.Value)
+	assert.False(t, nodes[1].LetStatementNode.Value.ConstantNode.BooleanConstantNode.Value)
+}
+
+func TestLabeledLoopParses(t *testing.T) {
+	input, _ := TokenizeInput(`fn main() { outer: loop { while x { break outer; next; } } }`, true)
+	nodes, errs := ParseTokenStream(input)
+	assert.Empty(t, errs)
+
+	loop := nodes[0].FunctionDeclaration.Body.Statements[0].LoopNode
+	assert.Equal(t, "outer", loop.Label.Value)
+
+	while := loop.Block.Statements[0].WhileLoopNode
+	assert.Nil(t, while.Label)
+	assert.Equal(t, "outer", while.Block.Statements[0].BreakNode.Label.Value)
+	assert.Nil(t, while.Block.Statements[1].NextNode.Label)
+}
+
+func TestNamedInitializerParses(t *testing.T) {
+	input, _ := TokenizeInput(`let p = :Person{name: "x", age: 3}; let q = :Person{"y", 4};`, true)
+	nodes, errs := ParseTokenStream(input)
+	assert.Empty(t, errs)
+
+	init := nodes[0].LetStatementNode.Value.InitializerExpressionNode
+	assert.Equal(t, "Person", init.LHand.Value)
+	assert.Len(t, init.Values, 2)
+	assert.Equal(t, "name", init.Names[0].Value)
+	assert.Equal(t, "age", init.Names[1].Value)
+
+	assert.Nil(t, nodes[1].LetStatementNode.Value.InitializerExpressionNode.Names)
+
+	input, _ = TokenizeInput(`let r = :Person{name: "x", 3};`, true)
+	_, errs = ParseTokenStream(input)
+	assert.NotEmpty(t, errs)
+}
+
+func TestFieldDefaultParses(t *testing.T) {
+	input, _ := TokenizeInput(`type Config = struct { retries int = 3, name str, };`, true)
+	nodes, errs := ParseTokenStream(input)
+	assert.Empty(t, errs)
+
+	fields := nodes[0].TypeAliasNode.Type.TypeExpressionNode.StructureTypeNode.Fields
+	assert.Equal(t, "3", fields[0].Default.ConstantNode.IntegerConstantNode.Value.String())
+	assert.Nil(t, fields[1].Default)
+}
+
+// unregisterDirective removes a directive registered by a test
+// so that the test can be run more than once.
+func unregisterDirective(name string) {
+	directiveLock.Lock()
+	defer directiveLock.Unlock()
+
+	delete(directiveRegistry, name)
+}
@@ -24,6 +24,8 @@ const (
 	LabelStatement = "labelNode"
 	JumpStatement  = "jumpNode"
 
+	DirectiveStatement = "directiveNode"
+
 	TypeAliasStatement     = "typeAliasDecl"
 	TraitDeclStatement     = "traitDecl"
 	ImplDeclStatement      = "implDecl"
@@ -38,6 +40,10 @@ type NamedType struct {
 	Name    Token           `json:"name"`
 	Owned   bool            `json:"owned"`
 	Type    *ExpressionNode `json:"type"`
+
+	// Default is the value of a structure field
+	// when an initializer doesn't give one.
+	Default *ExpressionNode `json:"default,omitempty"`
 }
 
 // BlockNode ...
@@ -52,10 +58,27 @@ type ElseIfNode struct {
 	Block *BlockNode      `json:"block"`
 }
 
+// ReceiverKind is how a method takes its receiver.
+type ReceiverKind string
+
+const (
+	ValueReceiver          ReceiverKind = "self"
+	PointerReceiver                     = "*self"
+	MutablePointerReceiver              = "mut *self"
+)
+
+// ReceiverNode ...
+// [ "mut" ] [ "*" ] "self"
+type ReceiverNode struct {
+	Kind ReceiverKind `json:"kind"`
+	Name Token        `json:"name"`
+}
+
 // FunctionPrototypeDeclaration ...
-// "func" iden "(" args ")"
+// "func" iden "(" [ receiver ] args ")"
 type FunctionPrototypeDeclaration struct {
 	Name       Token           `json:"name"`
+	Receiver   *ReceiverNode   `json:"receiver,omitempty"`
 	Arguments  []*NamedType    `json:"arguments"`
 	ReturnType *ExpressionNode `json:"return_type"`
 }
@@ -97,6 +120,9 @@ type MutableStatementNode struct {
 // ReturnStatementNode ...
 type ReturnStatementNode struct {
 	Value *ExpressionNode `json:"value"`
+
+	// Span is the position of the return keyword.
+	Span []int `json:"span"`
 }
 
 // CONSTR
@@ -106,11 +132,25 @@ type WhileLoopNode struct {
 	Cond  *ExpressionNode `json:"cond"`
 	Post  *ExpressionNode `json:"post,omitempty"`
 	Block *BlockNode      `json:"block"`
+	Label *Token          `json:"label,omitempty"`
 }
 
 // LoopNode ...
 type LoopNode struct {
 	Block *BlockNode `json:"block"`
+	Label *Token     `json:"label,omitempty"`
+}
+
+// BreakNode ...
+// "break" [label]
+type BreakNode struct {
+	Label *Token `json:"label,omitempty"`
+}
+
+// NextNode ...
+// "next" [label]
+type NextNode struct {
+	Label *Token `json:"label,omitempty"`
 }
 
 // IfNode ...
@@ -183,11 +223,16 @@ type ParseTreeNode struct {
 	BlockNode     *BlockNode     `json:"blockNode,omitempty"`
 	IfNode        *IfNode        `json:"ifNode,omitempty"`
 	DeferNode     *DeferNode     `json:"deferNode,omitempty"`
+	BreakNode     *BreakNode     `json:"breakNode,omitempty"`
+	NextNode      *NextNode      `json:"nextNode,omitempty"`
 
 	// JUMP STUFF
 	LabelNode *LabelNode `json:"labelNode,omitempty"`
 	JumpNode  *JumpNode  `json:"jumpNode,omitempty"`
 
+	// #{...}
+	Directives []*Directive `json:"directives,omitempty"`
+
 	// DECL
 
 	TraitDeclaration *TraitDeclaration `json:"traitDecl,omitempty"`
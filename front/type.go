@@ -8,6 +8,7 @@ const (
 	UnresolvedType TypeNodeType = "unresolvedType"
 	PointerType                 = "pointerType"
 	ArrayType                   = "arrayType"
+	SliceType                   = "sliceType"
 	TupleType                   = "tupleType"
 	StructureType               = "structType"
 )
@@ -36,6 +37,12 @@ type ArrayTypeNode struct {
 	Size *ExpressionNode
 }
 
+// SliceTypeNode ...
+// []TypeNode
+type SliceTypeNode struct {
+	Base *ExpressionNode
+}
+
 // StructureTypeNode ...
 // "struct" iden { ... }
 type StructureTypeNode struct {
@@ -51,5 +58,6 @@ type TypeNode struct {
 	UnresolvedTypeNode *UnresolvedTypeNode `json:"unresolvedType,omitempty"`
 	PointerTypeNode    *PointerTypeNode    `json:"pointerType,omitempty"`
 	ArrayTypeNode      *ArrayTypeNode      `json:"arrayType,omitempty"`
+	SliceTypeNode      *SliceTypeNode      `json:"sliceType,omitempty"`
 	StructureTypeNode  *StructureTypeNode  `json:"structType,omitempty"`
 }
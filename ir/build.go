@@ -9,14 +9,73 @@ import (
 type builder struct {
 	mod    *Module
 	errors []api.CompilerError
+
+	// cfg is the set of keys that #{cfg(...)}
+	// directives are checked against.
+	cfg map[string]string
 }
 
 func (b *builder) error(err api.CompilerError) {
 	b.errors = append(b.errors, err)
 }
 
-func newBuilder(mod *Module) *builder {
-	return &builder{mod, []api.CompilerError{}}
+func newBuilder(mod *Module, cfg map[string]string) *builder {
+	return &builder{mod, []api.CompilerError{}, cfg}
+}
+
+// cfgEnabled checks the cfg directives in the given directive
+// group against the builders cfg keys. all of the cfg conditions
+// in the group must hold for it to be enabled.
+func (b *builder) cfgEnabled(dirs []*front.Directive) bool {
+	for _, dir := range dirs {
+		if dir.Kind != front.Cfg {
+			continue
+		}
+
+		cfg := dir.CfgDirective
+		val, ok := b.cfg[cfg.Key]
+		if !ok {
+			return false
+		}
+
+		// #{cfg(key = "value")}
+		if cfg.Value != "" && cfg.Value != val {
+			return false
+		}
+	}
+	return true
+}
+
+// filterCfg removes any nodes that have been disabled by
+// a #{cfg(...)} directive. a disabled directive group will
+// remove itself as well as the declaration or statement
+// that follows it.
+func (b *builder) filterCfg(nodes []*front.ParseTreeNode) []*front.ParseTreeNode {
+	res := []*front.ParseTreeNode{}
+
+	skip := false
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+
+		if node.Kind == front.DirectiveStatement {
+			if !b.cfgEnabled(node.Directives) {
+				skip = true
+			}
+			if !skip {
+				res = append(res, node)
+			}
+			continue
+		}
+
+		if !skip {
+			res = append(res, node)
+		}
+		skip = false
+	}
+
+	return res
 }
 
 func (b *builder) buildUnresolvedType(u *front.UnresolvedTypeNode) *Type {
@@ -41,11 +100,32 @@ func (b *builder) buildArrayType(p *front.ArrayTypeNode) *Type {
 	}
 }
 
+func (b *builder) buildSliceType(s *front.SliceTypeNode) *Type {
+	base := b.buildType(s.Base)
+	return &Type{
+		Kind:  SliceKind,
+		Slice: NewSliceType(base),
+	}
+}
+
 func (b *builder) buildStructureType(struc *front.StructureTypeNode) *Type {
 	fields := newTypeDict()
 	for _, sf := range struc.Fields {
 		typ := b.buildType(sf.Type)
-		fields.Add(NewLocal(sf.Name, typ, sf.Owned))
+		field := NewLocal(sf.Name, typ, sf.Owned)
+		field.SetMutable(sf.Mutable)
+
+		// defaults are filled into initializers
+		// so they must be constant, like globals.
+		if sf.Default != nil {
+			def := b.buildExpr(sf.Default)
+			if IsConstant(def) {
+				field.SetValue(EvalConstant(def))
+			} else {
+				b.error(api.NewNonConstantDefault(sf.Name.Value, sf.Name.Span...))
+			}
+		}
+		fields.Add(field)
 	}
 	return &Type{
 		Kind:      StructKind,
@@ -68,12 +148,19 @@ func (b *builder) buildTypeExpr(te *front.TypeNode) *Type {
 	switch te.Kind {
 	case front.ArrayType:
 		return b.buildArrayType(te.ArrayTypeNode)
+	case front.SliceType:
+		return b.buildSliceType(te.SliceTypeNode)
 	case front.UnresolvedType:
 		return b.buildUnresolvedType(te.UnresolvedTypeNode)
 	case front.TupleType:
 		return b.buildTupleType(te.TupleTypeNode)
 	case front.StructureType:
 		return b.buildStructureType(te.StructureTypeNode)
+	case front.PointerType:
+		return &Type{
+			Kind:    PointerKind,
+			Pointer: b.buildPointerType(te.PointerTypeNode),
+		}
 	default:
 		panic(fmt.Sprintf("unimplemented type_expr %s", te.Kind))
 	}
@@ -91,7 +178,9 @@ func (b *builder) buildConstType(node *front.ConstantNode) *Type {
 		resp.Kind = FloatKind
 
 	case front.VariableReference:
-		resp.Kind = ReferenceKind
+		// a type name, e.g. i32 or Person
+		name := node.VariableReferenceNode.Name.Value
+		return b.buildUnresolvedType(&front.UnresolvedTypeNode{Name: name})
 
 	default:
 		panic(fmt.Sprintf("unimplemented buildConstType %s", node.Kind))
@@ -170,7 +259,23 @@ func (b *builder) buildIndexExpression(i *front.IndexExpressionNode) *Value {
 	sub := b.buildExpr(i.Value)
 	return &Value{
 		Kind:  IndexValue,
-		Index: NewIndex(left, sub),
+		Index: NewIndex(left, sub, i.Span),
+	}
+}
+
+func (b *builder) buildSliceExpression(s *front.SliceExpressionNode) *Value {
+	left := b.buildExpr(s.Left)
+
+	var low, high *Value
+	if s.Low != nil {
+		low = b.buildExpr(s.Low)
+	}
+	if s.High != nil {
+		high = b.buildExpr(s.High)
+	}
+	return &Value{
+		Kind:  SliceValue,
+		Slice: NewSlice(left, low, high, s.Span),
 	}
 }
 
@@ -196,6 +301,28 @@ func (b *builder) buildPathExpression(p *front.PathExpressionNode) *Value {
 	}
 }
 
+// foldCalls rewrites the calls in the given path into
+// method calls on the values before them, for example
+// a.b(x).c is parsed as Path[a, Call(b, x), c] and becomes
+// Path[Call(Path[a, b], x), c]
+func foldCalls(values []*Value) *Value {
+	var curr []*Value
+	for _, val := range values {
+		if val.Kind == CallValue && val.Call.Left.Kind == IdentifierValue && len(curr) > 0 {
+			recv := append(curr, val.Call.Left)
+			val.Call.Left = &Value{Kind: PathValue, Path: NewPath(recv)}
+			curr = []*Value{val}
+			continue
+		}
+		curr = append(curr, val)
+	}
+
+	if len(curr) == 1 {
+		return curr[0]
+	}
+	return &Value{Kind: PathValue, Path: NewPath(curr)}
+}
+
 func (b *builder) buildConst(e *front.ConstantNode) *Value {
 	res := &Value{}
 
@@ -212,6 +339,9 @@ func (b *builder) buildConst(e *front.ConstantNode) *Value {
 	case front.CharacterConstant:
 		res.CharacterValue = NewCharacterValue(e.CharacterConstantNode.Value)
 		res.Kind = CharacterValueValue
+	case front.BooleanConstant:
+		res.BooleanValue = NewBooleanValue(e.BooleanConstantNode.Value)
+		res.Kind = BooleanValueValue
 	case front.VariableReference:
 		res.Kind = IdentifierValue
 		res.Identifier = NewIdentifier(e.VariableReferenceNode.Name)
@@ -221,6 +351,15 @@ func (b *builder) buildConst(e *front.ConstantNode) *Value {
 	return res
 }
 
+func (b *builder) buildCast(c *front.CastExpressionNode) *Value {
+	val := b.buildExpr(c.Value)
+	typ := b.buildType(c.Type)
+	return &Value{
+		Kind: CastValue,
+		Cast: NewCast(val, typ),
+	}
+}
+
 func (b *builder) buildLambda(expr *front.LambdaExpressionNode) *Value {
 	// generate a new temp function
 	// return a pointer to this new temp func?
@@ -253,9 +392,50 @@ func (b *builder) buildInitializerList(init *front.InitializerExpressionNode) *V
 		iden = NewIdentifier(init.LHand)
 	}
 
+	res := NewInit(init.Kind, iden, init.Names, vals)
+	if st, ok := b.mod.Structures[init.LHand.Value]; ok && init.Kind == front.InitStructure {
+		fillDefaults(res, st)
+	}
+
 	return &Value{
 		Kind: InitValue,
-		Init: NewInit(init.Kind, iden, vals),
+		Init: res,
+	}
+}
+
+// fillDefaults adds a copy of the default value of any field
+// that the initializer leaves out. positional initializers are
+// filled in order up to the first field without a default.
+func fillDefaults(init *Init, st *Structure) {
+	fields := st.Fields
+
+	if init.Names == nil {
+		if len(init.Values) >= len(fields.Order) {
+			return
+		}
+		for _, name := range fields.Order[len(init.Values):] {
+			field := fields.Get(name.Value)
+			if field.Val == nil {
+				return
+			}
+			init.Values = append(init.Values, CloneValue(field.Val))
+			init.Defaults++
+		}
+		return
+	}
+
+	given := map[string]bool{}
+	for _, name := range init.Names {
+		given[name.Value] = true
+	}
+
+	for _, name := range fields.Order {
+		field := fields.Get(name.Value)
+		if !given[name.Value] && field.Val != nil {
+			init.Names = append(init.Names, name)
+			init.Values = append(init.Values, CloneValue(field.Val))
+			init.Defaults++
+		}
 	}
 }
 
@@ -300,7 +480,7 @@ func (b *builder) buildExpr(expr *front.ExpressionNode) *Value {
 
 				// set the last value to be equal to the binary expr lhand
 				pat.Values[len(pat.Values)-1] = bin.LHand
-				bin.LHand = p
+				bin.LHand = foldCalls(pat.Values)
 
 				return &Value{
 					Kind:             BinaryExpressionValue,
@@ -308,15 +488,28 @@ func (b *builder) buildExpr(expr *front.ExpressionNode) *Value {
 				}
 			}
 
-			return p
+			// same for assignments, e.g. self.x = 5
+			if last.Kind == AssignValue {
+				assign := last.Assign
+				pat.Values[len(pat.Values)-1] = assign.LHand
+				assign.LHand = foldCalls(pat.Values)
+				return last
+			}
+
+			return foldCalls(pat.Values)
 		}
 
 	case front.IndexExpression:
 		return b.buildIndexExpression(expr.IndexExpressionNode)
+	case front.SliceExpression:
+		return b.buildSliceExpression(expr.SliceExpressionNode)
 
 	case front.InitializerExpression:
 		return b.buildInitializerList(expr.InitializerExpressionNode)
 
+	case front.CastExpression:
+		return b.buildCast(expr.CastExpressionNode)
+
 	default:
 		panic(fmt.Sprintf("unhandled expr %s", expr.Kind))
 	}
@@ -380,6 +573,7 @@ func (b *builder) buildReturnStat(ret *front.ReturnStatementNode) *Instruction {
 		val = b.buildExpr(ret.Value)
 	}
 	res := NewReturn(val)
+	res.Span = ret.Span
 	return &Instruction{
 		Kind:   ReturnInstr,
 		Return: res,
@@ -393,7 +587,7 @@ func (b *builder) buildWhileLoopStat(while *front.WhileLoopNode) *Instruction {
 		post = b.buildExpr(while.Post)
 	}
 	body := b.buildBlock(while.Block)
-	res := NewWhileLoop(cond, post, body)
+	res := NewWhileLoop(cond, post, body, while.Label)
 	return &Instruction{
 		Kind:      WhileLoopInstr,
 		WhileLoop: res,
@@ -402,7 +596,7 @@ func (b *builder) buildWhileLoopStat(while *front.WhileLoopNode) *Instruction {
 
 func (b *builder) buildLoopStat(loop *front.LoopNode) *Instruction {
 	body := b.buildBlock(loop.Block)
-	res := NewLoop(body)
+	res := NewLoop(body, loop.Label)
 	return &Instruction{
 		Kind: LoopInstr,
 		Loop: res,
@@ -410,9 +604,9 @@ func (b *builder) buildLoopStat(loop *front.LoopNode) *Instruction {
 }
 
 func (b *builder) buildBlock(block *front.BlockNode) *Block {
-	res := NewBlock()
+	res := NewBlock(b.mod.IDs)
 
-	for _, stat := range block.Statements {
+	for _, stat := range b.filterCfg(block.Statements) {
 		st := b.buildStat(stat)
 		if st == nil {
 			continue
@@ -421,12 +615,9 @@ func (b *builder) buildBlock(block *front.BlockNode) *Block {
 		switch st.Kind {
 
 		case DeferInstr:
-			// do we add this to the instructions list?
+			st.Defer.After = len(res.Instr)
 			res.PushDefer(st.Defer)
 
-		case ReturnInstr:
-			res.SetReturn(st)
-			fallthrough
 		default:
 			res.AddInstr(st)
 		}
@@ -500,9 +691,9 @@ func (b *builder) buildStat(stat *front.ParseTreeNode) *Instruction {
 		return b.buildReturnStat(stat.ReturnStatementNode)
 
 	case front.BreakStatement:
-		return &Instruction{Kind: BreakInstr, Break: NewBreak()}
+		return &Instruction{Kind: BreakInstr, Break: NewBreak(stat.BreakNode.Label)}
 	case front.NextStatement:
-		return &Instruction{Kind: NextInstr, Next: NewNext()}
+		return &Instruction{Kind: NextInstr, Next: NewNext(stat.NextNode.Label)}
 
 	case front.LoopStatement:
 		return b.buildLoopStat(stat.LoopNode)
@@ -540,6 +731,11 @@ func (b *builder) buildStat(stat *front.ParseTreeNode) *Instruction {
 			Label: NewLabel(stat.LabelNode.LabelName),
 		}
 
+	case front.DirectiveStatement:
+		// directives are handled by whatever
+		// they are applied to.
+		return nil
+
 	default:
 		panic(fmt.Sprintf("unimplemented stat! %s", stat.Kind))
 	}
@@ -558,13 +754,70 @@ func (b *builder) buildFunc(node *front.FunctionDeclaration) *Function {
 		ret = b.buildType(node.ReturnType)
 	}
 
-	fn := NewFunction(node.Name, params, ret)
-	fn.Body = b.buildBlock(node.Body)
+	return NewFunction(node.Name, params, ret, b.buildBlock(node.Body))
+}
+
+func (b *builder) buildMethod(parent front.Token, node *front.FunctionDeclaration) *Function {
+	fn := b.buildFunc(node)
+	if node.Receiver == nil {
+		return fn
+	}
+
+	recv := node.Receiver
+	fn.Receiver = NewReceiver(recv.Kind, parent.Value)
+
+	// the receiver is passed as the first param.
+	typ := &Type{Kind: ReferenceKind, Reference: NewReferenceType(parent.Value)}
+	if recv.Kind != front.ValueReceiver {
+		typ = &Type{Kind: PointerKind, Pointer: NewPointerType(typ)}
+	}
+	self := NewLocal(recv.Name, typ, false)
+	self.SetMutable(recv.Kind == front.MutablePointerReceiver)
+
+	params := newTypeDict()
+	params.Add(self)
+	for _, name := range fn.Param.Order {
+		params.Add(fn.Param.Get(name.Value))
+	}
+	fn.Param = params
+
 	return fn
 }
 
+func (b *builder) buildImpls(nodes []*front.ParseTreeNode) {
+	for _, node := range nodes {
+		if node.Kind != front.ImplDeclStatement {
+			continue
+		}
+
+		decl := node.ImplDeclaration
+
+		// impls with the same name are merged.
+		impl, ok := b.mod.GetImpl(decl.Name.Value)
+		if !ok {
+			impl = NewImpl(decl.Name)
+			b.mod.RegisterImpl(impl)
+		}
+
+		for _, fn := range decl.Functions {
+			method := b.buildMethod(decl.Name, fn)
+			if !impl.RegisterMethod(method) {
+				b.error(api.NewSymbolError(method.Name.Value, method.Name.Span...))
+			}
+		}
+	}
+}
+
 func (b *builder) buildTypeAlias(nt *front.TypeAliasNode) *Instruction {
 	typ := b.buildType(nt.Type)
+
+	// structures are named after their alias, e.g.
+	// type Person = struct { ... };
+	if typ.Kind == StructKind {
+		typ.Structure.Name = nt.Name
+		b.mod.RegisterStructure(typ.Structure)
+	}
+
 	return &Instruction{
 		Kind:               TypeAliasInstr,
 		TypeAliasStatement: NewTypeAlias(nt.Name, typ),
@@ -591,20 +844,110 @@ func (b *builder) buildFunctions(nodes []*front.ParseTreeNode) {
 	}
 }
 
+// buildGlobal builds a module level let or mut into
+// a local, globals must be initialised with a constant
+// value as they are emitted at file-scope.
+func (b *builder) buildGlobal(node *front.ParseTreeNode) *Instruction {
+	var name front.Token
+	var typ, val *front.ExpressionNode
+	var owned, mutable bool
+
+	switch node.Kind {
+	case front.LetStatement:
+		l := node.LetStatementNode
+		name, typ, val, owned = l.Name, l.Type, l.Value, l.Owned
+	case front.MutableStatement:
+		m := node.MutableStatementNode
+		name, typ, val, owned, mutable = m.Name, m.Type, m.Value, m.Owned, true
+	}
+
+	var value *Value
+	if val != nil {
+		value = b.buildExpr(val)
+	}
+
+	// constants must always have a value, mutable
+	// globals without one are zero initialised.
+	if (value == nil && !mutable) || (value != nil && !IsConstant(value)) {
+		b.error(api.NewNonConstantGlobal(name.Value, name.Span...))
+		return nil
+	}
+
+	var t *Type
+	if typ != nil {
+		t = b.buildType(typ)
+	} else if value != nil {
+		t = ConstantType(value)
+	}
+
+	local := NewLocal(name, t, owned)
+	local.SetValue(value)
+	local.SetMutable(mutable)
+	return &Instruction{
+		Kind:  LocalInstr,
+		Local: local,
+	}
+}
+
+func (b *builder) buildGlobals(nodes []*front.ParseTreeNode) {
+	for _, node := range nodes {
+		if node.Kind != front.LetStatement && node.Kind != front.MutableStatement {
+			continue
+		}
+		if global := b.buildGlobal(node); global != nil {
+			b.mod.Global.AddInstr(global)
+		}
+	}
+}
+
+// buildDirectives registers any module level directives,
+// i.e. includes and link flags, with the module.
+func (b *builder) buildDirectives(nodes []*front.ParseTreeNode) {
+	for _, node := range nodes {
+		if node.Kind != front.DirectiveStatement {
+			continue
+		}
+
+		for _, dir := range node.Directives {
+			switch dir.Kind {
+			case front.Include:
+				b.mod.RegisterInclude(dir.IncludeDirective)
+			case front.Link:
+				b.mod.RegisterLinkFlags(dir.LinkDirective.Flags...)
+			}
+		}
+	}
+}
+
 // buildTree takes the given set of nodes, and builds a module
 // from them.
 func (b *builder) buildTree(m *Module, nodes []*front.ParseTreeNode) {
+	nodes = b.filterCfg(nodes)
+
+	b.buildDirectives(nodes)
 	b.introduceNamedTypes(nodes)
+	b.buildGlobals(nodes)
 	b.buildFunctions(nodes)
+	b.buildImpls(nodes)
 }
 
-func Build(trees [][]*front.ParseTreeNode) (*Module, []api.CompilerError) {
+// Build builds a single module from the given parse trees. any
+// declarations or statements disabled by a #{cfg(...)} directive
+// under the given cfg keys are not included in the module.
+func Build(trees [][]*front.ParseTreeNode, cfg map[string]string) (*Module, []api.CompilerError) {
 	module := NewModule("main")
 
-	b := newBuilder(module)
+	b := newBuilder(module, cfg)
 	for _, tree := range trees {
-		fmt.Println(tree)
 		b.buildTree(module, tree)
 	}
+
+	// structures can be declared in any tree, so impls
+	// are only checked once everything has been built.
+	for _, u := range module.UnclaimedMethods() {
+		name := u.Method.Name
+		b.error(api.NewUnclaimedMethod(name.Value, u.Parent, name.Span...))
+	}
+
 	return module, b.errors
 }
@@ -0,0 +1,63 @@
+package ir
+
+import (
+	"testing"
+
+	"github.com/krug-lang/caasper/front"
+	"github.com/stretchr/testify/assert"
+)
+
+func buildModule(t *testing.T, src string) *Module {
+	tokens, errs := front.TokenizeInput(src, true)
+	assert.Empty(t, errs)
+	nodes, errs := front.ParseTokenStream(tokens)
+	assert.Empty(t, errs)
+	mod, errs := Build([][]*front.ParseTreeNode{nodes}, map[string]string{})
+	assert.Empty(t, errs)
+	return mod
+}
+
+func TestDefaultsAreEvaluated(t *testing.T) {
+	mod := buildModule(t, `type Point = struct {
+		x i32 = 2 + 3,
+		y f64 = 1.0 / 4.0,
+		z i32 = -1 * 3,
+	};`)
+
+	fields := mod.Structures["Point"].Fields
+	x, ok := IntegerLiteral(fields.Get("x").Val)
+	assert.True(t, ok)
+	assert.Equal(t, int64(5), x.RawValue.Int64())
+
+	y := fields.Get("y").Val
+	assert.EqualValues(t, FloatingValueValue, y.Kind)
+	assert.Equal(t, 0.25, y.FloatingValue.Value)
+
+	z, ok := IntegerLiteral(fields.Get("z").Val)
+	assert.True(t, ok)
+	assert.Equal(t, int64(-3), z.RawValue.Int64())
+}
+
+func TestDefaultsAreCopiedPerInit(t *testing.T) {
+	mod := buildModule(t, `type Point = struct { x i32, y i32 = 7, };
+	fn main() {
+		let a = :Point{1};
+		let b = :Point{x: 2};
+	}`)
+
+	var inits []*Init
+	Inspect(mod.Functions["main"].Body, func(n Node) bool {
+		if v, ok := n.(*Value); ok && v.Kind == InitValue {
+			inits = append(inits, v.Init)
+		}
+		return true
+	})
+
+	def := mod.Structures["Point"].Fields.Get("y").Val
+	if assert.Len(t, inits, 2) {
+		a, b := inits[0].Values[1], inits[1].Values[1]
+		assert.True(t, a != b)
+		assert.True(t, a != def && b != def)
+		assert.Equal(t, 1, inits[0].Defaults)
+	}
+}
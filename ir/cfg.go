@@ -0,0 +1,778 @@
+package ir
+
+import (
+	"fmt"
+
+	"github.com/krug-lang/caasper/api"
+	"github.com/krug-lang/caasper/front"
+)
+
+/*
+	the cfg is a lowering of a function into basic blocks,
+	each block is a run of straight line instructions ended
+	by a terminator that says where control goes next.
+
+	loops, ifs, breaks, nexts and jumps are all turned into
+	branches and jumps between blocks, and the defers that
+	run when a scope is left are copied into the blocks on
+	each path out of it, the same as the C backend does.
+
+	the instructions in the blocks are the ones from the
+	tree copied, one copy for each place the instruction is
+	lowered to, e.g. deferred code is lowered once for every
+	way out of its scope. the identifiers in the copies are
+	resolved to the locals they refer to while the scopes
+	are still known, see CFG.Refs, so that passes can tell
+	locals apart once the scopes are flattened.
+*/
+
+type TerminatorKind string
+
+const (
+	JumpTerm   TerminatorKind = "jump"
+	BranchTerm                = "branch"
+	ReturnTerm                = "return"
+)
+
+// Terminator ends a basic block. a jump goes to Target,
+// a branch goes to True if Cond holds and False if not,
+// and a return leaves the function with Val if it's set.
+type Terminator struct {
+	Kind   TerminatorKind
+	Target *BasicBlock
+	Cond   *Value
+	True   *BasicBlock
+	False  *BasicBlock
+	Val    *Value
+}
+
+// Succs returns the blocks that control can go to.
+func (t *Terminator) Succs() []*BasicBlock {
+	switch t.Kind {
+	case JumpTerm:
+		return []*BasicBlock{t.Target}
+	case BranchTerm:
+		return []*BasicBlock{t.True, t.False}
+	}
+	return nil
+}
+
+type BasicBlock struct {
+	ID    int
+	Instr []*Instruction
+	Term  *Terminator
+
+	Preds []*BasicBlock
+	Succs []*BasicBlock
+}
+
+// CFG is the control flow graph of a single function,
+// the entry block is always the first of the blocks.
+type CFG struct {
+	Func   *Function
+	Entry  *BasicBlock
+	Blocks []*BasicBlock
+
+	// Refs is the local that each identifier in the blocks
+	// refers to, globals and functions aren't included.
+	Refs map[*Identifier]*Local
+
+	idom     map[*BasicBlock]*BasicBlock
+	children map[*BasicBlock][]*BasicBlock
+	order    map[*BasicBlock]int
+
+	// ids is where the blocks copied into the
+	// cfg get their ids from.
+	ids *IDSource
+}
+
+// ReversePostorder returns the blocks that can be reached
+// from the entry, each block comes before its successors
+// unless the edge between them is the back edge of a loop.
+func (g *CFG) ReversePostorder() []*BasicBlock {
+	seen := map[*BasicBlock]bool{}
+	post := []*BasicBlock{}
+
+	var visit func(b *BasicBlock)
+	visit = func(b *BasicBlock) {
+		seen[b] = true
+		for _, succ := range b.Succs {
+			if !seen[succ] {
+				visit(succ)
+			}
+		}
+		post = append(post, b)
+	}
+	visit(g.Entry)
+
+	res := make([]*BasicBlock, len(post))
+	for i, b := range post {
+		res[len(post)-1-i] = b
+	}
+	return res
+}
+
+// Reachable returns whether control can get to the
+// given block from the entry of the function.
+func (g *CFG) Reachable(b *BasicBlock) bool {
+	_, ok := g.order[b]
+	return ok
+}
+
+// Idom returns the immediate dominator of the block, this
+// is nil for the entry and for any unreachable blocks.
+func (g *CFG) Idom(b *BasicBlock) *BasicBlock {
+	return g.idom[b]
+}
+
+// Dominated returns the children of the block in
+// the dominator tree.
+func (g *CFG) Dominated(b *BasicBlock) []*BasicBlock {
+	return g.children[b]
+}
+
+// Dominates returns whether every path from the entry
+// to b goes through a, a block dominates itself.
+func (g *CFG) Dominates(a, b *BasicBlock) bool {
+	if !g.Reachable(a) || !g.Reachable(b) {
+		return false
+	}
+	for b != nil {
+		if b == a {
+			return true
+		}
+		b = g.idom[b]
+	}
+	return false
+}
+
+// dominators works out the dominator tree, using "A Simple,
+// Fast Dominance Algorithm" by Cooper, Harvey and Kennedy.
+func (g *CFG) dominators() {
+	rpo := g.ReversePostorder()
+
+	g.order = map[*BasicBlock]int{}
+	for i, b := range rpo {
+		g.order[b] = i
+	}
+
+	intersect := func(a, b *BasicBlock) *BasicBlock {
+		for a != b {
+			for g.order[a] > g.order[b] {
+				a = g.idom[a]
+			}
+			for g.order[b] > g.order[a] {
+				b = g.idom[b]
+			}
+		}
+		return a
+	}
+
+	g.idom = map[*BasicBlock]*BasicBlock{g.Entry: g.Entry}
+	for changed := true; changed; {
+		changed = false
+		for _, b := range rpo[1:] {
+			var idom *BasicBlock
+			for _, pred := range b.Preds {
+				if _, ok := g.idom[pred]; !ok {
+					continue
+				}
+				if idom == nil {
+					idom = pred
+				} else {
+					idom = intersect(pred, idom)
+				}
+			}
+			if g.idom[b] != idom {
+				g.idom[b] = idom
+				changed = true
+			}
+		}
+	}
+
+	// the entry is its own dominator while working
+	// the tree out, but it has no parent in the tree.
+	delete(g.idom, g.Entry)
+
+	g.children = map[*BasicBlock][]*BasicBlock{}
+	for _, b := range rpo[1:] {
+		parent := g.idom[b]
+		g.children[parent] = append(g.children[parent], b)
+	}
+}
+
+func (g *CFG) String() string {
+	p := &printer{mod: &Module{}}
+	name := func(b *BasicBlock) string {
+		return fmt.Sprintf("bb%d", b.ID)
+	}
+
+	for _, b := range g.Blocks {
+		p.depth = 0
+		p.line("%s:", name(b))
+		p.depth = 1
+		for _, instr := range b.Instr {
+			p.instr(instr)
+		}
+
+		switch b.Term.Kind {
+		case JumpTerm:
+			p.line("jump %s;", name(b.Term.Target))
+		case BranchTerm:
+			p.line("branch %s, %s, %s;", p.value(b.Term.Cond), name(b.Term.True), name(b.Term.False))
+		case ReturnTerm:
+			if b.Term.Val == nil {
+				p.line("return;")
+			} else {
+				p.line("return %s;", p.value(b.Term.Val))
+			}
+		}
+	}
+	return p.buf.String()
+}
+
+// LOWERING
+
+type cfgScope struct {
+	block *Block
+	pos   int
+
+	// the locals declared in the block so far, and
+	// the index of the instruction declaring each.
+	locals []*Local
+	at     []int
+}
+
+// upTo returns the scope as it was before the
+// instruction at the given index.
+func (s *cfgScope) upTo(pos int) *cfgScope {
+	res := &cfgScope{block: s.block, pos: pos}
+	for i, at := range s.at {
+		if at < pos {
+			res.locals = append(res.locals, s.locals[i])
+			res.at = append(res.at, at)
+		}
+	}
+	return res
+}
+
+type cfgLoop struct {
+	label *front.Token
+	next  *BasicBlock
+	exit  *BasicBlock
+	depth int
+}
+
+type cfgBuilder struct {
+	g    *CFG
+	errs []api.CompilerError
+
+	// curr is the block that instructions are added to,
+	// it's nil after a terminator until a new block starts.
+	curr *BasicBlock
+
+	scopes []*cfgScope
+	loops  []*cfgLoop
+
+	// floor is the depth of the scopes that deferred code
+	// is run from, it can see them but doesn't unwind them.
+	floor int
+
+	labels     map[string]*BasicBlock
+	labelPaths map[string][]*Block
+
+	// env is kept in step with the scopes, it's used
+	// to type locals that are declared without one.
+	env     *Env
+	envBase int
+	params  map[string]*Local
+}
+
+func (c *cfgBuilder) error(err api.CompilerError) {
+	c.errs = append(c.errs, err)
+}
+
+func (c *cfgBuilder) newBlock() *BasicBlock {
+	return &BasicBlock{ID: -1, Instr: []*Instruction{}}
+}
+
+// place starts adding instructions to the given block.
+func (c *cfgBuilder) place(b *BasicBlock) {
+	b.ID = len(c.g.Blocks)
+	c.g.Blocks = append(c.g.Blocks, b)
+	c.curr = b
+}
+
+func (c *cfgBuilder) lookup(name string) *Local {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		locals := c.scopes[i].locals
+		for j := len(locals) - 1; j >= 0; j-- {
+			if locals[j].Name.Value == name {
+				return locals[j]
+			}
+		}
+	}
+	return c.params[name]
+}
+
+// resolve records the locals that the identifiers in
+// the value refer to, the names of fields and methods
+// in a path aren't identifiers of locals.
+func (c *cfgBuilder) resolve(v *Value) {
+	if v == nil {
+		return
+	}
+
+	switch v.Kind {
+	case IdentifierValue:
+		if local := c.lookup(v.Identifier.Name.Value); local != nil {
+			c.g.Refs[v.Identifier] = local
+		}
+	case GroupingValue:
+		c.resolve(v.Grouping.Val)
+	case BinaryExpressionValue:
+		c.resolve(v.BinaryExpression.LHand)
+		c.resolve(v.BinaryExpression.RHand)
+	case AssignValue:
+		c.resolve(v.Assign.LHand)
+		c.resolve(v.Assign.RHand)
+	case UnaryExpressionValue:
+		c.resolve(v.UnaryExpression.Val)
+	case BuiltinValue:
+		if iden := v.Builtin.Iden; iden != nil {
+			if local := c.lookup(iden.Name.Value); local != nil {
+				c.g.Refs[iden] = local
+			}
+		}
+		for _, arg := range v.Builtin.Args {
+			c.resolve(arg)
+		}
+	case CallValue:
+		c.resolve(v.Call.Left)
+		for _, param := range v.Call.Params {
+			c.resolve(param)
+		}
+	case PathValue:
+		c.resolve(v.Path.Values[0])
+		for _, elem := range v.Path.Values[1:] {
+			if elem.Kind != IdentifierValue {
+				c.resolve(elem)
+			}
+		}
+	case IndexValue:
+		c.resolve(v.Index.Left)
+		c.resolve(v.Index.Sub)
+	case SliceValue:
+		c.resolve(v.Slice.Left)
+		c.resolve(v.Slice.Low)
+		c.resolve(v.Slice.High)
+	case InitValue:
+		for _, val := range v.Init.Values {
+			c.resolve(val)
+		}
+	case CastValue:
+		c.resolve(v.Cast.Val)
+	}
+}
+
+// value copies a value into the cfg.
+func (c *cfgBuilder) value(v *Value) *Value {
+	res := CloneValue(v)
+	c.resolve(res)
+	return res
+}
+
+// add copies the instruction into the current block.
+func (c *cfgBuilder) add(instr *Instruction) {
+	if c.curr == nil {
+		// anything after a terminator is unreachable, it's
+		// kept in a block without predecessors.
+		c.place(c.newBlock())
+	}
+
+	res := CloneInstr(c.g.ids, instr)
+	switch res.Kind {
+	case LocalInstr:
+		local := res.Local
+		c.resolve(local.Val)
+		if local.Type == nil && local.Val != nil {
+			local.Type = c.env.TypeOf(local.Val)
+		}
+		c.env.DeclareLocal(local)
+
+		scope := c.scopes[len(c.scopes)-1]
+		scope.locals = append(scope.locals, local)
+		scope.at = append(scope.at, scope.pos)
+
+	case AllocaInstr:
+		c.resolve(res.Alloca.Val)
+	case AssignInstr:
+		c.resolve(res.Assign.LHand)
+		c.resolve(res.Assign.RHand)
+	case ExpressionInstr:
+		c.resolve(res.ExpressionStatement)
+	}
+	c.curr.Instr = append(c.curr.Instr, res)
+}
+
+func (c *cfgBuilder) terminate(term *Terminator) {
+	if c.curr == nil {
+		c.place(c.newBlock())
+	}
+	term.Cond = c.value(term.Cond)
+	term.Val = c.value(term.Val)
+
+	c.curr.Term = term
+	for _, succ := range term.Succs() {
+		c.curr.Succs = append(c.curr.Succs, succ)
+		succ.Preds = append(succ.Preds, c.curr)
+	}
+	c.curr = nil
+}
+
+func (c *cfgBuilder) jump(target *BasicBlock) {
+	c.terminate(&Terminator{Kind: JumpTerm, Target: target})
+}
+
+func (c *cfgBuilder) branch(cond *Value, t, f *BasicBlock) {
+	c.terminate(&Terminator{Kind: BranchTerm, Cond: cond, True: t, False: f})
+}
+
+// fallInto jumps to the block if control can fall
+// through to it, and then starts adding to it.
+func (c *cfgBuilder) fallInto(b *BasicBlock) {
+	if c.curr != nil {
+		c.jump(b)
+	}
+	c.place(b)
+}
+
+// runDefers lowers the defers that have been reached in the
+// scope at the given depth, the most recent defer runs first.
+// the deferred code sees the scopes as they were where it was
+// deferred, and can't see the loops it's run from.
+func (c *cfgBuilder) runDefers(depth int) {
+	scopes, loops, env, floor := c.scopes, c.loops, c.env.scopes, c.floor
+	defer func() {
+		c.scopes, c.loops, c.env.scopes, c.floor = scopes, loops, env, floor
+	}()
+
+	s := scopes[depth]
+	defers := s.block.DeferStack
+	for i := len(defers) - 1; i >= 0; i-- {
+		def := defers[i]
+		if def.After > s.pos {
+			continue
+		}
+
+		c.scopes = append(append([]*cfgScope{}, scopes[:depth]...), s.upTo(def.After))
+		c.loops = nil
+		c.floor = len(c.scopes)
+		c.env.scopes = append([]map[string]binding{}, env[:c.envBase+depth+1]...)
+
+		if def.Block != nil {
+			c.lowerBlock(def.Block)
+		} else {
+			c.lowerInstr(def.Stat)
+		}
+	}
+}
+
+// hasDefers returns whether any defers have been reached
+// in the scopes from the given depth to the innermost scope.
+func (c *cfgBuilder) hasDefers(depth int) bool {
+	if depth < c.floor {
+		depth = c.floor
+	}
+	for _, s := range c.scopes[depth:] {
+		for _, def := range s.block.DeferStack {
+			if def.After <= s.pos {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unwind lowers the defers that have been reached in every
+// scope from the innermost scope out to the given depth.
+func (c *cfgBuilder) unwind(depth int) {
+	for i := len(c.scopes) - 1; i >= depth && i >= c.floor; i-- {
+		c.runDefers(i)
+	}
+}
+
+func (c *cfgBuilder) lowerBlock(b *Block) {
+	scope := &cfgScope{block: b}
+	c.scopes = append(c.scopes, scope)
+	c.env.Push()
+
+	for idx, instr := range b.Instr {
+		scope.pos = idx
+		c.lowerInstr(instr)
+	}
+
+	// when control falls off the end of the
+	// block every defer in it has been reached.
+	if c.curr != nil {
+		scope.pos = len(b.Instr)
+		c.runDefers(len(c.scopes) - 1)
+	}
+
+	c.env.Pop()
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+func (c *cfgBuilder) lowerIf(iff *IfStatement) {
+	join := c.newBlock()
+
+	conds := []*Value{iff.Cond}
+	bodies := []*Block{iff.True}
+	for _, elif := range iff.ElseIf {
+		conds = append(conds, elif.Cond)
+		bodies = append(bodies, elif.Body)
+	}
+
+	for idx, cond := range conds {
+		body := c.newBlock()
+		next := join
+		if idx < len(conds)-1 || iff.Else != nil {
+			next = c.newBlock()
+		}
+
+		c.branch(cond, body, next)
+
+		c.place(body)
+		c.lowerBlock(bodies[idx])
+		if c.curr != nil {
+			c.jump(join)
+		}
+
+		if next != join {
+			c.place(next)
+		}
+	}
+
+	if iff.Else != nil {
+		c.lowerBlock(iff.Else)
+	}
+	c.fallInto(join)
+}
+
+// lowerLoop lowers the body of a loop, next goes to the
+// given block and break goes to the block after the loop.
+func (c *cfgBuilder) lowerLoop(label *front.Token, body *Block, next *BasicBlock, exit *BasicBlock) {
+	c.loops = append(c.loops, &cfgLoop{label, next, exit, len(c.scopes)})
+	c.lowerBlock(body)
+	c.loops = c.loops[:len(c.loops)-1]
+
+	if c.curr != nil {
+		c.jump(next)
+	}
+	c.place(exit)
+}
+
+func (c *cfgBuilder) lowerWhile(w *WhileLoop) {
+	cond, body, exit := c.newBlock(), c.newBlock(), c.newBlock()
+
+	c.fallInto(cond)
+	c.branch(w.Cond, body, exit)
+
+	next := cond
+	if w.Post != nil {
+		next = c.newBlock()
+	}
+
+	c.place(body)
+	c.loops = append(c.loops, &cfgLoop{w.Label, next, exit, len(c.scopes)})
+	c.lowerBlock(w.Body)
+	c.loops = c.loops[:len(c.loops)-1]
+
+	if w.Post != nil {
+		c.fallInto(next)
+		c.add(&Instruction{Kind: ExpressionInstr, ExpressionStatement: w.Post})
+	}
+	if c.curr != nil {
+		c.jump(cond)
+	}
+	c.place(exit)
+}
+
+func (c *cfgBuilder) lowerLoopJump(kind string, label *front.Token) {
+	if len(c.loops) == 0 {
+		c.error(api.NewJumpOutsideLoop(kind))
+		return
+	}
+
+	target := c.loops[len(c.loops)-1]
+	if label != nil {
+		target = nil
+		for i := len(c.loops) - 1; i >= 0; i-- {
+			if l := c.loops[i].label; l != nil && l.Value == label.Value {
+				target = c.loops[i]
+				break
+			}
+		}
+	}
+
+	if target == nil {
+		c.error(api.NewUnknownLoopLabel(label.Value, label.Span...))
+		return
+	}
+
+	c.unwind(target.depth)
+	if kind == "next" {
+		c.jump(target.next)
+	} else {
+		c.jump(target.exit)
+	}
+}
+
+func (c *cfgBuilder) labelBlock(name string) *BasicBlock {
+	b, ok := c.labels[name]
+	if !ok {
+		b = c.newBlock()
+		c.labels[name] = b
+	}
+	return b
+}
+
+func (c *cfgBuilder) lowerJump(j *Jump) {
+	path, ok := c.labelPaths[j.Location.Value]
+	if !ok {
+		c.error(api.NewUndefinedLabel(j.Location.Value, j.Location.Span...))
+		return
+	}
+
+	depth := 0
+	for depth < len(path) && depth < len(c.scopes) &&
+		c.scopes[depth].block == path[depth] {
+		depth++
+	}
+
+	c.unwind(depth)
+	c.jump(c.labelBlock(j.Location.Value))
+}
+
+func (c *cfgBuilder) lowerReturn(r *Return) {
+	val := r.Val
+
+	// the value is worked out before any defers run.
+	if val != nil && c.hasDefers(0) {
+		name := front.Token{Value: "krug_ret", Kind: front.Identifier}
+		ret := NewLocal(name, c.g.Func.ReturnType, false)
+		ret.SetValue(val)
+		c.add(&Instruction{Kind: LocalInstr, Local: ret})
+		val = &Value{Kind: IdentifierValue, Identifier: NewIdentifier(name)}
+	}
+
+	c.unwind(0)
+	c.terminate(&Terminator{Kind: ReturnTerm, Val: val})
+}
+
+func (c *cfgBuilder) lowerInstr(instr *Instruction) {
+	switch instr.Kind {
+	case BlockInstr:
+		c.lowerBlock(instr.Block)
+
+	case IfStatementInstr:
+		c.lowerIf(instr.IfStatement)
+
+	case LoopInstr:
+		head := c.newBlock()
+		c.fallInto(head)
+		c.lowerLoop(instr.Loop.Label, instr.Loop.Body, head, c.newBlock())
+
+	case WhileLoopInstr:
+		c.lowerWhile(instr.WhileLoop)
+
+	case BreakInstr:
+		c.lowerLoopJump("break", instr.Break.Label)
+
+	case NextInstr:
+		c.lowerLoopJump("next", instr.Next.Label)
+
+	case ReturnInstr:
+		c.lowerReturn(instr.Return)
+
+	case JumpInstr:
+		c.lowerJump(instr.Jump)
+
+	case LabelInstr:
+		name := instr.Label.Name
+		b := c.labelBlock(name.Value)
+		if b.ID >= 0 {
+			c.error(api.NewDuplicateLabel(name.Value, name.Span...))
+			b = c.newBlock()
+		}
+		c.fallInto(b)
+
+	default:
+		c.add(instr)
+	}
+}
+
+// findBlockLabels records the blocks enclosing each label in
+// the given block, deferred code isn't searched.
+func findBlockLabels(b *Block, outer []*Block, res map[string][]*Block) {
+	path := append(append([]*Block{}, outer...), b)
+
+	for _, instr := range b.Instr {
+		switch instr.Kind {
+		case LabelInstr:
+			res[instr.Label.Name.Value] = path
+		case BlockInstr:
+			findBlockLabels(instr.Block, path, res)
+		case LoopInstr:
+			findBlockLabels(instr.Loop.Body, path, res)
+		case WhileLoopInstr:
+			findBlockLabels(instr.WhileLoop.Body, path, res)
+		case IfStatementInstr:
+			iff := instr.IfStatement
+			findBlockLabels(iff.True, path, res)
+			for _, elif := range iff.ElseIf {
+				findBlockLabels(elif.Body, path, res)
+			}
+			if iff.Else != nil {
+				findBlockLabels(iff.Else, path, res)
+			}
+		}
+	}
+}
+
+// BuildCFG lowers the body of the given function in the
+// module into a control flow graph of basic blocks.
+func BuildCFG(mod *Module, fn *Function) (*CFG, []api.CompilerError) {
+	c := &cfgBuilder{
+		g: &CFG{
+			Func:   fn,
+			Blocks: []*BasicBlock{},
+			Refs:   map[*Identifier]*Local{},
+			ids:    mod.IDSource(),
+		},
+		errs:       []api.CompilerError{},
+		labels:     map[string]*BasicBlock{},
+		labelPaths: map[string][]*Block{},
+		env:        NewEnv(mod),
+		params:     map[string]*Local{},
+	}
+	findBlockLabels(fn.Body, nil, c.labelPaths)
+
+	c.env.Push()
+	c.env.DeclareParams(fn)
+	c.envBase = len(c.env.scopes)
+	for _, name := range fn.Param.Order {
+		c.params[name.Value] = fn.Param.Get(name.Value)
+	}
+
+	c.place(c.newBlock())
+	c.g.Entry = c.curr
+
+	c.lowerBlock(fn.Body)
+	if c.curr != nil {
+		c.terminate(&Terminator{Kind: ReturnTerm})
+	}
+
+	c.g.dominators()
+	return c.g, c.errs
+}
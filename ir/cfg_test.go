@@ -0,0 +1,244 @@
+package ir
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildCFGs builds the cfg of every function in
+// the module written in the given ir text.
+func buildCFGs(t *testing.T, src string) map[string]*CFG {
+	mod, errs := ParseText(`module "main";
+global {
+}
+` + src)
+	assert.Empty(t, errs)
+
+	res := map[string]*CFG{}
+	for _, name := range mod.FunctionOrder {
+		g, errs := BuildCFG(mod, mod.Functions[name.Value])
+		assert.Empty(t, errs, name.Value)
+		res[name.Value] = g
+	}
+	return res
+}
+
+func blockIDs(blocks []*BasicBlock) []int {
+	res := []int{}
+	for _, b := range blocks {
+		res = append(res, b.ID)
+	}
+	return res
+}
+
+// block returns the block in the cfg with the given id.
+func block(g *CFG, id int) *BasicBlock {
+	for _, b := range g.Blocks {
+		if b.ID == id {
+			return b
+		}
+	}
+	return nil
+}
+
+func TestCFGEdges(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		preds [][]int
+		succs [][]int
+	}{
+		{
+			name: "if, else if and else",
+			src: `fn void f(owned k i32) {
+	if (k == 0) {
+		let owned a = 1;
+	}
+	elif (k == 1) {
+		let owned b = 2;
+	}
+	else {
+		let owned c = 3;
+	}
+	let owned d = 4;
+}
+`,
+			// bb0 branches to the if body bb1 or the
+			// else if test bb2, every body joins at bb5.
+			preds: [][]int{{}, {0}, {0}, {2}, {2}, {1, 3, 4}},
+			succs: [][]int{{1, 2}, {5}, {3, 4}, {5}, {5}, {}},
+		},
+		{
+			name: "labeled break and next",
+			src: `fn void f(owned k i32) {
+	outer: loop {
+		let owned a = 1;
+		inner: loop {
+			if (k == 0) {
+				break outer;
+			}
+			if (k == 1) {
+				next outer;
+			}
+			next inner;
+		}
+	}
+	return;
+}
+`,
+			// bb1 is the head of outer and bb2 the head of inner.
+			// break outer goes to bb8 after outer, next outer goes
+			// back to bb1 and next inner back to bb2. bb7 is the
+			// end of the inner loop, which is never reached.
+			preds: [][]int{{}, {0, 5, 7}, {1, 6}, {2}, {2}, {4}, {4}, {}, {3}},
+			succs: [][]int{{1}, {2}, {3, 4}, {8}, {5, 6}, {1}, {2}, {1}, {}},
+		},
+		{
+			name: "jump",
+			src: `fn void f(owned k i32) {
+	jump done;
+	let owned x = 2;
+	$done;
+	return;
+}
+`,
+			// the code between the jump and the label
+			// is in bb1, which has no predecessors.
+			preds: [][]int{{}, {}, {0, 1}},
+			succs: [][]int{{2}, {2}, {}},
+		},
+	}
+
+	for _, test := range tests {
+		g := buildCFGs(t, test.src)["f"]
+		if !assert.Len(t, g.Blocks, len(test.succs), test.name) {
+			continue
+		}
+		for id := range test.succs {
+			b := block(g, id)
+			assert.Equal(t, test.preds[id], blockIDs(b.Preds), "%s: preds of bb%d", test.name, id)
+			assert.Equal(t, test.succs[id], blockIDs(b.Succs), "%s: succs of bb%d", test.name, id)
+		}
+	}
+}
+
+func TestCFGDominators(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		// idom is the immediate dominator of each
+		// block, -1 for the entry.
+		idom []int
+	}{
+		{
+			name: "diamond",
+			src: `fn void f(owned k i32) {
+	if (k == 0) {
+		let owned a = 1;
+	}
+	else {
+		let owned b = 2;
+	}
+	return;
+}
+`,
+			idom: []int{-1, 0, 0, 0},
+		},
+		{
+			name: "nested loop",
+			src: `fn void f(owned k i32) {
+	mut owned i = 0;
+	while (i < k) {
+		mut owned j = 0;
+		while (j < k) {
+			j = (j + 1);
+		}
+		i = (i + 1);
+	}
+}
+`,
+			// bb1 and bb3 are the conditions of the outer
+			// and inner loop, bb5 is after the inner loop
+			// and bb6 after the outer loop.
+			idom: []int{-1, 0, 1, 2, 3, 3, 1},
+		},
+	}
+
+	for _, test := range tests {
+		g := buildCFGs(t, test.src)["f"]
+		if !assert.Len(t, g.Blocks, len(test.idom), test.name) {
+			continue
+		}
+
+		for id, want := range test.idom {
+			b := block(g, id)
+			idom := g.Idom(b)
+			if want < 0 {
+				assert.Nil(t, idom, "%s: idom of bb%d", test.name, id)
+				continue
+			}
+			if assert.NotNil(t, idom, "%s: idom of bb%d", test.name, id) {
+				assert.Equal(t, want, idom.ID, "%s: idom of bb%d", test.name, id)
+				assert.True(t, g.Dominates(idom, b), test.name)
+				assert.Contains(t, g.Dominated(idom), b, test.name)
+			}
+			assert.True(t, g.Dominates(g.Entry, b), test.name)
+		}
+	}
+
+	g := buildCFGs(t, tests[1].src)["f"]
+	assert.False(t, g.Dominates(block(g, 5), block(g, 6)))
+	assert.False(t, g.Dominates(block(g, 4), block(g, 3)))
+}
+
+func TestCFGDefersOnEveryExit(t *testing.T) {
+	g := buildCFGs(t, `fn i32 f(owned k i32) {
+	defer let owned e = 2;
+	loop {
+		defer let owned d = 1;
+		if (k == 0) {
+			break;
+		}
+		if (k == 1) {
+			return 2;
+		}
+	}
+	return 0;
+}
+`)["f"]
+
+	// locals are the names of the locals in each block.
+	locals := map[int][]string{}
+	seen := map[*Instruction]bool{}
+	for _, b := range g.Blocks {
+		locals[b.ID] = []string{}
+		for _, instr := range b.Instr {
+			assert.False(t, seen[instr], "instruction lowered twice into bb%d", b.ID)
+			seen[instr] = true
+			if instr.Kind == LocalInstr {
+				locals[b.ID] = append(locals[b.ID], instr.Local.Name.Value)
+			}
+		}
+	}
+
+	assert.Equal(t, map[int][]string{
+		0: {},
+		1: {},
+		// break runs the loop's defer.
+		2: {"d"},
+		3: {},
+		// return works out its value, then runs the
+		// loop's defer and then the function's.
+		4: {"krug_ret", "d", "e"},
+		// the end of the loop body runs its defer.
+		5: {"d"},
+		// the return after the loop only
+		// runs the function's defer.
+		6: {"krug_ret", "e"},
+	}, locals)
+
+	assert.EqualValues(t, ReturnTerm, block(g, 4).Term.Kind)
+	assert.EqualValues(t, ReturnTerm, block(g, 6).Term.Kind)
+	assert.Equal(t, []int{6}, blockIDs(block(g, 2).Succs))
+}
@@ -0,0 +1,163 @@
+package ir
+
+import (
+	"math/big"
+)
+
+// the clones share types and tokens with the original,
+// only the values, instructions and blocks are copied.
+
+func cloneValues(vals []*Value) []*Value {
+	if vals == nil {
+		return nil
+	}
+	res := make([]*Value, len(vals))
+	for i, val := range vals {
+		res[i] = CloneValue(val)
+	}
+	return res
+}
+
+func cloneIdentifier(i *Identifier) *Identifier {
+	if i == nil {
+		return nil
+	}
+	return NewIdentifier(i.Name)
+}
+
+// CloneValue returns a deep copy of the given value.
+func CloneValue(v *Value) *Value {
+	if v == nil {
+		return nil
+	}
+
+	res := &Value{Kind: v.Kind}
+	switch v.Kind {
+	case IntegerValueValue:
+		res.IntegerValue = NewIntegerValue(new(big.Int).Set(v.IntegerValue.RawValue))
+	case FloatingValueValue:
+		res.FloatingValue = NewFloatingValue(v.FloatingValue.Value)
+	case StringValueValue:
+		res.StringValue = NewStringValue(v.StringValue.Value)
+	case CharacterValueValue:
+		res.CharacterValue = NewCharacterValue(v.CharacterValue.Value)
+	case BooleanValueValue:
+		res.BooleanValue = NewBooleanValue(v.BooleanValue.Value)
+	case IdentifierValue:
+		res.Identifier = cloneIdentifier(v.Identifier)
+	case GroupingValue:
+		res.Grouping = NewGrouping(CloneValue(v.Grouping.Val))
+	case BinaryExpressionValue:
+		b := v.BinaryExpression
+		res.BinaryExpression = NewBinaryExpression(CloneValue(b.LHand), b.Op, CloneValue(b.RHand))
+	case AssignValue:
+		a := v.Assign
+		res.Assign = NewAssign(CloneValue(a.LHand), a.Op, CloneValue(a.RHand))
+	case UnaryExpressionValue:
+		res.UnaryExpression = NewUnaryExpression(v.UnaryExpression.Op, CloneValue(v.UnaryExpression.Val))
+	case BuiltinValue:
+		b := v.Builtin
+		res.Builtin = NewBuiltin(b.Name, cloneIdentifier(b.Iden), cloneValues(b.Args))
+	case CallValue:
+		res.Call = NewCall(CloneValue(v.Call.Left), cloneValues(v.Call.Params))
+	case PathValue:
+		res.Path = NewPath(cloneValues(v.Path.Values))
+	case IndexValue:
+		i := v.Index
+		res.Index = NewIndex(CloneValue(i.Left), CloneValue(i.Sub), i.Span)
+	case SliceValue:
+		s := v.Slice
+		res.Slice = NewSlice(CloneValue(s.Left), CloneValue(s.Low), CloneValue(s.High), s.Span)
+	case InitValue:
+		i := v.Init
+		res.Init = NewInit(i.Kind, cloneIdentifier(i.LHand), i.Names, cloneValues(i.Values))
+		res.Init.Defaults = i.Defaults
+	case CastValue:
+		res.Cast = NewCast(CloneValue(v.Cast.Val), v.Cast.Type)
+	}
+	return res
+}
+
+func cloneLocal(l *Local) *Local {
+	return &Local{l.Name, l.Type, l.Mutable, l.Owned, CloneValue(l.Val)}
+}
+
+func cloneDefer(ids *IDSource, d *Defer) *Defer {
+	res := NewDefer(CloneInstr(ids, d.Stat), CloneBlock(ids, d.Block))
+	res.After = d.After
+	return res
+}
+
+// CloneBlock returns a deep copy of the given block,
+// the copy is given a new id from the source.
+func CloneBlock(ids *IDSource, b *Block) *Block {
+	if b == nil {
+		return nil
+	}
+
+	res := NewBlock(ids)
+	res.Stab = b.Stab
+	for _, def := range b.DeferStack {
+		res.PushDefer(cloneDefer(ids, def))
+	}
+	for _, instr := range b.Instr {
+		res.AddInstr(CloneInstr(ids, instr))
+	}
+	return res
+}
+
+// CloneInstr returns a deep copy of the given instruction,
+// any blocks in it are given new ids from the source.
+func CloneInstr(ids *IDSource, i *Instruction) *Instruction {
+	if i == nil {
+		return nil
+	}
+
+	res := &Instruction{Kind: i.Kind}
+	switch i.Kind {
+	case BlockInstr:
+		res.Block = CloneBlock(ids, i.Block)
+	case AssignInstr:
+		a := i.Assign
+		res.Assign = NewAssign(CloneValue(a.LHand), a.Op, CloneValue(a.RHand))
+	case LocalInstr:
+		res.Local = cloneLocal(i.Local)
+	case AllocaInstr:
+		a := i.Alloca
+		res.Alloca = &Alloca{a.Name, a.Type, a.Mutable, a.Owned, CloneValue(a.Val)}
+	case NextInstr:
+		res.Next = NewNext(i.Next.Label)
+	case BreakInstr:
+		res.Break = NewBreak(i.Break.Label)
+	case ReturnInstr:
+		res.Return = NewReturn(CloneValue(i.Return.Val))
+		res.Return.Span = i.Return.Span
+	case LoopInstr:
+		res.Loop = NewLoop(CloneBlock(ids, i.Loop.Body), i.Loop.Label)
+	case WhileLoopInstr:
+		w := i.WhileLoop
+		res.WhileLoop = NewWhileLoop(CloneValue(w.Cond), CloneValue(w.Post), CloneBlock(ids, w.Body), w.Label)
+	case IfStatementInstr:
+		iff := i.IfStatement
+		elifs := make([]*ElseIfStatement, len(iff.ElseIf))
+		for idx, elif := range iff.ElseIf {
+			elifs[idx] = NewElseIfStatement(CloneValue(elif.Cond), CloneBlock(ids, elif.Body))
+		}
+		res.IfStatement = NewIfStatement(CloneValue(iff.Cond), CloneBlock(ids, iff.True), elifs, CloneBlock(ids, iff.Else))
+	case ElseIfStatementInstr:
+		elif := i.ElseIfStatement
+		res.ElseIfStatement = NewElseIfStatement(CloneValue(elif.Cond), CloneBlock(ids, elif.Body))
+	case ExpressionInstr:
+		res.ExpressionStatement = CloneValue(i.ExpressionStatement)
+	case JumpInstr:
+		res.Jump = NewJump(i.Jump.Location)
+	case LabelInstr:
+		res.Label = NewLabel(i.Label.Name)
+	case DeferInstr:
+		res.Defer = cloneDefer(ids, i.Defer)
+	case TypeAliasInstr:
+		t := i.TypeAliasStatement
+		res.TypeAliasStatement = NewTypeAlias(t.Name, t.Type)
+	}
+	return res
+}
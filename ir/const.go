@@ -0,0 +1,496 @@
+package ir
+
+import (
+	"math"
+	"math/big"
+)
+
+// IsConstant returns whether the given value can be
+// evaluated at compile time, i.e. it is only made up
+// of literals and operators on literals. this is what
+// is allowed to initialise a global.
+func IsConstant(v *Value) bool {
+	if v == nil {
+		return false
+	}
+
+	switch v.Kind {
+	case IntegerValueValue, FloatingValueValue, CharacterValueValue, StringValueValue, BooleanValueValue:
+		return true
+
+	case GroupingValue:
+		return IsConstant(v.Grouping.Val)
+
+	case UnaryExpressionValue:
+		switch v.UnaryExpression.Op {
+		case "-", "+", "!", "~":
+			return IsConstant(v.UnaryExpression.Val)
+		}
+		return false
+
+	case BinaryExpressionValue:
+		bin := v.BinaryExpression
+		if !IsConstant(bin.LHand) || !IsConstant(bin.RHand) {
+			return false
+		}
+
+		// operations on strings are done by the runtime.
+		lh, rh := ConstantType(bin.LHand), ConstantType(bin.RHand)
+		return lh.Kind != StringKind && rh.Kind != StringKind
+
+	case InitValue:
+		for _, val := range v.Init.Values {
+			if !IsConstant(val) {
+				return false
+			}
+		}
+		return true
+
+	case BuiltinValue:
+		return v.Builtin.Name == "sizeof"
+
+	case CastValue:
+		return IsNumeric(v.Cast.Type) && IsConstant(v.Cast.Val)
+
+	default:
+		return false
+	}
+}
+
+// ConstantType works out the type of the given constant
+// value, this is used for globals that are declared without
+// a type, e.g. let foo = 3;
+func ConstantType(v *Value) *Type {
+	switch v.Kind {
+	case GroupingValue:
+		return ConstantType(v.Grouping.Val)
+
+	case UnaryExpressionValue:
+		if v.UnaryExpression.Op == "!" {
+			return Bool
+		}
+		return ConstantType(v.UnaryExpression.Val)
+
+	case BinaryExpressionValue:
+		bin := v.BinaryExpression
+		if IsComparison(bin.Op) {
+			return Bool
+		}
+		lh, rh := ConstantType(bin.LHand), ConstantType(bin.RHand)
+		if widest := Widest(lh, rh); widest != nil {
+			return widest
+		}
+		return lh
+
+	case BuiltinValue:
+		// sizeof
+		return Uint64
+
+	case CastValue:
+		return v.Cast.Type
+
+	default:
+		return v.InferredType()
+	}
+}
+
+// IntegerLiteral returns the integer literal that the given
+// value is, if it is one. negated literals are folded, e.g. -5
+func IntegerLiteral(v *Value) (*IntegerValue, bool) {
+	switch v.Kind {
+	case IntegerValueValue:
+		return v.IntegerValue, true
+	case GroupingValue:
+		return IntegerLiteral(v.Grouping.Val)
+	case UnaryExpressionValue:
+		if v.UnaryExpression.Op != "-" {
+			return nil, false
+		}
+		if lit, ok := IntegerLiteral(v.UnaryExpression.Val); ok {
+			return NewIntegerValue(new(big.Int).Neg(lit.RawValue)), true
+		}
+	}
+	return nil, false
+}
+
+// Constant is a value that is known at compile time, one
+// of an integer, a float, or a bool. Type is nil for literals
+// that haven't been given a type.
+type Constant struct {
+	Kind  ValueKind
+	Int   *big.Int
+	Float float64
+	Bool  bool
+	Type  *Type
+}
+
+func (c *Constant) IsNumeric() bool {
+	return c.Kind == IntegerValueValue || c.Kind == FloatingValueValue
+}
+
+// AsFloat returns the value of the constant as a float.
+func (c *Constant) AsFloat() float64 {
+	if c.Kind == FloatingValueValue {
+		return c.Float
+	}
+	res, _ := new(big.Float).SetInt(c.Int).Float64()
+	return res
+}
+
+// Fits returns whether an integer constant fits in its type.
+func (c *Constant) Fits() bool {
+	if c.Type == nil || c.Kind != IntegerValueValue {
+		return true
+	}
+	return IntegerFits(c.Int, c.Type.IntegerType)
+}
+
+func (c *Constant) String() string {
+	switch c.Kind {
+	case IntegerValueValue:
+		return c.Int.String()
+	case FloatingValueValue:
+		return big.NewFloat(c.Float).String()
+	}
+	if c.Bool {
+		return "true"
+	}
+	return "false"
+}
+
+// ConstantOf returns the constant that the given value is,
+// if it is one. casts of numeric literals to numeric types
+// are constants of that type.
+func ConstantOf(v *Value) (*Constant, bool) {
+	if lit, ok := IntegerLiteral(v); ok {
+		return &Constant{Kind: IntegerValueValue, Int: lit.RawValue}, true
+	}
+
+	switch v.Kind {
+	case FloatingValueValue:
+		return &Constant{Kind: FloatingValueValue, Float: v.FloatingValue.Value}, true
+	case BooleanValueValue:
+		return &Constant{Kind: BooleanValueValue, Bool: v.BooleanValue.Value}, true
+	case GroupingValue:
+		return ConstantOf(v.Grouping.Val)
+
+	case UnaryExpressionValue:
+		u := v.UnaryExpression
+		if u.Op != "-" || u.Val.Kind != FloatingValueValue {
+			return nil, false
+		}
+		return &Constant{Kind: FloatingValueValue, Float: -u.Val.FloatingValue.Value}, true
+
+	case CastValue:
+		c, ok := ConstantOf(v.Cast.Val)
+		if !ok || c.Type != nil || !c.IsNumeric() {
+			return nil, false
+		}
+		return c.Typed(v.Cast.Type)
+	}
+	return nil, false
+}
+
+// Typed gives the untyped constant the type typ, if
+// the constant can be implicitly converted to it.
+func (c *Constant) Typed(typ *Type) (*Constant, bool) {
+	switch {
+	case typ == nil:
+		return nil, false
+	case c.Kind == IntegerValueValue && typ.Kind == IntegerKind:
+		if !IntegerFits(c.Int, typ.IntegerType) {
+			return nil, false
+		}
+		return &Constant{Kind: c.Kind, Int: c.Int, Type: typ}, true
+	case c.IsNumeric() && typ.Kind == FloatKind:
+		return &Constant{Kind: FloatingValueValue, Float: c.AsFloat(), Type: typ}, true
+	case c.Kind == BooleanValueValue && typ.Kind == BoolKind:
+		return c, true
+	}
+	return nil, false
+}
+
+// Value returns the constant as a value, negative numbers
+// are negated literals so that they can be written after
+// another operator.
+func (c *Constant) Value() *Value {
+	var res *Value
+	switch c.Kind {
+	case IntegerValueValue:
+		res = &Value{Kind: IntegerValueValue, IntegerValue: NewIntegerValue(new(big.Int).Abs(c.Int))}
+		if c.Int.Sign() < 0 {
+			res = &Value{Kind: UnaryExpressionValue, UnaryExpression: NewUnaryExpression("-", res)}
+		}
+	case FloatingValueValue:
+		res = &Value{Kind: FloatingValueValue, FloatingValue: NewFloatingValue(math.Abs(c.Float))}
+		if c.Float < 0 {
+			res = &Value{Kind: UnaryExpressionValue, UnaryExpression: NewUnaryExpression("-", res)}
+		}
+	case BooleanValueValue:
+		return &Value{Kind: BooleanValueValue, BooleanValue: NewBooleanValue(c.Bool)}
+	}
+
+	if c.Type == nil {
+		return res
+	}
+	return &Value{Kind: CastValue, Cast: NewCast(res, c.Type)}
+}
+
+// commonType returns the type that the result of an operator
+// on the two constants has, ok is false if they can't be mixed.
+func commonType(a, b *Constant) (typ *Type, ok bool) {
+	switch {
+	case a.Type == nil:
+		return b.Type, true
+	case b.Type == nil:
+		return a.Type, true
+	}
+	typ = Widest(a.Type, b.Type)
+	return typ, typ != nil
+}
+
+// floatType returns the type of the float of the two
+// constants, which is nil if it's an untyped literal.
+func floatType(a, b *Constant) *Type {
+	if a.Kind == FloatingValueValue {
+		return a.Type
+	}
+	return b.Type
+}
+
+func foldComparison(op string, cmp int) (*Constant, bool) {
+	var res bool
+	switch op {
+	case "==":
+		res = cmp == 0
+	case "!=":
+		res = cmp != 0
+	case "<":
+		res = cmp < 0
+	case ">":
+		res = cmp > 0
+	case "<=":
+		res = cmp <= 0
+	case ">=":
+		res = cmp >= 0
+	default:
+		return nil, false
+	}
+	return &Constant{Kind: BooleanValueValue, Bool: res}, true
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func foldBools(op string, a, b bool) (*Constant, bool) {
+	var res bool
+	switch op {
+	case "&&":
+		res = a && b
+	case "||":
+		res = a || b
+	case "==":
+		res = a == b
+	case "!=":
+		res = a != b
+	default:
+		return nil, false
+	}
+	return &Constant{Kind: BooleanValueValue, Bool: res}, true
+}
+
+func foldFloats(op string, a, b float64) (*Constant, bool) {
+	if IsComparison(op) {
+		return foldComparison(op, compareFloats(a, b))
+	}
+
+	var res float64
+	switch op {
+	case "+":
+		res = a + b
+	case "-":
+		res = a - b
+	case "*":
+		res = a * b
+	case "/":
+		// this is left to the runtime, it's
+		// infinity and not an error for floats.
+		if b == 0 {
+			return nil, false
+		}
+		res = a / b
+	default:
+		return nil, false
+	}
+
+	if math.IsInf(res, 0) || math.IsNaN(res) {
+		return nil, false
+	}
+	return &Constant{Kind: FloatingValueValue, Float: res}, true
+}
+
+// maxShift is the largest shift that is folded,
+// anything larger overflows every integer type.
+const maxShift = 1024
+
+func foldIntegers(op string, a, b *big.Int) (*Constant, bool) {
+	if IsComparison(op) {
+		return foldComparison(op, a.Cmp(b))
+	}
+
+	res := new(big.Int)
+	switch op {
+	case "+":
+		res.Add(a, b)
+	case "-":
+		res.Sub(a, b)
+	case "*":
+		res.Mul(a, b)
+	case "/", "%":
+		if b.Sign() == 0 {
+			return nil, false
+		}
+		// C truncates towards zero.
+		if op == "/" {
+			res.Quo(a, b)
+		} else {
+			res.Rem(a, b)
+		}
+	case "<<", ">>":
+		if b.Sign() < 0 || b.Cmp(big.NewInt(maxShift)) > 0 {
+			return nil, false
+		}
+		if op == "<<" {
+			res.Lsh(a, uint(b.Uint64()))
+		} else {
+			res.Rsh(a, uint(b.Uint64()))
+		}
+	case "&":
+		res.And(a, b)
+	case "|":
+		res.Or(a, b)
+	case "^":
+		res.Xor(a, b)
+	default:
+		return nil, false
+	}
+	return &Constant{Kind: IntegerValueValue, Int: res}, true
+}
+
+// FoldBinary applies the binary operator to the two constants.
+// ok is false if it can't be done at compile time, e.g. an
+// integer division by zero. the result may not fit its type.
+func FoldBinary(op string, lh, rh *Constant) (res *Constant, ok bool) {
+	if lh.Kind == BooleanValueValue || rh.Kind == BooleanValueValue {
+		if lh.Kind != rh.Kind {
+			return nil, false
+		}
+		return foldBools(op, lh.Bool, rh.Bool)
+	}
+
+	typ, ok := commonType(lh, rh)
+	if !ok {
+		return nil, false
+	}
+
+	if lh.Kind == FloatingValueValue || rh.Kind == FloatingValueValue || (typ != nil && typ.Kind == FloatKind) {
+		res, ok = foldFloats(op, lh.AsFloat(), rh.AsFloat())
+
+		// a float literal doesn't take the integer type of
+		// the other side, the result is still a float.
+		if typ != nil && typ.Kind != FloatKind {
+			typ = floatType(lh, rh)
+		}
+	} else {
+		res, ok = foldIntegers(op, lh.Int, rh.Int)
+	}
+	if !ok {
+		return nil, false
+	}
+
+	if res.Kind != BooleanValueValue {
+		res.Type = typ
+	}
+	return res, true
+}
+
+// FoldUnary applies the unary operator to the constant,
+// the result may not fit its type.
+func FoldUnary(op string, c *Constant) (*Constant, bool) {
+	res := &Constant{Kind: c.Kind, Type: c.Type}
+	switch {
+	case op == "!" && c.Kind == BooleanValueValue:
+		res.Bool = !c.Bool
+	case op == "+" && c.IsNumeric():
+		return c, true
+	case op == "-" && c.Kind == FloatingValueValue:
+		res.Float = -c.Float
+	case op == "-" && c.Kind == IntegerValueValue:
+		res.Int = new(big.Int).Neg(c.Int)
+	case op == "~" && c.Kind == IntegerValueValue:
+		res.Int = new(big.Int).Not(c.Int)
+		// unsigned integers have no sign bit to flip.
+		if c.Type != nil && !c.Type.IntegerType.Signed {
+			max := new(big.Int).Lsh(big.NewInt(1), uint(c.Type.IntegerType.Width))
+			res.Int.Add(res.Int, max)
+		}
+	default:
+		return nil, false
+	}
+	return res, true
+}
+
+// EvalConstant evaluates the given constant expression, e.g.
+// 2 + 3 is 5. the value is returned as it is if it can't be
+// evaluated, e.g. if it overflows or divides by zero, these
+// are reported when the constants are folded.
+func EvalConstant(v *Value) *Value {
+	if c, ok := evalConstant(v); ok {
+		return c.Value()
+	}
+	return v
+}
+
+func evalConstant(v *Value) (*Constant, bool) {
+	if c, ok := ConstantOf(v); ok {
+		return c, true
+	}
+
+	var res *Constant
+	var ok bool
+	switch v.Kind {
+	case GroupingValue:
+		return evalConstant(v.Grouping.Val)
+
+	case UnaryExpressionValue:
+		c, cok := evalConstant(v.UnaryExpression.Val)
+		if !cok {
+			return nil, false
+		}
+		res, ok = FoldUnary(v.UnaryExpression.Op, c)
+
+	case BinaryExpressionValue:
+		bin := v.BinaryExpression
+		lh, lok := evalConstant(bin.LHand)
+		rh, rok := evalConstant(bin.RHand)
+		if !lok || !rok {
+			return nil, false
+		}
+		res, ok = FoldBinary(bin.Op, lh, rh)
+
+	case CastValue:
+		c, ok := evalConstant(v.Cast.Val)
+		if !ok || c.Type != nil || !c.IsNumeric() {
+			return nil, false
+		}
+		return c.Typed(v.Cast.Type)
+	}
+	return res, ok && res.Fits()
+}
@@ -0,0 +1,163 @@
+package ir
+
+import (
+	"math/big"
+)
+
+// TypesEqual returns whether the two types are the same type.
+func TypesEqual(a, b *Type) bool {
+	if a == nil || b == nil || a.Kind != b.Kind {
+		return false
+	}
+
+	switch a.Kind {
+	case IntegerKind:
+		return *a.IntegerType == *b.IntegerType
+	case FloatKind:
+		return *a.FloatingType == *b.FloatingType
+	case VoidKind, StringKind, BoolKind:
+		return true
+	case PointerKind:
+		return TypesEqual(a.Pointer.Base, b.Pointer.Base)
+	case ReferenceKind:
+		return a.Reference.Name == b.Reference.Name
+	case ArrayKind:
+		return TypesEqual(a.ArrayType.Base, b.ArrayType.Base)
+	case SliceKind:
+		return TypesEqual(a.Slice.Base, b.Slice.Base)
+	case TupleKind:
+		if len(a.Tuple.Types) != len(b.Tuple.Types) {
+			return false
+		}
+		for i, t := range a.Tuple.Types {
+			if !TypesEqual(t, b.Tuple.Types[i]) {
+				return false
+			}
+		}
+		return true
+	case StructKind:
+		return a.Structure.Name.Value == b.Structure.Name.Value
+	}
+	return false
+}
+
+// IsNumeric returns whether the given type is
+// an integer or a floating point type.
+func IsNumeric(t *Type) bool {
+	return t != nil && (t.Kind == IntegerKind || t.Kind == FloatKind)
+}
+
+// mantissa is the number of bits of integer
+// precision a floating type of the given width has.
+func mantissa(width int) int {
+	if width == 32 {
+		return 24
+	}
+	return 53
+}
+
+// Widens returns whether a value of type from can be implicitly
+// converted to the type to without losing any information:
+//
+//   - integers widen to larger integers of the same signedness
+//   - unsigned integers widen to strictly larger signed integers
+//   - integers widen to floats that can represent all of their values
+//   - f32 widens to f64
+//
+// every other conversion must be written with an explicit cast.
+func Widens(from, to *Type) bool {
+	if TypesEqual(from, to) {
+		return true
+	}
+	if !IsNumeric(from) || !IsNumeric(to) {
+		return false
+	}
+
+	switch {
+	case from.Kind == IntegerKind && to.Kind == IntegerKind:
+		f, t := from.IntegerType, to.IntegerType
+		if f.Signed == t.Signed {
+			return f.Width <= t.Width
+		}
+		return !f.Signed && f.Width < t.Width
+
+	case from.Kind == IntegerKind && to.Kind == FloatKind:
+		width := from.IntegerType.Width
+		if from.IntegerType.Signed {
+			width--
+		}
+		return width <= mantissa(to.FloatingType.Width)
+
+	case from.Kind == FloatKind && to.Kind == FloatKind:
+		return from.FloatingType.Width <= to.FloatingType.Width
+	}
+
+	return false
+}
+
+// Widest returns the type that both a and b can be implicitly
+// converted to, or nil if there is no such type.
+func Widest(a, b *Type) *Type {
+	if Widens(a, b) {
+		return b
+	}
+	if Widens(b, a) {
+		return a
+	}
+	return nil
+}
+
+// CanCast returns whether a value of type from can be
+// explicitly converted to the type to with an 'as' cast.
+func CanCast(from, to *Type) bool {
+	if TypesEqual(from, to) {
+		return true
+	}
+
+	switch {
+	// any numeric conversion is allowed, even
+	// if it loses information.
+	case IsNumeric(from) && IsNumeric(to):
+		return true
+
+	// pointers can be reinterpreted as any other pointer.
+	case from.Kind == PointerKind && to.Kind == PointerKind:
+		return true
+
+	// pointers can be converted to and from 64 bit integers
+	case from.Kind == PointerKind && to.Kind == IntegerKind:
+		return to.IntegerType.Width == 64
+	case from.Kind == IntegerKind && to.Kind == PointerKind:
+		return from.IntegerType.Width == 64
+
+	// bools are 0 or 1 as an integer, and an
+	// integer is true if it's not 0.
+	case from.Kind == BoolKind && to.Kind == IntegerKind:
+		return true
+	case from.Kind == IntegerKind && to.Kind == BoolKind:
+		return true
+
+	// strings can be converted to and from *u8 for C
+	case from.Kind == StringKind && to.Kind == PointerKind:
+		return TypesEqual(to.Pointer.Base, Uint8)
+	case from.Kind == PointerKind && to.Kind == StringKind:
+		return TypesEqual(from.Pointer.Base, Uint8)
+	}
+
+	return false
+}
+
+// IntegerFits returns whether the given integer constant
+// can be stored in the given integer type.
+func IntegerFits(val *big.Int, t *IntegerType) bool {
+	var min, max big.Int
+	if t.Signed {
+		max.Lsh(big.NewInt(1), uint(t.Width-1))
+		min.Neg(&max)
+		max.Sub(&max, big.NewInt(1))
+	} else {
+		max.Lsh(big.NewInt(1), uint(t.Width))
+		max.Sub(&max, big.NewInt(1))
+	}
+	return val.Cmp(&min) >= 0 && val.Cmp(&max) <= 0
+}
@@ -0,0 +1,375 @@
+package ir
+
+// binding is a local in the environment.
+type binding struct {
+	typ     *Type
+	mutable bool
+}
+
+// Env is a scoped set of the types of locals in a module.
+// it's used by passes that need to know the type of a value
+// while they walk a function.
+type Env struct {
+	mod    *Module
+	scopes []map[string]binding
+
+	// types are the types of values that have already
+	// been worked out, e.g. by type inference.
+	types *TypeMap
+}
+
+// NewEnv creates a new environment for the given module,
+// with the modules globals in the outermost scope.
+func NewEnv(mod *Module) *Env {
+	e := &Env{mod, []map[string]binding{}, nil}
+
+	e.Push()
+	for _, instr := range mod.Global.Instr {
+		if instr.Kind == LocalInstr {
+			e.DeclareLocal(instr.Local)
+		}
+	}
+	return e
+}
+
+func (e *Env) Push() {
+	e.scopes = append(e.scopes, map[string]binding{})
+}
+
+func (e *Env) Pop() {
+	e.scopes = e.scopes[:len(e.scopes)-1]
+}
+
+func (e *Env) Declare(name string, typ *Type, mutable bool) {
+	e.scopes[len(e.scopes)-1][name] = binding{typ, mutable}
+}
+
+// DeclareLocal declares the given local, if the local
+// has no type it is inferred from its value.
+func (e *Env) DeclareLocal(l *Local) {
+	typ := l.Type
+	if typ == nil && l.Val != nil {
+		typ = e.TypeOf(l.Val)
+	}
+	e.Declare(l.Name.Value, typ, l.Mutable)
+}
+
+// DeclareParams declares the params of the given function.
+func (e *Env) DeclareParams(fn *Function) {
+	for _, name := range fn.Param.Order {
+		e.DeclareLocal(fn.Param.Get(name.Value))
+	}
+}
+
+func (e *Env) lookup(name string) (binding, bool) {
+	for i := len(e.scopes) - 1; i >= 0; i-- {
+		if b, ok := e.scopes[i][name]; ok {
+			return b, true
+		}
+	}
+	return binding{}, false
+}
+
+// Lookup returns the type of the local with the given
+// name, or nil if there is no such local.
+func (e *Env) Lookup(name string) *Type {
+	b, _ := e.lookup(name)
+	return b.typ
+}
+
+// IsMutable returns whether the local with the given name
+// can be modified.
+func (e *Env) IsMutable(name string) bool {
+	b, ok := e.lookup(name)
+	return ok && b.mutable
+}
+
+// Structure returns the structure that the given type refers
+// to, pointers to structures are dereferenced.
+func (e *Env) Structure(typ *Type) (*Structure, bool) {
+	if typ == nil {
+		return nil, false
+	}
+
+	switch typ.Kind {
+	case PointerKind:
+		if base := typ.Pointer.Base; base.Kind != PointerKind {
+			return e.Structure(base)
+		}
+	case ReferenceKind:
+		return e.mod.GetStructure(typ.Reference.Name)
+	case StructKind:
+		return typ.Structure, true
+	}
+	return nil, false
+}
+
+// MethodCall works out whether the given call is a method
+// call, i.e. recv.method(args), returning the receiver value
+// and the method. the parent is set if the receiver is a
+// structure even if the method does not exist.
+func (e *Env) MethodCall(c *Call) (recv *Value, method *Function, parent *Structure) {
+	if c.Left.Kind != PathValue {
+		return nil, nil, nil
+	}
+
+	values := c.Left.Path.Values
+	name := values[len(values)-1]
+	if name.Kind != IdentifierValue {
+		return nil, nil, nil
+	}
+
+	recv = values[0]
+	if len(values) > 2 {
+		recv = &Value{Kind: PathValue, Path: NewPath(values[:len(values)-1])}
+	}
+
+	parent, ok := e.Structure(e.TypeOf(recv))
+	if !ok {
+		// a method without a receiver is called on
+		// its structure, e.g. Foo.make(), there's no
+		// receiver value and no method is returned.
+		if parent, ok = e.typeName(recv); ok {
+			return nil, nil, parent
+		}
+		return nil, nil, nil
+	}
+
+	impl, ok := e.mod.GetImpl(parent.Name.Value)
+	if !ok {
+		return recv, nil, parent
+	}
+
+	method, ok = impl.Methods[name.Identifier.Name.Value]
+	if !ok || method.Receiver == nil {
+		return recv, nil, parent
+	}
+	return recv, method, parent
+}
+
+// UseTypes makes the env take the type of any value in the
+// given type map from the map rather than working it out.
+func (e *Env) UseTypes(types *TypeMap) {
+	e.types = types
+}
+
+// functionType returns the type of the function or method
+// with the given name in the structure, or nil if none.
+func (e *Env) functionType(st *Structure, name string) *Type {
+	var fn *Function
+	if st == nil {
+		fn = e.mod.Functions[name]
+	} else if impl, ok := e.mod.GetImpl(st.Name.Value); ok {
+		fn = impl.Methods[name]
+	}
+
+	if fn == nil {
+		return nil
+	}
+	return &Type{Kind: FunctionKind, Function: fn}
+}
+
+// typeName returns the structure that the value names, if it's
+// the name of a structure and not of a local, e.g. Foo in Foo.make.
+func (e *Env) typeName(v *Value) (*Structure, bool) {
+	if v.Kind != IdentifierValue {
+		return nil, false
+	}
+	name := v.Identifier.Name.Value
+	if _, ok := e.lookup(name); ok {
+		return nil, false
+	}
+	return e.mod.GetStructure(name)
+}
+
+func (e *Env) typeOfPath(p *Path) *Type {
+	// a method can be named on its structure, e.g. Foo.make.
+	if st, ok := e.typeName(p.Values[0]); ok {
+		if len(p.Values) != 2 || p.Values[1].Kind != IdentifierValue {
+			return nil
+		}
+		return e.functionType(st, p.Values[1].Identifier.Name.Value)
+	}
+
+	typ := e.TypeOf(p.Values[0])
+	for i, val := range p.Values[1:] {
+		if val.Kind != IdentifierValue {
+			return nil
+		}
+
+		st, ok := e.Structure(typ)
+		if !ok {
+			return nil
+		}
+
+		name := val.Identifier.Name.Value
+		field := st.Fields.Get(name)
+		if field == nil {
+			// the last value may be a method.
+			if i == len(p.Values)-2 {
+				return e.functionType(st, name)
+			}
+			return nil
+		}
+		typ = field.Type
+	}
+	return typ
+}
+
+// TypeOf works out the type of the given value, nil is
+// returned if the type cannot be worked out.
+func (e *Env) TypeOf(v *Value) *Type {
+	if typ := e.types.TypeOf(v); typ != nil {
+		return typ
+	}
+
+	switch v.Kind {
+	case IntegerValueValue, FloatingValueValue, CharacterValueValue, StringValueValue, BooleanValueValue:
+		return v.InferredType()
+
+	case IdentifierValue:
+		name := v.Identifier.Name.Value
+		if typ := e.Lookup(name); typ != nil {
+			return typ
+		}
+		// a function can be named, e.g. to call it.
+		return e.functionType(nil, name)
+
+	case GroupingValue:
+		return e.TypeOf(v.Grouping.Val)
+
+	case CastValue:
+		return v.Cast.Type
+
+	case PathValue:
+		return e.typeOfPath(v.Path)
+
+	case UnaryExpressionValue:
+		u := v.UnaryExpression
+		typ := e.TypeOf(u.Val)
+		switch u.Op {
+		case "!":
+			return Bool
+		case "&":
+			if typ == nil {
+				return nil
+			}
+			return &Type{Kind: PointerKind, Pointer: NewPointerType(typ)}
+		case "@":
+			if typ == nil || typ.Kind != PointerKind {
+				return nil
+			}
+			return typ.Pointer.Base
+		}
+		return typ
+
+	case BinaryExpressionValue:
+		bin := v.BinaryExpression
+		if IsComparison(bin.Op) {
+			return Bool
+		}
+
+		lh, rh := e.TypeOf(bin.LHand), e.TypeOf(bin.RHand)
+		if lh == nil || rh == nil {
+			return nil
+		}
+
+		// a literal takes on the type of the other
+		// side if it can be converted to it.
+		if _, ok := IntegerLiteral(bin.LHand); ok && e.Assignable(bin.LHand, rh) {
+			return rh
+		}
+		if _, ok := IntegerLiteral(bin.RHand); ok && e.Assignable(bin.RHand, lh) {
+			return lh
+		}
+		return Widest(lh, rh)
+
+	case CallValue:
+		if iden := v.Call.Left.Identifier; iden != nil {
+			if fn, ok := e.mod.Functions[iden.Name.Value]; ok {
+				return fn.ReturnType
+			}
+		}
+		if _, method, _ := e.MethodCall(v.Call); method != nil {
+			return method.ReturnType
+		}
+		if typ := e.TypeOf(v.Call.Left); typ != nil && typ.Kind == FunctionKind {
+			return typ.Function.ReturnType
+		}
+
+	case IndexValue:
+		return ElementType(e.TypeOf(v.Index.Left))
+
+	case SliceValue:
+		base := ElementType(e.TypeOf(v.Slice.Left))
+		if base == nil {
+			return nil
+		}
+		return &Type{Kind: SliceKind, Slice: NewSliceType(base)}
+
+	case InitValue:
+		return v.Init.InferredType()
+
+	case BuiltinValue:
+		return e.typeOfBuiltin(v.Builtin)
+
+	case AssignValue:
+		return e.TypeOf(v.Assign.LHand)
+	}
+
+	return nil
+}
+
+func (e *Env) typeOfBuiltin(b *Builtin) *Type {
+	name := b.Iden.Name.Value
+
+	switch b.Name {
+	case "sizeof", "len":
+		return Uint64
+	case "free":
+		return Void
+	case "move", "ref":
+		return e.Lookup(name)
+	case "alloc":
+		// the identifier names the type being allocated.
+		base, ok := PrimitiveType[name]
+		if !ok {
+			base = &Type{Kind: ReferenceKind, Reference: NewReferenceType(name)}
+		}
+		return &Type{Kind: PointerKind, Pointer: NewPointerType(base)}
+	}
+	return nil
+}
+
+// Assignable returns whether the value can be implicitly converted
+// to the given type, see the package func Assignable.
+func (e *Env) Assignable(v *Value, to *Type) bool {
+	return Assignable(v, e.TypeOf(v), to)
+}
+
+// Assignable returns whether the value v of type from can be
+// implicitly converted to the given type. integer literals
+// convert to any integer type they fit in, and to any floating
+// type. floating literals can be converted to any floating type.
+func Assignable(v *Value, from *Type, to *Type) bool {
+	if to == nil {
+		return true
+	}
+
+	if lit, ok := IntegerLiteral(v); ok {
+		switch to.Kind {
+		case IntegerKind:
+			return IntegerFits(lit.RawValue, to.IntegerType)
+		case FloatKind:
+			return true
+		}
+	}
+	if v.Kind == FloatingValueValue && to.Kind == FloatKind {
+		return true
+	}
+
+	if from == nil {
+		return true
+	}
+	return Widens(from, to)
+}
@@ -0,0 +1,58 @@
+package ir
+
+import (
+	"sync"
+)
+
+// IDSource hands out the ids of the blocks and symbol
+// tables in a module. ids are handed out in order from
+// zero, so building the same module twice gives the same
+// ids, and they're unique within the module.
+type IDSource struct {
+	mu   sync.Mutex
+	Next uint64 `json:"next"`
+}
+
+// NextID returns a new id from the source.
+func (s *IDSource) NextID() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.Next
+	s.Next++
+	return id
+}
+
+func NewIDSource() *IDSource {
+	return &IDSource{}
+}
+
+// skip moves the source past an id that's already in use.
+func (s *IDSource) skip(id uint64) {
+	if id >= s.Next {
+		s.Next = id + 1
+	}
+}
+
+// IDSource returns the source of ids for the module. a module
+// that was sent without one will get a source that starts after
+// the largest block or symbol table id already in the module.
+func (m *Module) IDSource() *IDSource {
+	if m.IDs != nil {
+		return m.IDs
+	}
+
+	ids := NewIDSource()
+	Inspect(m, func(n Node) bool {
+		if b, ok := n.(*Block); ok {
+			ids.skip(b.ID)
+			if b.Stab != nil {
+				ids.skip(uint64(b.Stab.ID))
+			}
+		}
+		return true
+	})
+
+	m.IDs = ids
+	return ids
+}
@@ -0,0 +1,99 @@
+package ir
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const idsSource = `type Point = struct {
+	x i32,
+	y i32,
+};
+
+fn f(n i32) i32 {
+	mut i = 0;
+	while i < n {
+		if i == 2 {
+			i = i + 2;
+		} else {
+			i = i + 1;
+		}
+	}
+	return i;
+}
+
+fn main() {
+	let p = :Point{x: 1, y: 2};
+	{
+		let q = f(3);
+	}
+}`
+
+// blockIDsOf returns the id of every block in the module.
+func blockIDsOf(mod *Module) []uint64 {
+	res := []uint64{}
+	Inspect(mod, func(n Node) bool {
+		if b, ok := n.(*Block); ok {
+			res = append(res, b.ID)
+		}
+		return true
+	})
+	return res
+}
+
+func TestIDsAreStable(t *testing.T) {
+	a := buildModule(t, idsSource)
+	b := buildModule(t, idsSource)
+	assert.Equal(t, blockIDsOf(a), blockIDsOf(b))
+
+	aJSON, err := json.Marshal(a)
+	assert.NoError(t, err)
+	bJSON, err := json.Marshal(b)
+	assert.NoError(t, err)
+	assert.Equal(t, string(aJSON), string(bJSON))
+}
+
+func TestIDsAreUnique(t *testing.T) {
+	mod := buildModule(t, idsSource)
+
+	seen := map[uint64]bool{}
+	for _, id := range blockIDsOf(mod) {
+		assert.False(t, seen[id], "block id %d is used twice", id)
+		assert.Less(t, id, mod.IDSource().Next)
+		seen[id] = true
+	}
+}
+
+func TestIDSourceWithoutIDs(t *testing.T) {
+	mod := buildModule(t, idsSource)
+	next := mod.IDSource().Next
+	mod.IDs = nil
+
+	data, err := json.Marshal(mod)
+	assert.NoError(t, err)
+
+	// the source is worked out from the largest id
+	// in a module that was sent without one.
+	var sent Module
+	assert.NoError(t, json.Unmarshal(data, &sent))
+	assert.Nil(t, sent.IDs)
+	assert.Equal(t, next, sent.IDSource().Next)
+
+	id := sent.IDSource().NextID()
+	assert.NotContains(t, blockIDsOf(&sent), id)
+	assert.Equal(t, sent.IDs, sent.IDSource())
+}
+
+func TestIDSourceSkipsSymbolTables(t *testing.T) {
+	mod := buildModule(t, `fn main() {
+	let a = 1;
+}`)
+	mod.IDs = nil
+
+	// a symbol table can have a larger id than any block.
+	body := mod.Functions["main"].Body
+	body.Stab = &SymbolTable{ID: int(body.ID) + 10}
+	assert.Equal(t, body.ID+11, mod.IDSource().Next)
+}
@@ -67,33 +67,24 @@ type Block struct {
 	DeferStack []*Defer       `json:"deferStack,omitempty"`
 	Instr      []*Instruction `json:"instr,omitempty"`
 	Stab       *SymbolTable   `json:"stab,omitempty"`
-	Return     *Instruction   `json:"return,omitempty"`
 }
 
 func (b *Block) PushDefer(def *Defer) {
 	b.DeferStack = append(b.DeferStack, def)
 }
 
-func (b *Block) SetReturn(ret *Instruction) {
-	b.Return = ret
-}
-
 func (b *Block) AddInstr(instr *Instruction) {
 	b.Instr = append(b.Instr, instr)
 }
 
-var blockIota uint64
-
-func NewBlock() *Block {
-	res := &Block{
-		blockIota,
+// NewBlock creates a new block with an id from the given source.
+func NewBlock(ids *IDSource) *Block {
+	return &Block{
+		ids.NextID(),
 		[]*Defer{},
 		[]*Instruction{},
 		nil,
-		nil,
 	}
-	blockIota++
-	return res
 }
 
 // ASSIGN
@@ -104,8 +95,8 @@ type Assign struct {
 	RHand *Value
 }
 
-func (a *Assign) InferredType() Type {
-	panic("handle this in sema?")
+func (a *Assign) InferredType() *Type {
+	return a.LHand.InferredType()
 }
 
 func NewAssign(lh *Value, op string, rh *Value) *Assign {
@@ -131,10 +122,15 @@ func NewJump(loc front.Token) *Jump {
 type Defer struct {
 	Stat  *Instruction
 	Block *Block
+
+	// After is the number of instructions in the block
+	// before the defer, the defer only runs if the block
+	// is left after this point.
+	After int
 }
 
 func NewDefer(stat *Instruction, block *Block) *Defer {
-	return &Defer{stat, block}
+	return &Defer{stat, block, 0}
 }
 
 // LOCAL
@@ -195,44 +191,56 @@ func NewAlloca(name front.Token, mutable bool, owned bool, typ *Type) *Alloca {
 
 // NEXT, BREAK
 
-type Next struct{}
+// Next and Break refer to the innermost loop,
+// or the loop with the given label if set.
+
+type Next struct {
+	Label *front.Token `json:"label,omitempty"`
+}
 
-func NewNext() *Next { return &Next{} }
+func NewNext(label *front.Token) *Next { return &Next{label} }
 
-type Break struct{}
+type Break struct {
+	Label *front.Token `json:"label,omitempty"`
+}
 
-func NewBreak() *Break { return &Break{} }
+func NewBreak(label *front.Token) *Break { return &Break{label} }
 
 // RETURN
 
 type Return struct {
 	Val *Value
+
+	// Span is where the return was written.
+	Span []int `json:"span,omitempty"`
 }
 
 func NewReturn(val *Value) *Return {
-	return &Return{val}
+	return &Return{val, nil}
 }
 
 // LOOP
 
 type Loop struct {
-	Body *Block
+	Body  *Block
+	Label *front.Token `json:"label,omitempty"`
 }
 
-func NewLoop(body *Block) *Loop {
-	return &Loop{body}
+func NewLoop(body *Block, label *front.Token) *Loop {
+	return &Loop{body, label}
 }
 
 // WHILE LOOP
 
 type WhileLoop struct {
-	Cond *Value
-	Post *Value
-	Body *Block
+	Cond  *Value
+	Post  *Value
+	Body  *Block
+	Label *front.Token `json:"label,omitempty"`
 }
 
-func NewWhileLoop(cond *Value, post *Value, body *Block) *WhileLoop {
-	return &WhileLoop{cond, post, body}
+func NewWhileLoop(cond *Value, post *Value, body *Block, label *front.Token) *WhileLoop {
+	return &WhileLoop{cond, post, body, label}
 }
 
 // ELSE IF
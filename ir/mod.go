@@ -17,10 +17,20 @@ type Module struct {
 	Impls          map[string]*Impl      `json:"impls,omitempty"`
 	ImplsOrder     []front.Token         `json:"impls_order,omitempty"`
 	Global         *Block                `json:"global,omitempty"`
+
+	// IDs is where the ids of the blocks and
+	// symbol tables in the module come from.
+	IDs *IDSource `json:"ids,omitempty"`
+
+	// Includes and LinkFlags are collected from the
+	// #{include} and #{link} directives in the module.
+	Includes  []*front.IncludeDirective `json:"includes,omitempty"`
+	LinkFlags []string                  `json:"link_flags,omitempty"`
 }
 
 // NewModule creates a new module with the given name
 func NewModule(name string) *Module {
+	ids := NewIDSource()
 	return &Module{
 		name,
 
@@ -33,7 +43,11 @@ func NewModule(name string) *Module {
 		map[string]*Impl{},
 		[]front.Token{},
 
-		NewBlock(),
+		NewBlock(ids),
+		ids,
+
+		[]*front.IncludeDirective{},
+		[]string{},
 	}
 }
 
@@ -68,3 +82,48 @@ func (m *Module) RegisterFunction(f *Function) {
 	m.FunctionOrder = append(m.FunctionOrder, f.Name)
 	m.Functions[f.Name.Value] = f
 }
+
+// UnclaimedMethods returns the methods of any impls that don't
+// name a structure in the module, in the order they were declared.
+func (m *Module) UnclaimedMethods() []*UnclaimedMethod {
+	res := []*UnclaimedMethod{}
+	for _, name := range m.ImplsOrder {
+		if _, ok := m.GetStructure(name.Value); ok {
+			continue
+		}
+
+		impl := m.Impls[name.Value]
+		for _, method := range impl.Order {
+			res = append(res, &UnclaimedMethod{name.Value, impl.Methods[method.Value]})
+		}
+	}
+	return res
+}
+
+// RegisterInclude registers the given include with the module,
+// includes that have already been registered are ignored.
+func (m *Module) RegisterInclude(inc *front.IncludeDirective) {
+	for _, other := range m.Includes {
+		if other.Path == inc.Path && other.System == inc.System {
+			return
+		}
+	}
+	m.Includes = append(m.Includes, inc)
+}
+
+// RegisterLinkFlags registers the given linker flags with
+// the module, ignoring any flags that have already been registered.
+func (m *Module) RegisterLinkFlags(flags ...string) {
+	for _, flag := range flags {
+		seen := false
+		for _, other := range m.LinkFlags {
+			if other == flag {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			m.LinkFlags = append(m.LinkFlags, flag)
+		}
+	}
+}
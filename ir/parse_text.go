@@ -0,0 +1,821 @@
+package ir
+
+import (
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/scanner"
+
+	"github.com/krug-lang/caasper/api"
+	"github.com/krug-lang/caasper/front"
+)
+
+// the kind of the symbols made of two characters,
+// other symbols are their own rune.
+const opToken = -100
+
+var textOps = map[string]bool{
+	"==": true, "!=": true, "<=": true, ">=": true,
+	"&&": true, "||": true, "<<": true, ">>": true, "..": true,
+	"+=": true, "-=": true, "*=": true, "/=": true, "%=": true,
+}
+
+var assignOps = map[string]bool{
+	"=": true, "+=": true, "-=": true, "*=": true, "/=": true, "%=": true,
+}
+
+var sizedType = regexp.MustCompile(`^[iuf][0-9]+$`)
+
+type textToken struct {
+	kind rune
+	text string
+	span []int
+}
+
+type textParser struct {
+	toks []textToken
+	pos  int
+	mod  *Module
+
+	// impl is the name of the impl whose
+	// methods are being parsed.
+	impl string
+}
+
+// textError is used to unwind the parser on the
+// first error, see ParseText.
+type textError struct {
+	err api.CompilerError
+}
+
+func lexText(src string) (toks []textToken, errs []api.CompilerError) {
+	var s scanner.Scanner
+	s.Init(strings.NewReader(src))
+	s.Mode = scanner.GoTokens
+	s.Error = func(s *scanner.Scanner, msg string) {
+		errs = append(errs, api.NewParseError(msg, s.Position.Offset))
+	}
+
+	for kind := s.Scan(); kind != scanner.EOF; kind = s.Scan() {
+		start := s.Position.Offset
+		tok := textToken{kind, s.TokenText(), []int{start, start + len(s.TokenText())}}
+
+		// join symbols like == that are written together,
+		// the scanner returns the rune of single symbols.
+		if n := len(toks); n > 0 && kind > 0 {
+			prev := &toks[n-1]
+			if prev.kind > 0 && prev.span[1] == start && textOps[prev.text+tok.text] {
+				prev.kind = opToken
+				prev.text += tok.text
+				prev.span[1] = tok.span[1]
+				continue
+			}
+		}
+		toks = append(toks, tok)
+	}
+
+	toks = append(toks, textToken{scanner.EOF, "<eof>", []int{len(src), len(src)}})
+	return toks, errs
+}
+
+func (p *textParser) fail(err api.CompilerError) {
+	panic(textError{err})
+}
+
+func (p *textParser) next() textToken {
+	return p.toks[p.pos]
+}
+
+func (p *textParser) peek(offs int) textToken {
+	if p.pos+offs >= len(p.toks) {
+		return p.toks[len(p.toks)-1]
+	}
+	return p.toks[p.pos+offs]
+}
+
+func (p *textParser) consume() textToken {
+	tok := p.next()
+	if tok.kind != scanner.EOF {
+		p.pos++
+	}
+	return tok
+}
+
+// is returns whether the next token is one of the given
+// symbols or words. string literals never match.
+func (p *textParser) is(texts ...string) bool {
+	tok := p.next()
+	if tok.kind == scanner.String || tok.kind == scanner.RawString || tok.kind == scanner.Char {
+		return false
+	}
+	for _, text := range texts {
+		if tok.text == text {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *textParser) expect(text string) textToken {
+	if !p.is(text) {
+		tok := p.next()
+		p.fail(api.NewUnexpectedToken(tok.text, text, tok.span...))
+	}
+	return p.consume()
+}
+
+func (p *textParser) expectIdent() front.Token {
+	tok := p.next()
+	if tok.kind != scanner.Ident {
+		p.fail(api.NewUnexpectedToken(tok.text, "identifier", tok.span...))
+	}
+	p.consume()
+	return front.Token{Value: tok.text, Kind: front.Identifier, Span: tok.span}
+}
+
+func (p *textParser) expectString() string {
+	tok := p.next()
+	if tok.kind != scanner.String && tok.kind != scanner.RawString {
+		p.fail(api.NewUnexpectedToken(tok.text, "string", tok.span...))
+	}
+	p.consume()
+
+	res, err := strconv.Unquote(tok.text)
+	if err != nil {
+		p.fail(api.NewParseError("string", tok.span...))
+	}
+	return res
+}
+
+// TYPES
+
+func (p *textParser) parseType() *Type {
+	tok := p.next()
+
+	switch {
+	case p.is("_"):
+		p.consume()
+		return nil
+
+	case p.is("*"):
+		p.consume()
+		return &Type{Kind: PointerKind, Pointer: NewPointerType(p.parseType())}
+
+	case p.is("#"):
+		p.consume()
+		return &Type{Kind: ReferenceKind, Reference: NewReferenceType(p.expectIdent().Value)}
+
+	case p.is("["):
+		p.consume()
+		if p.is("]") {
+			p.consume()
+			return &Type{Kind: SliceKind, Slice: NewSliceType(p.parseType())}
+		}
+		base := p.parseType()
+		p.expect(";")
+		size := p.parseValue()
+		p.expect("]")
+		return &Type{Kind: ArrayKind, ArrayType: NewArrayType(base, size)}
+
+	case p.is("("):
+		p.consume()
+		types := []*Type{}
+		for !p.is(")") {
+			types = append(types, p.parseType())
+			if !p.is(")") {
+				p.expect(",")
+			}
+		}
+		p.expect(")")
+		return &Type{Kind: TupleKind, Tuple: NewTupleType(types)}
+
+	case p.is("struct"):
+		p.consume()
+		name := p.expectIdent()
+		if !p.is("{") {
+			st, ok := p.mod.Structures[name.Value]
+			if !ok {
+				p.fail(api.NewUnresolvedSymbol(name.Value, name.Span...))
+			}
+			return &Type{Kind: StructKind, Structure: st}
+		}
+		return &Type{Kind: StructKind, Structure: NewStructure(name, p.parseFields())}
+
+	case p.is("void", "str", "bool"):
+		p.consume()
+		return PrimitiveType[tok.text]
+
+	case tok.kind == scanner.Ident && sizedType.MatchString(tok.text):
+		p.consume()
+		if typ, ok := PrimitiveType[tok.text]; ok {
+			return typ
+		}
+
+		width, _ := strconv.Atoi(tok.text[1:])
+		if tok.text[0] == 'f' {
+			return &Type{Kind: FloatKind, FloatingType: NewFloatingType(width)}
+		}
+		return &Type{Kind: IntegerKind, IntegerType: NewIntegerType(width, tok.text[0] == 'i')}
+	}
+
+	p.fail(api.NewUnexpectedToken(tok.text, "type", tok.span...))
+	return nil
+}
+
+// parseFields parses the fields of a structure, e.g.
+// { mut name str; mut age i32 = 3; }
+func (p *textParser) parseFields() *TypeDict {
+	fields := newTypeDict()
+	p.expect("{")
+	for !p.is("}") {
+		fields.Add(p.parseLocal())
+		p.expect(";")
+	}
+	p.expect("}")
+	return fields
+}
+
+// parseLocal parses a let or mut without the semicolon.
+func (p *textParser) parseLocal() *Local {
+	mutable := p.is("mut")
+	if !mutable {
+		p.expect("let")
+	} else {
+		p.consume()
+	}
+
+	owned := p.is("owned") && p.peek(1).kind == scanner.Ident
+	if owned {
+		p.consume()
+	}
+
+	local := NewLocal(p.expectIdent(), nil, owned)
+	local.SetMutable(mutable)
+	if !p.is("=", ";", ",", "}") {
+		local.Type = p.parseType()
+	}
+	if p.is("=") {
+		p.consume()
+		local.SetValue(p.parseValue())
+	}
+	return local
+}
+
+func (p *textParser) parseParam() *Local {
+	mutable := p.is("mut")
+	if mutable {
+		p.consume()
+	}
+	owned := p.is("owned") && p.peek(1).kind == scanner.Ident
+	if owned {
+		p.consume()
+	}
+
+	local := NewLocal(p.expectIdent(), p.parseType(), owned)
+	local.SetMutable(mutable)
+	return local
+}
+
+// VALUES
+
+func (p *textParser) parseValues(end string) []*Value {
+	vals := []*Value{}
+	for !p.is(end) {
+		vals = append(vals, p.parseValue())
+		if !p.is(end) {
+			p.expect(",")
+		}
+	}
+	p.expect(end)
+	return vals
+}
+
+func (p *textParser) parseValue() *Value {
+	if p.is("-", "+", "!", "~", "&", "@", "*", "^") {
+		op := p.next()
+
+		// a minus written against a number is part of it.
+		num := p.peek(1)
+		if op.text == "-" && num.span[0] == op.span[1] && (num.kind == scanner.Int || num.kind == scanner.Float) {
+			return p.parsePostfix()
+		}
+
+		p.consume()
+		return &Value{
+			Kind:            UnaryExpressionValue,
+			UnaryExpression: NewUnaryExpression(op.text, p.parseValue()),
+		}
+	}
+	return p.parsePostfix()
+}
+
+func (p *textParser) parsePostfix() *Value {
+	left := p.parsePrimary()
+
+	// only a path made here can be extended with more
+	// values, a path in braces is a value of its own.
+	extend := false
+	for {
+		start := p.next().span[0]
+
+		switch {
+		case p.is("."):
+			p.consume()
+			var val *Value
+			if p.is("{") {
+				p.consume()
+				val = p.parseValue()
+				p.expect("}")
+			} else {
+				val = &Value{Kind: IdentifierValue, Identifier: NewIdentifier(p.expectIdent())}
+			}
+
+			if extend {
+				left.Path.Values = append(left.Path.Values, val)
+			} else {
+				left = &Value{Kind: PathValue, Path: NewPath([]*Value{left, val})}
+				extend = true
+			}
+			continue
+
+		case p.is("("):
+			p.consume()
+			left = &Value{Kind: CallValue, Call: NewCall(left, p.parseValues(")"))}
+
+		case p.is("["):
+			p.consume()
+			var low, high *Value
+			if !p.is("..") {
+				low = p.parseValue()
+			}
+			if !p.is("..") {
+				end := p.expect("]")
+				left = &Value{Kind: IndexValue, Index: NewIndex(left, low, []int{start, end.span[1]})}
+				break
+			}
+			p.consume()
+			if !p.is("]") {
+				high = p.parseValue()
+			}
+			end := p.expect("]")
+			left = &Value{Kind: SliceValue, Slice: NewSlice(left, low, high, []int{start, end.span[1]})}
+
+		default:
+			return left
+		}
+		extend = false
+	}
+}
+
+func (p *textParser) parsePrimary() *Value {
+	tok := p.next()
+
+	switch {
+	case tok.kind == scanner.Int || tok.kind == scanner.Float || p.is("-"):
+		return p.parseNumber()
+
+	case tok.kind == scanner.String || tok.kind == scanner.RawString:
+		p.consume()
+		return &Value{Kind: StringValueValue, StringValue: NewStringValue(tok.text)}
+
+	case tok.kind == scanner.Char:
+		p.consume()
+		return &Value{Kind: CharacterValueValue, CharacterValue: NewCharacterValue(tok.text)}
+
+	case p.is("str"):
+		p.consume()
+		return &Value{Kind: StringValueValue, StringValue: NewStringValue(p.expectString())}
+
+	case p.is("true", "false"):
+		p.consume()
+		return &Value{Kind: BooleanValueValue, BooleanValue: NewBooleanValue(tok.text == "true")}
+
+	case p.is("char"):
+		p.consume()
+		return &Value{Kind: CharacterValueValue, CharacterValue: NewCharacterValue(p.expectString())}
+
+	case p.is("{"):
+		p.consume()
+		val := p.parseValue()
+		p.expect("}")
+		return val
+
+	case p.is(":"):
+		return p.parseInit()
+
+	case p.is("("):
+		return p.parseParens()
+
+	case tok.kind == scanner.Ident:
+		name := p.expectIdent()
+		if bang := p.next(); bang.text == "!" && bang.span[0] == tok.span[1] {
+			p.consume()
+			return p.parseBuiltin(name.Value)
+		}
+		return &Value{Kind: IdentifierValue, Identifier: NewIdentifier(name)}
+	}
+
+	p.fail(api.NewUnexpectedToken(tok.text, "value", tok.span...))
+	return nil
+}
+
+func (p *textParser) parseNumber() *Value {
+	neg := ""
+	if p.is("-") {
+		neg = p.consume().text
+	}
+
+	tok := p.consume()
+	if tok.kind == scanner.Float {
+		val, err := strconv.ParseFloat(neg+tok.text, 64)
+		if err != nil {
+			p.fail(api.NewParseError("float", tok.span...))
+		}
+		return &Value{Kind: FloatingValueValue, FloatingValue: NewFloatingValue(val)}
+	}
+
+	val, ok := new(big.Int).SetString(neg+tok.text, 0)
+	if !ok {
+		p.fail(api.NewParseError("integer", tok.span...))
+	}
+	return &Value{Kind: IntegerValueValue, IntegerValue: NewIntegerValue(val)}
+}
+
+// parseParens parses a grouping, a binary expression,
+// an assignment or a cast, e.g. (a), (a + b), (a as T)
+func (p *textParser) parseParens() *Value {
+	p.expect("(")
+	left := p.parseValue()
+
+	if p.is(")") {
+		p.consume()
+		return &Value{Kind: GroupingValue, Grouping: NewGrouping(left)}
+	}
+
+	if p.is("as") {
+		p.consume()
+		typ := p.parseType()
+		p.expect(")")
+		return &Value{Kind: CastValue, Cast: NewCast(left, typ)}
+	}
+
+	op := p.consume()
+	if op.kind != opToken && (op.kind < 0 || op.kind == scanner.EOF) {
+		p.fail(api.NewUnexpectedToken(op.text, "operator", op.span...))
+	}
+	right := p.parseValue()
+	p.expect(")")
+
+	if assignOps[op.text] {
+		return &Value{Kind: AssignValue, Assign: NewAssign(left, op.text, right)}
+	}
+	return &Value{Kind: BinaryExpressionValue, BinaryExpression: NewBinaryExpression(left, op.text, right)}
+}
+
+func (p *textParser) parseBuiltin(name string) *Value {
+	args := []*Value{}
+
+	var iden front.Token
+	if p.is("(") {
+		p.consume()
+		iden = p.expectIdent()
+		if p.is(",") {
+			p.consume()
+			args = p.parseValues(")")
+		} else {
+			p.expect(")")
+		}
+	} else {
+		iden = p.expectIdent()
+	}
+
+	return &Value{Kind: BuiltinValue, Builtin: NewBuiltin(name, NewIdentifier(iden), args)}
+}
+
+// parseInit parses an initializer, e.g. :Person{name: "x"},
+// :(a, b) or :[a, b]. values after a semicolon were filled
+// in from the defaults of the fields.
+func (p *textParser) parseInit() *Value {
+	p.expect(":")
+
+	kind, end := front.InitStructure, "}"
+	var iden *Identifier
+	switch {
+	case p.is("("):
+		kind, end = front.InitTuple, ")"
+	case p.is("["):
+		kind, end = front.InitArray, "]"
+	default:
+		iden = NewIdentifier(p.expectIdent())
+		if !p.is("{") {
+			p.expect("{")
+		}
+	}
+	p.consume()
+
+	var names []front.Token
+	vals := []*Value{}
+	defaults := -1
+	for !p.is(end) {
+		if p.is(";") && defaults < 0 {
+			p.consume()
+			defaults = 0
+			continue
+		}
+
+		if p.next().kind == scanner.Ident && p.peek(1).text == ":" {
+			names = append(names, p.expectIdent())
+			p.consume()
+		}
+		vals = append(vals, p.parseValue())
+		if defaults >= 0 {
+			defaults++
+		}
+
+		if !p.is(end, ";") {
+			p.expect(",")
+		}
+	}
+	p.expect(end)
+
+	init := NewInit(kind, iden, names, vals)
+	if defaults > 0 {
+		init.Defaults = defaults
+	}
+	return &Value{Kind: InitValue, Init: init}
+}
+
+// INSTRUCTIONS
+
+func (p *textParser) parseBlock() *Block {
+	block := NewBlock(p.mod.IDs)
+	p.expect("{")
+	for !p.is("}") {
+		if p.next().kind == scanner.EOF {
+			p.expect("}")
+		}
+
+		instr := p.parseInstr()
+		if instr.Kind == DeferInstr {
+			instr.Defer.After = len(block.Instr)
+			block.PushDefer(instr.Defer)
+			continue
+		}
+		block.AddInstr(instr)
+	}
+	p.expect("}")
+	return block
+}
+
+// parseTarget parses the optional label of a break or next.
+func (p *textParser) parseTarget() *front.Token {
+	if p.is(";") {
+		return nil
+	}
+	label := p.expectIdent()
+	return &label
+}
+
+func (p *textParser) parseInstr() *Instruction {
+	var label *front.Token
+	if p.next().kind == scanner.Ident && p.peek(1).text == ":" {
+		tok := p.expectIdent()
+		label = &tok
+		p.consume()
+		if !p.is("loop", "while") {
+			p.expect("loop")
+		}
+	}
+
+	switch {
+	case p.is("{"):
+		return &Instruction{Kind: BlockInstr, Block: p.parseBlock()}
+
+	case p.is("let", "mut"):
+		local := p.parseLocal()
+		p.expect(";")
+		return &Instruction{Kind: LocalInstr, Local: local}
+
+	case p.is("alloca"):
+		p.consume()
+		l := p.parseLocal()
+		p.expect(";")
+		alloca := NewAlloca(l.Name, l.Mutable, l.Owned, l.Type)
+		alloca.SetValue(l.Val)
+		return &Instruction{Kind: AllocaInstr, Alloca: alloca}
+
+	case p.is("return"):
+		keyword := p.consume()
+		var val *Value
+		if !p.is(";") {
+			val = p.parseValue()
+		}
+		p.expect(";")
+		ret := NewReturn(val)
+		ret.Span = keyword.span
+		return &Instruction{Kind: ReturnInstr, Return: ret}
+
+	case p.is("break"):
+		p.consume()
+		res := &Instruction{Kind: BreakInstr, Break: NewBreak(p.parseTarget())}
+		p.expect(";")
+		return res
+
+	case p.is("next"):
+		p.consume()
+		res := &Instruction{Kind: NextInstr, Next: NewNext(p.parseTarget())}
+		p.expect(";")
+		return res
+
+	case p.is("jump"):
+		p.consume()
+		res := &Instruction{Kind: JumpInstr, Jump: NewJump(p.expectIdent())}
+		p.expect(";")
+		return res
+
+	case p.is("$"):
+		p.consume()
+		res := &Instruction{Kind: LabelInstr, Label: NewLabel(p.expectIdent())}
+		p.expect(";")
+		return res
+
+	case p.is("type"):
+		p.consume()
+		name := p.expectIdent()
+		p.expect("=")
+		typ := p.parseType()
+		p.expect(";")
+		return &Instruction{Kind: TypeAliasInstr, TypeAliasStatement: NewTypeAlias(name, typ)}
+
+	case p.is("defer"):
+		p.consume()
+		if p.is("{") {
+			return &Instruction{Kind: DeferInstr, Defer: NewDefer(nil, p.parseBlock())}
+		}
+		return &Instruction{Kind: DeferInstr, Defer: NewDefer(p.parseInstr(), nil)}
+
+	case p.is("loop"):
+		p.consume()
+		return &Instruction{Kind: LoopInstr, Loop: NewLoop(p.parseBlock(), label)}
+
+	case p.is("while"):
+		p.consume()
+		cond := p.parseValue()
+		var post *Value
+		if p.is(";") {
+			p.consume()
+			post = p.parseValue()
+		}
+		return &Instruction{Kind: WhileLoopInstr, WhileLoop: NewWhileLoop(cond, post, p.parseBlock(), label)}
+
+	case p.is("if"):
+		p.consume()
+		cond := p.parseValue()
+		body := p.parseBlock()
+
+		var elses []*ElseIfStatement
+		var els *Block
+		for p.is("elif") {
+			p.consume()
+			cond := p.parseValue()
+			elses = append(elses, NewElseIfStatement(cond, p.parseBlock()))
+		}
+		if p.is("else") {
+			p.consume()
+			els = p.parseBlock()
+		}
+		return &Instruction{Kind: IfStatementInstr, IfStatement: NewIfStatement(cond, body, elses, els)}
+	}
+
+	val := p.parseValue()
+	if op := p.next(); assignOps[op.text] {
+		p.consume()
+		res := &Instruction{Kind: AssignInstr, Assign: NewAssign(val, op.text, p.parseValue())}
+		p.expect(";")
+		return res
+	}
+	p.expect(";")
+	return &Instruction{Kind: ExpressionInstr, ExpressionStatement: val}
+}
+
+// DECLARATIONS
+
+func (p *textParser) parseFunction() *Function {
+	p.expect("fn")
+
+	var recv *Receiver
+	if p.is("[") {
+		p.consume()
+		kind := ""
+		for !p.is("]") {
+			if p.next().kind == scanner.EOF {
+				p.expect("]")
+			}
+			kind += p.consume().text
+		}
+		p.expect("]")
+
+		// e.g. mut *self is read as mut, *, self
+		kind = strings.Replace(kind, "mut*", "mut *", 1)
+		recv = NewReceiver(front.ReceiverKind(kind), p.impl)
+	}
+
+	ret := p.parseType()
+	name := p.expectIdent()
+
+	params := newTypeDict()
+	p.expect("(")
+	for !p.is(")") {
+		params.Add(p.parseParam())
+		if !p.is(")") {
+			p.expect(",")
+		}
+	}
+	p.expect(")")
+
+	fn := NewFunction(name, params, ret, p.parseBlock())
+	fn.Receiver = recv
+	return fn
+}
+
+func (p *textParser) parseModule() {
+	p.expect("module")
+	p.mod.Name = p.expectString()
+	p.expect(";")
+
+	for p.next().kind != scanner.EOF {
+		switch tok := p.next(); {
+		case p.is("include"):
+			p.consume()
+			system := p.is("system")
+			if system {
+				p.consume()
+			}
+			p.mod.RegisterInclude(&front.IncludeDirective{Path: p.expectString(), System: system})
+			p.expect(";")
+
+		case p.is("link"):
+			p.consume()
+			p.mod.RegisterLinkFlags(p.expectString())
+			p.expect(";")
+
+		case p.is("struct"):
+			p.consume()
+			name := p.expectIdent()
+			p.mod.RegisterStructure(NewStructure(name, p.parseFields()))
+
+		case p.is("global"):
+			p.consume()
+			p.mod.Global = p.parseBlock()
+
+		case p.is("fn"):
+			p.mod.RegisterFunction(p.parseFunction())
+
+		case p.is("impl"):
+			p.consume()
+			impl := NewImpl(p.expectIdent())
+			p.impl = impl.Name.Value
+
+			p.expect("{")
+			for p.is("fn") {
+				method := p.parseFunction()
+				if !impl.RegisterMethod(method) {
+					p.fail(api.NewSymbolError(method.Name.Value, method.Name.Span...))
+				}
+			}
+			p.expect("}")
+
+			if p.mod.RegisterImpl(impl) {
+				p.fail(api.NewSymbolError(impl.Name.Value, impl.Name.Span...))
+			}
+
+		default:
+			p.fail(api.NewUnexpectedToken(tok.text, "declaration", tok.span...))
+		}
+	}
+}
+
+// ParseText parses a module written in the textual form
+// of the IR, see Print. spans are offsets into the text.
+func ParseText(src string) (mod *Module, errs []api.CompilerError) {
+	toks, errs := lexText(src)
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	p := &textParser{toks: toks, mod: NewModule("")}
+
+	defer func() {
+		if r := recover(); r != nil {
+			failure, ok := r.(textError)
+			if !ok {
+				panic(r)
+			}
+			mod, errs = nil, []api.CompilerError{failure.err}
+		}
+	}()
+
+	p.parseModule()
+	return p.mod, nil
+}
@@ -0,0 +1,442 @@
+package ir
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/krug-lang/caasper/front"
+)
+
+/*
+	the textual form of a module, e.g.
+
+		module "main";
+		struct Person {
+			mut name str;
+			mut age i32 = 3;
+		}
+		fn i32 main() {
+			let p = :Person{name: "x"; age: 3};
+			return (p.age + 1);
+		}
+
+	binary expressions are always wrapped in parens and defers
+	are written where they are pushed, so the text can be parsed
+	back into the same module with ParseText. source positions,
+	block ids and symbol tables are not part of the text.
+*/
+
+type printer struct {
+	mod   *Module
+	buf   strings.Builder
+	depth int
+
+	// prefix is written before the next line, this
+	// is used to put defer in front of an instruction.
+	prefix string
+}
+
+func (p *printer) line(format string, args ...interface{}) {
+	p.buf.WriteString(strings.Repeat("\t", p.depth) + p.prefix)
+	p.prefix = ""
+	fmt.Fprintf(&p.buf, format, args...)
+	p.buf.WriteString("\n")
+}
+
+func (p *printer) typ(t *Type) string {
+	if t == nil {
+		return "_"
+	}
+
+	switch t.Kind {
+	case IntegerKind:
+		sign := "u"
+		if t.IntegerType.Signed {
+			sign = "i"
+		}
+		return fmt.Sprintf("%s%d", sign, t.IntegerType.Width)
+	case FloatKind:
+		return fmt.Sprintf("f%d", t.FloatingType.Width)
+	case VoidKind:
+		return "void"
+	case StringKind:
+		return "str"
+	case BoolKind:
+		return "bool"
+	case PointerKind:
+		return "*" + p.typ(t.Pointer.Base)
+	case SliceKind:
+		return "[]" + p.typ(t.Slice.Base)
+	case ArrayKind:
+		return fmt.Sprintf("[%s; %s]", p.typ(t.ArrayType.Base), p.value(t.ArrayType.Size))
+	case ReferenceKind:
+		return "#" + t.Reference.Name
+	case TupleKind:
+		types := make([]string, len(t.Tuple.Types))
+		for i, typ := range t.Tuple.Types {
+			types[i] = p.typ(typ)
+		}
+		return fmt.Sprintf("(%s)", strings.Join(types, ", "))
+	case StructKind:
+		// structures of the module are written once at the top.
+		st := t.Structure
+		if p.mod.Structures[st.Name.Value] == st {
+			return "struct " + st.Name.Value
+		}
+		fields := []string{}
+		for _, name := range st.Fields.Order {
+			fields = append(fields, p.local(st.Fields.Get(name.Value))+";")
+		}
+		return fmt.Sprintf("struct %s { %s }", st.Name.Value, strings.Join(fields, " "))
+	}
+	panic(fmt.Sprintf("unhandled type '%s' in Print", t.Kind))
+}
+
+// local writes a let or mut without the semicolon.
+func (p *printer) local(l *Local) string {
+	res := "let "
+	if l.Mutable {
+		res = "mut "
+	}
+	if l.Owned {
+		res += "owned "
+	}
+	res += l.Name.Value
+	if l.Type != nil {
+		res += " " + p.typ(l.Type)
+	}
+	if l.Val != nil {
+		res += " = " + p.value(l.Val)
+	}
+	return res
+}
+
+func (p *printer) param(l *Local) string {
+	res := ""
+	if l.Mutable {
+		res += "mut "
+	}
+	if l.Owned {
+		res += "owned "
+	}
+	return res + l.Name.Value + " " + p.typ(l.Type)
+}
+
+func (p *printer) values(vals []*Value) string {
+	res := make([]string, len(vals))
+	for i, val := range vals {
+		res[i] = p.value(val)
+	}
+	return strings.Join(res, ", ")
+}
+
+// negative returns whether the value is written with a
+// leading minus, which would be read as part of a literal.
+func negative(v *Value) bool {
+	switch v.Kind {
+	case IntegerValueValue:
+		return v.IntegerValue.RawValue.Sign() < 0
+	case FloatingValueValue:
+		return v.FloatingValue.Value < 0
+	}
+	return false
+}
+
+// operand writes the left of a call, index, slice or path,
+// values that would bind differently are wrapped in braces.
+// a path in a path would be read as one path.
+func (p *printer) operand(v *Value, inPath bool) string {
+	if v.Kind == UnaryExpressionValue || negative(v) || (inPath && v.Kind == PathValue) {
+		return "{" + p.value(v) + "}"
+	}
+	return p.value(v)
+}
+
+// literal writes the raw source text of a string or character
+// as is when it is already a valid quoted literal, otherwise it
+// is quoted and marked with the given keyword.
+func literal(raw string, keyword string, quotes string) string {
+	if raw != "" && strings.IndexByte(quotes, raw[0]) >= 0 {
+		if _, err := strconv.Unquote(raw); err == nil {
+			return raw
+		}
+	}
+	return keyword + " " + strconv.Quote(raw)
+}
+
+func (p *printer) value(v *Value) string {
+	switch v.Kind {
+	case IntegerValueValue:
+		return v.IntegerValue.RawValue.String()
+	case FloatingValueValue:
+		res := strconv.FormatFloat(v.FloatingValue.Value, 'g', -1, 64)
+		if !strings.ContainsAny(res, ".e") {
+			res += ".0"
+		}
+		return res
+	case StringValueValue:
+		return literal(v.StringValue.Value, "str", "\"`")
+	case CharacterValueValue:
+		return literal(v.CharacterValue.Value, "char", "'")
+	case BooleanValueValue:
+		return strconv.FormatBool(v.BooleanValue.Value)
+	case IdentifierValue:
+		return v.Identifier.Name.Value
+
+	case GroupingValue:
+		return "(" + p.value(v.Grouping.Val) + ")"
+	case BinaryExpressionValue:
+		bin := v.BinaryExpression
+		return fmt.Sprintf("(%s %s %s)", p.value(bin.LHand), bin.Op, p.value(bin.RHand))
+	case AssignValue:
+		a := v.Assign
+		return fmt.Sprintf("(%s %s %s)", p.value(a.LHand), a.Op, p.value(a.RHand))
+	case CastValue:
+		return fmt.Sprintf("(%s as %s)", p.value(v.Cast.Val), p.typ(v.Cast.Type))
+
+	case UnaryExpressionValue:
+		u := v.UnaryExpression
+		// braces keep -5 and && from being read as one token.
+		val := p.value(u.Val)
+		switch u.Val.Kind {
+		case IntegerValueValue, FloatingValueValue, UnaryExpressionValue:
+			val = "{" + val + "}"
+		}
+		return u.Op + val
+
+	case BuiltinValue:
+		b := v.Builtin
+		if len(b.Args) == 0 {
+			return fmt.Sprintf("%s!%s", b.Name, b.Iden.Name.Value)
+		}
+		return fmt.Sprintf("%s!(%s, %s)", b.Name, b.Iden.Name.Value, p.values(b.Args))
+
+	case CallValue:
+		return fmt.Sprintf("%s(%s)", p.operand(v.Call.Left, false), p.values(v.Call.Params))
+	case IndexValue:
+		return fmt.Sprintf("%s[%s]", p.operand(v.Index.Left, false), p.value(v.Index.Sub))
+	case SliceValue:
+		// spaces keep 1..2 from being read as two floats.
+		s := v.Slice
+		bounds := ".."
+		if s.Low != nil {
+			bounds = p.value(s.Low) + " " + bounds
+		}
+		if s.High != nil {
+			bounds += " " + p.value(s.High)
+		}
+		return fmt.Sprintf("%s[%s]", p.operand(s.Left, false), bounds)
+
+	case PathValue:
+		res := p.operand(v.Path.Values[0], true)
+		for _, val := range v.Path.Values[1:] {
+			if val.Kind == IdentifierValue {
+				res += "." + val.Identifier.Name.Value
+			} else {
+				res += ".{" + p.value(val) + "}"
+			}
+		}
+		return res
+
+	case InitValue:
+		return p.init(v.Init)
+	}
+	panic(fmt.Sprintf("unhandled value '%s' in Print", v.Kind))
+}
+
+func (p *printer) init(i *Init) string {
+	vals := make([]string, len(i.Values))
+	for idx, val := range i.Values {
+		vals[idx] = p.value(val)
+		if idx < len(i.Names) {
+			vals[idx] = i.Names[idx].Value + ": " + vals[idx]
+		}
+	}
+
+	// values filled in from defaults come after a semicolon.
+	given := len(vals) - i.Defaults
+	list := strings.Join(vals[:given], ", ")
+	if i.Defaults > 0 {
+		list += "; " + strings.Join(vals[given:], ", ")
+	}
+
+	switch i.Kind {
+	case front.InitTuple:
+		return ":(" + list + ")"
+	case front.InitArray:
+		return ":[" + list + "]"
+	}
+	return ":" + i.LHand.Name.Value + "{" + list + "}"
+}
+
+func labelPrefix(label *front.Token) string {
+	if label == nil {
+		return ""
+	}
+	return label.Value + ": "
+}
+
+func labelSuffix(label *front.Token) string {
+	if label == nil {
+		return ""
+	}
+	return " " + label.Value
+}
+
+// block writes the instructions of the block after the
+// given header, e.g. "if x {". defers are written at the
+// point that they were pushed.
+func (p *printer) block(header string, b *Block) {
+	p.line("%s{", header)
+	p.depth++
+
+	defers := b.DeferStack
+	for idx := 0; idx <= len(b.Instr); idx++ {
+		for len(defers) > 0 && defers[0].After <= idx {
+			p.deferred(defers[0])
+			defers = defers[1:]
+		}
+		if idx < len(b.Instr) {
+			p.instr(b.Instr[idx])
+		}
+	}
+	for _, def := range defers {
+		p.deferred(def)
+	}
+
+	p.depth--
+	p.line("}")
+}
+
+func (p *printer) deferred(d *Defer) {
+	p.prefix = "defer "
+	if d.Block != nil {
+		p.block("", d.Block)
+	} else {
+		p.instr(d.Stat)
+	}
+}
+
+func (p *printer) instr(i *Instruction) {
+	switch i.Kind {
+	case LocalInstr:
+		p.line("%s;", p.local(i.Local))
+	case AllocaInstr:
+		a := i.Alloca
+		p.line("alloca %s;", p.local(&Local{a.Name, a.Type, a.Mutable, a.Owned, a.Val}))
+	case AssignInstr:
+		a := i.Assign
+		p.line("%s %s %s;", p.value(a.LHand), a.Op, p.value(a.RHand))
+	case ExpressionInstr:
+		p.line("%s;", p.value(i.ExpressionStatement))
+	case ReturnInstr:
+		if i.Return.Val == nil {
+			p.line("return;")
+		} else {
+			p.line("return %s;", p.value(i.Return.Val))
+		}
+	case BreakInstr:
+		p.line("break%s;", labelSuffix(i.Break.Label))
+	case NextInstr:
+		p.line("next%s;", labelSuffix(i.Next.Label))
+	case JumpInstr:
+		p.line("jump %s;", i.Jump.Location.Value)
+	case LabelInstr:
+		p.line("$%s;", i.Label.Name.Value)
+	case TypeAliasInstr:
+		t := i.TypeAliasStatement
+		p.line("type %s = %s;", t.Name.Value, p.typ(t.Type))
+	case DeferInstr:
+		p.deferred(i.Defer)
+
+	case BlockInstr:
+		p.block("", i.Block)
+	case LoopInstr:
+		p.block(labelPrefix(i.Loop.Label)+"loop ", i.Loop.Body)
+	case WhileLoopInstr:
+		w := i.WhileLoop
+		header := labelPrefix(w.Label) + "while " + p.value(w.Cond)
+		if w.Post != nil {
+			header += "; " + p.value(w.Post)
+		}
+		p.block(header+" ", w.Body)
+	case IfStatementInstr:
+		iff := i.IfStatement
+		p.block("if "+p.value(iff.Cond)+" ", iff.True)
+		for _, elif := range iff.ElseIf {
+			p.block("elif "+p.value(elif.Cond)+" ", elif.Body)
+		}
+		if iff.Else != nil {
+			p.block("else ", iff.Else)
+		}
+
+	default:
+		panic(fmt.Sprintf("unhandled instruction '%s' in Print", i.Kind))
+	}
+}
+
+func (p *printer) function(fn *Function) {
+	params := []string{}
+	for _, name := range fn.Param.Order {
+		params = append(params, p.param(fn.Param.Get(name.Value)))
+	}
+
+	recv := ""
+	if fn.Receiver != nil {
+		recv = "[" + string(fn.Receiver.Kind) + "]"
+	}
+
+	header := fmt.Sprintf("fn%s %s %s(%s) ", recv, p.typ(fn.ReturnType), fn.Name.Value, strings.Join(params, ", "))
+	p.block(header, fn.Body)
+}
+
+// Print writes the given module in its textual form,
+// see ParseText for reading it back in.
+func Print(mod *Module) string {
+	p := &printer{mod: mod}
+
+	p.line("module %s;", strconv.Quote(mod.Name))
+	for _, inc := range mod.Includes {
+		if inc.System {
+			p.line("include system %s;", strconv.Quote(inc.Path))
+		} else {
+			p.line("include %s;", strconv.Quote(inc.Path))
+		}
+	}
+	for _, flag := range mod.LinkFlags {
+		p.line("link %s;", strconv.Quote(flag))
+	}
+
+	for _, name := range mod.StructureOrder {
+		st := mod.Structures[name.Value]
+		p.line("struct %s {", st.Name.Value)
+		p.depth++
+		for _, field := range st.Fields.Order {
+			p.line("%s;", p.local(st.Fields.Get(field.Value)))
+		}
+		p.depth--
+		p.line("}")
+	}
+
+	if mod.Global != nil {
+		p.block("global ", mod.Global)
+	}
+
+	for _, name := range mod.FunctionOrder {
+		p.function(mod.Functions[name.Value])
+	}
+
+	for _, name := range mod.ImplsOrder {
+		impl := mod.Impls[name.Value]
+		p.line("impl %s {", impl.Name.Value)
+		p.depth++
+		for _, method := range impl.Order {
+			p.function(impl.Methods[method.Value])
+		}
+		p.depth--
+		p.line("}")
+	}
+
+	return p.buf.String()
+}
@@ -0,0 +1,148 @@
+package ir
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// assertRoundTrips checks that printing the module, reading
+// it back in and printing it again gives the same text.
+func assertRoundTrips(t *testing.T, name string, mod *Module) {
+	text := Print(mod)
+	parsed, errs := ParseText(text)
+	if !assert.Empty(t, errs, name) {
+		return
+	}
+	assert.Equal(t, text, Print(parsed), name)
+}
+
+func TestPrintRoundTrips(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{
+			name: "directives",
+			src: `#{include("<stdio.h>"), include("foo.h"), link("-lm")}
+			fn main() int { return 0; }`,
+		},
+		{
+			name: "globals",
+			src: `let N = 4;
+			let M u64 = 1024;
+			mut count i32 = -1;
+			let name = "caasper";
+			let on = true;`,
+		},
+		{
+			name: "defaults",
+			src: `type Point = struct { x i32, y f64 = 2.5, z i32 = -3, };
+			fn main() {
+				let a = :Point{1};
+				let b = :Point{x: 2};
+				let c = :Point{y: 1.0, x: 2};
+			}`,
+		},
+		{
+			name: "casts",
+			src: `fn main() {
+				let x i32 = 5;
+				let y = x as f64;
+				let z = x + 1 as u8;
+			}`,
+		},
+		{
+			name: "slices",
+			src: `fn main() {
+				let arr [i32; 4];
+				let all = arr[..];
+				let some = arr[1..3];
+				let tail = arr[2..];
+				let n = len!arr;
+			}`,
+		},
+		{
+			name: "labeled loops",
+			src: `fn main() {
+				outer: loop {
+					mut i = 0;
+					inner: while i < 10; i = i + 1 {
+						if i == 5 {
+							break outer;
+						} else {
+							next inner;
+						}
+					}
+				}
+				jump done;
+				$done;
+			}`,
+		},
+		{
+			name: "defers",
+			src: `fn main() {
+				let a = 1;
+				defer let b = 2;
+				defer {
+					let c = 3;
+				}
+				return;
+			}`,
+		},
+		{
+			name: "methods",
+			src: `type Point = struct { x i32, y i32, };
+			impl Point {
+				fn sum(self) i32 { return self.x + self.y; }
+				fn scale(self, k i32) i32 { return self.sum() * k; }
+			}
+			fn main() {
+				let p = :Point{1, 2};
+				let s = p.scale(3);
+			}`,
+		},
+	}
+
+	for _, test := range tests {
+		assertRoundTrips(t, test.name, buildModule(t, test.src))
+	}
+}
+
+// ir can be written by hand, e.g. for else ifs
+// which the front end doesn't build yet.
+func TestParseTextRoundTrips(t *testing.T) {
+	src := `module "main";
+include system "stdio.h";
+link "-lm";
+struct Point {
+	mut x i32;
+	mut y f64 = 2.5;
+}
+global {
+	type Point = struct Point;
+	let owned N u64 = 4;
+}
+fn void main(owned k i32) {
+	let owned p = :Point{1; 2.5};
+	let owned q = :Point{x: 2; y: 2.5};
+	if (k == 0) {
+		$zero;
+	}
+	elif (k == 1) {
+		let owned f = (k as f64);
+	}
+	else {
+		defer let owned d = 1;
+		return;
+	}
+}
+`
+	mod, errs := ParseText(src)
+	assert.Empty(t, errs)
+	assert.Empty(t, Verify(mod))
+
+	text := Print(mod)
+	assert.Equal(t, src, text)
+	assertRoundTrips(t, "hand written", mod)
+}
@@ -1,5 +1,7 @@
 package ir
 
+import "github.com/krug-lang/caasper/front"
+
 type SemanticModule struct {
 	ScopeMap *ScopeMap
 	TypeMap  *TypeMap
@@ -27,6 +29,10 @@ func NewScopeDict() *ScopeDict {
 type ScopeMap struct {
 	Functions  map[string]*SymbolTable
 	Structures map[string]*SymbolTable
+
+	// Global is the symbol table for the
+	// modules global variables.
+	Global *SymbolTable
 }
 
 func (s *ScopeMap) RegisterFunction(name string, sym *SymbolTable) bool {
@@ -45,10 +51,11 @@ func (s *ScopeMap) RegisterStructure(name string, sym *SymbolTable) bool {
 	return true
 }
 
-func NewScopeMap() *ScopeMap {
+func NewScopeMap(ids *IDSource) *ScopeMap {
 	return &ScopeMap{
 		Functions:  map[string]*SymbolTable{},
 		Structures: map[string]*SymbolTable{},
+		Global:     NewSymbolTable(ids, nil),
 	}
 }
 
@@ -56,8 +63,52 @@ func NewScopeMap() *ScopeMap {
 // data structure that contains information of
 // all of the symbols and their types.
 type TypeMap struct {
+	// Values is the type of every value in the module, it's
+	// keyed by the value itself so it is not serialised.
+	Values map[*Value]*Type `json:"-"`
+
+	// Symbols is every local, param and identifier in the
+	// module with its type, in the order they were inferred.
+	Symbols []*TypedSymbol `json:"symbols"`
+}
+
+// TypedSymbol is a name written in the source and its type.
+type TypedSymbol struct {
+	Name string `json:"name"`
+	Span []int  `json:"span"`
+	Type *Type  `json:"type"`
+}
+
+// Set sets the type of the given value.
+func (t *TypeMap) Set(v *Value, typ *Type) {
+	t.Values[v] = typ
+}
+
+// TypeOf returns the type of the given value, or nil
+// if it has no type or isn't in the type map.
+func (t *TypeMap) TypeOf(v *Value) *Type {
+	if t == nil {
+		return nil
+	}
+	return t.Values[v]
+}
+
+// AddSymbol records the type of the given name.
+func (t *TypeMap) AddSymbol(name front.Token, typ *Type) {
+	t.Symbols = append(t.Symbols, &TypedSymbol{name.Value, name.Span, typ})
+}
+
+// SymbolAt returns the symbol written at the given
+// offset in the source, e.g. for hovering in an editor.
+func (t *TypeMap) SymbolAt(offset int) (*TypedSymbol, bool) {
+	for _, sym := range t.Symbols {
+		if len(sym.Span) == 2 && sym.Span[0] <= offset && offset < sym.Span[1] {
+			return sym, true
+		}
+	}
+	return nil, false
 }
 
 func NewTypeMap() *TypeMap {
-	return &TypeMap{}
+	return &TypeMap{map[*Value]*Type{}, []*TypedSymbol{}}
 }
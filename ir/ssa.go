@@ -0,0 +1,991 @@
+package ir
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/krug-lang/caasper/api"
+	"github.com/krug-lang/caasper/front"
+)
+
+/*
+	the ssa form of a function is built on its cfg. locals
+	that are only ever read or assigned as a whole are promoted,
+	i.e. ones that never have their address taken, a field or
+	element used, or a method called on them. every assignment
+	to a promoted local is a new definition of it, and where
+	different definitions meet a phi picks the one that holds.
+
+	the instructions in the cfg aren't changed, the definition
+	that each identifier makes or reads is kept to the side.
+	Destruct turns the ssa form back into a function that has
+	a variable for each definition.
+*/
+
+// Def is a single definition of a promoted local.
+type Def struct {
+	ID    int
+	Var   *Local
+	Block *BasicBlock
+
+	// Instr is the instruction that makes the definition, it's
+	// nil for a phi and for the value of a param on entry.
+	Instr *Instruction
+
+	// Iden is the identifier that is assigned to, it's nil
+	// when the definition is the declaration of the local.
+	Iden *Identifier
+	Phi  *Phi
+
+	Uses []*Use
+}
+
+// IsParam returns whether the definition is the value
+// that a param has on entry to the function.
+func (d *Def) IsParam() bool {
+	return d.Instr == nil && d.Phi == nil
+}
+
+// Use is a read of a definition, either by an identifier
+// or as the argument of a phi.
+type Use struct {
+	Def   *Def
+	Block *BasicBlock
+
+	// Instr is the instruction that the identifier is in,
+	// it's nil if it's in the terminator of the block.
+	Instr *Instruction
+	Iden  *Identifier
+	Phi   *Phi
+}
+
+// Phi defines a local at the start of a block. the value of
+// the local is the definition that comes in from the pred
+// that control came from, Args has one for each pred and is
+// nil if the local has no definition along that edge.
+type Phi struct {
+	Def   *Def
+	Block *BasicBlock
+	Args  []*Def
+}
+
+type SSA struct {
+	CFG  *CFG
+	Vars []*Local
+	Defs []*Def
+	Phis map[*BasicBlock][]*Phi
+
+	// Frontier is the dominance frontier of each block, the
+	// blocks that it doesn't dominate but has an edge into.
+	Frontier map[*BasicBlock][]*BasicBlock
+
+	promoted map[*Local]bool
+	params   map[*Local]*Def
+	decls    map[*Local]*Def
+	defs     map[*Identifier]*Def
+	uses     map[*Identifier]*Use
+}
+
+// Promoted returns whether the local is in ssa form.
+func (s *SSA) Promoted(l *Local) bool {
+	return s.promoted[l]
+}
+
+// DefOf returns the definition made by assigning
+// to the identifier, if it is one.
+func (s *SSA) DefOf(iden *Identifier) *Def {
+	return s.defs[iden]
+}
+
+// DeclOf returns the definition made by the declaration of
+// the given local, or the value it has on entry for a param.
+func (s *SSA) DeclOf(l *Local) *Def {
+	if def, ok := s.params[l]; ok {
+		return def
+	}
+	return s.decls[l]
+}
+
+// UseOf returns the use of a definition that the identifier
+// reads, the definition is nil if the local is read before
+// it has been given a value.
+func (s *SSA) UseOf(iden *Identifier) *Use {
+	return s.uses[iden]
+}
+
+// DefsOf returns every definition of the given local.
+func (s *SSA) DefsOf(l *Local) []*Def {
+	res := []*Def{}
+	for _, def := range s.Defs {
+		if def.Var == l {
+			res = append(res, def)
+		}
+	}
+	return res
+}
+
+// children returns the values directly inside of the given
+// value in the order they are worked out, nil for any that
+// are omitted.
+func children(v *Value) []*Value {
+	slots := valueSlots(v)
+	if slots == nil {
+		return nil
+	}
+	res := make([]*Value, len(slots))
+	for i, slot := range slots {
+		res[i] = *slot
+	}
+	return res
+}
+
+// instrValues returns the values in the straight line
+// instructions that are left in the blocks of a cfg.
+func instrValues(instr *Instruction) []*Value {
+	switch instr.Kind {
+	case LocalInstr:
+		return []*Value{instr.Local.Val}
+	case AllocaInstr:
+		return []*Value{instr.Alloca.Val}
+	case AssignInstr:
+		// an assignment statement defines the local the
+		// same way as an assignment in an expression.
+		return []*Value{{Kind: AssignValue, Assign: instr.Assign}}
+	case ExpressionInstr:
+		return []*Value{instr.ExpressionStatement}
+	}
+	return nil
+}
+
+func termValues(term *Terminator) []*Value {
+	return []*Value{term.Cond, term.Val}
+}
+
+type ssaBuilder struct {
+	s *SSA
+
+	stacks map[*Local][]*Def
+
+	// where the instruction being renamed is.
+	block *BasicBlock
+	instr *Instruction
+}
+
+func (b *ssaBuilder) ref(v *Value) *Local {
+	for v != nil && v.Kind == GroupingValue {
+		v = v.Grouping.Val
+	}
+	if v == nil || v.Kind != IdentifierValue {
+		return nil
+	}
+	return b.s.CFG.Refs[v.Identifier]
+}
+
+// escape demotes any locals that are used in a way that
+// could change them without an assignment to them.
+func (b *ssaBuilder) escape(v *Value) {
+	if v == nil {
+		return
+	}
+
+	switch v.Kind {
+	case UnaryExpressionValue:
+		if v.UnaryExpression.Op == "&" {
+			b.demote(b.ref(v.UnaryExpression.Val))
+		}
+	case PathValue:
+		b.demote(b.ref(v.Path.Values[0]))
+	case IndexValue:
+		b.demote(b.ref(v.Index.Left))
+	case SliceValue:
+		b.demote(b.ref(v.Slice.Left))
+	}
+
+	for _, child := range children(v) {
+		b.escape(child)
+	}
+}
+
+func (b *ssaBuilder) demote(l *Local) {
+	if l != nil {
+		delete(b.s.promoted, l)
+	}
+}
+
+// promotable returns whether a local with the given type
+// can be given a new value with a plain assignment.
+func promotable(t *Type) bool {
+	return t != nil && t.Kind != ArrayKind && t.Kind != TupleKind && t.Kind != VoidKind
+}
+
+func (b *ssaBuilder) promote() {
+	s := b.s
+	fn := s.CFG.Func
+
+	for _, name := range fn.Param.Order {
+		param := fn.Param.Get(name.Value)
+		if promotable(param.Type) {
+			s.promoted[param] = true
+		}
+	}
+
+	for _, block := range s.CFG.Blocks {
+		for _, instr := range block.Instr {
+			if instr.Kind == LocalInstr && promotable(instr.Local.Type) {
+				s.promoted[instr.Local] = true
+			}
+		}
+	}
+
+	for _, block := range s.CFG.Blocks {
+		for _, instr := range block.Instr {
+			for _, val := range instrValues(instr) {
+				b.escape(val)
+			}
+		}
+		for _, val := range termValues(block.Term) {
+			b.escape(val)
+		}
+	}
+
+	for _, name := range fn.Param.Order {
+		if param := fn.Param.Get(name.Value); s.promoted[param] {
+			s.Vars = append(s.Vars, param)
+		}
+	}
+	for _, block := range s.CFG.Blocks {
+		for _, instr := range block.Instr {
+			if instr.Kind == LocalInstr && s.promoted[instr.Local] {
+				s.Vars = append(s.Vars, instr.Local)
+			}
+		}
+	}
+}
+
+func (b *ssaBuilder) newDef(v *Local, block *BasicBlock) *Def {
+	def := &Def{ID: len(b.s.Defs), Var: v, Block: block, Uses: []*Use{}}
+	b.s.Defs = append(b.s.Defs, def)
+	return def
+}
+
+// assigned returns the promoted local that the
+// value assigns to, if it assigns to one.
+func (b *ssaBuilder) assigned(v *Value) *Local {
+	if v == nil || v.Kind != AssignValue {
+		return nil
+	}
+	if local := b.ref(v.Assign.LHand); local != nil && b.s.promoted[local] {
+		return local
+	}
+	return nil
+}
+
+// frontiers works out the dominance frontier of each block.
+func (s *SSA) frontiers() {
+	g := s.CFG
+	s.Frontier = map[*BasicBlock][]*BasicBlock{}
+
+	for _, block := range g.Blocks {
+		if !g.Reachable(block) || len(block.Preds) < 2 {
+			continue
+		}
+		for _, pred := range block.Preds {
+			for runner := pred; g.Reachable(runner) && runner != g.Idom(block); runner = g.Idom(runner) {
+				if !containsBlock(s.Frontier[runner], block) {
+					s.Frontier[runner] = append(s.Frontier[runner], block)
+				}
+			}
+		}
+	}
+}
+
+func containsBlock(blocks []*BasicBlock, b *BasicBlock) bool {
+	for _, other := range blocks {
+		if other == b {
+			return true
+		}
+	}
+	return false
+}
+
+// placePhis puts a phi for each local in the iterated
+// dominance frontier of the blocks that define it.
+func (b *ssaBuilder) placePhis() {
+	s := b.s
+	g := s.CFG
+
+	defBlocks := map[*Local][]*BasicBlock{}
+	for _, v := range s.Vars {
+		if _, ok := s.params[v]; ok {
+			defBlocks[v] = []*BasicBlock{g.Entry}
+		}
+	}
+
+	var visit func(v *Value, block *BasicBlock)
+	visit = func(v *Value, block *BasicBlock) {
+		if v == nil {
+			return
+		}
+		if local := b.assigned(v); local != nil && !containsBlock(defBlocks[local], block) {
+			defBlocks[local] = append(defBlocks[local], block)
+		}
+		for _, child := range children(v) {
+			visit(child, block)
+		}
+	}
+
+	for _, block := range g.Blocks {
+		if !g.Reachable(block) {
+			continue
+		}
+		for _, instr := range block.Instr {
+			if instr.Kind == LocalInstr && s.promoted[instr.Local] {
+				if !containsBlock(defBlocks[instr.Local], block) {
+					defBlocks[instr.Local] = append(defBlocks[instr.Local], block)
+				}
+			}
+			for _, val := range instrValues(instr) {
+				visit(val, block)
+			}
+		}
+		for _, val := range termValues(block.Term) {
+			visit(val, block)
+		}
+	}
+
+	for _, v := range s.Vars {
+		work := append([]*BasicBlock{}, defBlocks[v]...)
+		placed := map[*BasicBlock]bool{}
+
+		for len(work) > 0 {
+			block := work[len(work)-1]
+			work = work[:len(work)-1]
+
+			for _, frontier := range s.Frontier[block] {
+				if placed[frontier] {
+					continue
+				}
+				placed[frontier] = true
+
+				phi := &Phi{Block: frontier, Args: make([]*Def, len(frontier.Preds))}
+				phi.Def = b.newDef(v, frontier)
+				phi.Def.Phi = phi
+				s.Phis[frontier] = append(s.Phis[frontier], phi)
+
+				if !containsBlock(defBlocks[v], frontier) {
+					work = append(work, frontier)
+				}
+			}
+		}
+	}
+}
+
+func (b *ssaBuilder) top(v *Local) *Def {
+	stack := b.stacks[v]
+	if len(stack) == 0 {
+		return nil
+	}
+	return stack[len(stack)-1]
+}
+
+func (b *ssaBuilder) push(def *Def) {
+	b.stacks[def.Var] = append(b.stacks[def.Var], def)
+}
+
+func (b *ssaBuilder) use(iden *Identifier, v *Local) {
+	use := &Use{Def: b.top(v), Block: b.block, Instr: b.instr, Iden: iden}
+	if use.Def != nil {
+		use.Def.Uses = append(use.Def.Uses, use)
+	}
+	b.s.uses[iden] = use
+}
+
+// rename records the definition that each identifier in the
+// value reads or makes, in the order they are worked out.
+func (b *ssaBuilder) rename(v *Value) {
+	if v == nil {
+		return
+	}
+
+	switch v.Kind {
+	case IdentifierValue:
+		if local := b.s.CFG.Refs[v.Identifier]; b.s.promoted[local] {
+			b.use(v.Identifier, local)
+		}
+		return
+
+	case BuiltinValue:
+		if iden := v.Builtin.Iden; iden != nil {
+			if local := b.s.CFG.Refs[iden]; b.s.promoted[local] {
+				b.use(iden, local)
+			}
+		}
+
+	case AssignValue:
+		local := b.assigned(v)
+		if local == nil {
+			break
+		}
+
+		a := v.Assign
+		lhand := a.LHand
+		for lhand.Kind == GroupingValue {
+			lhand = lhand.Grouping.Val
+		}
+
+		b.rename(a.RHand)
+		if a.Op != "=" {
+			b.use(lhand.Identifier, local)
+		}
+
+		def := b.newDef(local, b.block)
+		def.Instr, def.Iden = b.instr, lhand.Identifier
+		b.s.defs[lhand.Identifier] = def
+		b.push(def)
+		return
+	}
+
+	for _, child := range children(v) {
+		b.rename(child)
+	}
+}
+
+func (b *ssaBuilder) renameBlock(block *BasicBlock) {
+	s := b.s
+	pushed := []*Local{}
+	push := func(def *Def) {
+		b.push(def)
+		pushed = append(pushed, def.Var)
+	}
+
+	for _, phi := range s.Phis[block] {
+		push(phi.Def)
+	}
+
+	b.block = block
+	for _, instr := range block.Instr {
+		b.instr = instr
+
+		// an assignment pushes its definition as it's renamed,
+		// so count what's pushed for each instruction.
+		before := len(s.Defs)
+		for _, val := range instrValues(instr) {
+			b.rename(val)
+		}
+		for _, def := range s.Defs[before:] {
+			if def.Phi == nil {
+				pushed = append(pushed, def.Var)
+			}
+		}
+
+		if instr.Kind == LocalInstr && s.promoted[instr.Local] {
+			def := b.newDef(instr.Local, block)
+			def.Instr = instr
+			s.decls[instr.Local] = def
+			push(def)
+		}
+	}
+
+	b.instr = nil
+	for _, val := range termValues(block.Term) {
+		b.rename(val)
+	}
+
+	for _, succ := range block.Succs {
+		for idx, pred := range succ.Preds {
+			if pred != block {
+				continue
+			}
+			for _, phi := range s.Phis[succ] {
+				arg := b.top(phi.Def.Var)
+				phi.Args[idx] = arg
+				if arg != nil {
+					arg.Uses = append(arg.Uses, &Use{Def: arg, Block: succ, Phi: phi})
+				}
+			}
+		}
+	}
+
+	for _, child := range s.CFG.Dominated(block) {
+		b.renameBlock(child)
+	}
+
+	for _, v := range pushed {
+		b.stacks[v] = b.stacks[v][:len(b.stacks[v])-1]
+	}
+}
+
+// prune removes the phis whose definitions are never used.
+func (s *SSA) prune() {
+	dead := map[*Phi]bool{}
+	for changed := true; changed; {
+		changed = false
+		for _, phis := range s.Phis {
+			for _, phi := range phis {
+				if dead[phi] || len(phi.Def.Uses) != 0 {
+					continue
+				}
+				dead[phi] = true
+				changed = true
+
+				for _, arg := range phi.Args {
+					if arg == nil {
+						continue
+					}
+					uses := []*Use{}
+					for _, use := range arg.Uses {
+						if use.Phi != phi {
+							uses = append(uses, use)
+						}
+					}
+					arg.Uses = uses
+				}
+			}
+		}
+	}
+
+	for block, phis := range s.Phis {
+		live := []*Phi{}
+		for _, phi := range phis {
+			if !dead[phi] {
+				live = append(live, phi)
+			}
+		}
+		s.Phis[block] = live
+	}
+
+	defs := []*Def{}
+	for _, def := range s.Defs {
+		if def.Phi == nil || !dead[def.Phi] {
+			def.ID = len(defs)
+			defs = append(defs, def)
+		}
+	}
+	s.Defs = defs
+}
+
+// BuildSSA builds the cfg of the given function in the
+// module, and puts the locals in it into ssa form.
+func BuildSSA(mod *Module, fn *Function) (*SSA, []api.CompilerError) {
+	g, errs := BuildCFG(mod, fn)
+
+	s := &SSA{
+		CFG:      g,
+		Vars:     []*Local{},
+		Defs:     []*Def{},
+		Phis:     map[*BasicBlock][]*Phi{},
+		promoted: map[*Local]bool{},
+		params:   map[*Local]*Def{},
+		decls:    map[*Local]*Def{},
+		defs:     map[*Identifier]*Def{},
+		uses:     map[*Identifier]*Use{},
+	}
+
+	b := &ssaBuilder{s: s, stacks: map[*Local][]*Def{}}
+	b.promote()
+
+	for _, name := range fn.Param.Order {
+		param := fn.Param.Get(name.Value)
+		if s.promoted[param] {
+			def := b.newDef(param, g.Entry)
+			s.params[param] = def
+			b.push(def)
+		}
+	}
+
+	s.frontiers()
+	b.placePhis()
+	b.renameBlock(g.Entry)
+	s.prune()
+	return s, errs
+}
+
+// OUT OF SSA
+
+type destructor struct {
+	s    *SSA
+	body *Block
+
+	names map[interface{}]string
+	taken map[string]bool
+	decls []*Instruction
+
+	// targets are the blocks that are jumped to,
+	// the other blocks don't need a label.
+	targets map[*BasicBlock]bool
+}
+
+func (d *destructor) fresh(name string) string {
+	res := name
+	for i := 1; d.taken[res]; i++ {
+		res = fmt.Sprintf("%s__%d", name, i)
+	}
+	d.taken[res] = true
+	return res
+}
+
+func (d *destructor) declare(name string, v *Local) {
+	local := NewLocal(front.Token{Value: name, Kind: front.Identifier, Span: v.Name.Span}, v.Type, v.Owned)
+	local.SetMutable(true)
+	d.decls = append(d.decls, &Instruction{Kind: LocalInstr, Local: local})
+}
+
+// defName returns the variable that holds the given
+// definition of the local, nil is a read of a local
+// before it has been given a value.
+func (d *destructor) defName(def *Def, v *Local) string {
+	var key interface{} = def
+	if def == nil {
+		key = v
+	}
+	if name, ok := d.names[key]; ok {
+		return name
+	}
+
+	name := d.fresh(v.Name.Value)
+	d.names[key] = name
+	d.declare(name, v)
+	return name
+}
+
+func (d *destructor) identifier(name string) *Value {
+	return &Value{Kind: IdentifierValue, Identifier: NewIdentifier(front.Token{Value: name, Kind: front.Identifier})}
+}
+
+// name returns the name that the identifier is written as.
+func (d *destructor) name(iden *Identifier) string {
+	if def := d.s.DefOf(iden); def != nil {
+		return d.defName(def, def.Var)
+	}
+	if use := d.s.UseOf(iden); use != nil {
+		return d.defName(use.Def, d.s.CFG.Refs[iden])
+	}
+	if local, ok := d.s.CFG.Refs[iden]; ok {
+		if name, ok := d.names[local]; ok {
+			return name
+		}
+	}
+	return iden.Name.Value
+}
+
+// copyValue copies the value, writing each
+// identifier as the variable it refers to.
+func (d *destructor) copyValue(v *Value) *Value {
+	if v == nil {
+		return nil
+	}
+
+	res := CloneValue(v)
+	var rename func(orig, res *Value)
+	rename = func(orig, res *Value) {
+		if orig == nil {
+			return
+		}
+
+		switch orig.Kind {
+		case IdentifierValue:
+			res.Identifier.Name.Value = d.name(orig.Identifier)
+		case BuiltinValue:
+			if orig.Builtin.Iden != nil {
+				res.Builtin.Iden.Name.Value = d.name(orig.Builtin.Iden)
+			}
+		case PathValue:
+			// only the first element of a path names a local.
+			rename(orig.Path.Values[0], res.Path.Values[0])
+			for i, elem := range orig.Path.Values[1:] {
+				if elem.Kind != IdentifierValue {
+					rename(elem, res.Path.Values[i+1])
+				}
+			}
+			return
+		case AssignValue:
+			// the assignment defines a new variable, so a compound
+			// assignment reads from the one it replaces.
+			a := res.Assign
+			if def := d.defOfAssign(orig); def != nil && a.Op != "=" {
+				old := CloneValue(a.LHand)
+				rename(orig.Assign.LHand, a.LHand)
+				d.renameUse(orig.Assign.LHand, old)
+				rename(orig.Assign.RHand, a.RHand)
+
+				op := strings.TrimSuffix(a.Op, "=")
+				a.RHand = &Value{Kind: BinaryExpressionValue, BinaryExpression: NewBinaryExpression(old, op, a.RHand)}
+				a.Op = "="
+				return
+			}
+		}
+
+		origs, copies := children(orig), children(res)
+		for i := range origs {
+			rename(origs[i], copies[i])
+		}
+	}
+	rename(v, res)
+	return res
+}
+
+func (d *destructor) defOfAssign(v *Value) *Def {
+	lhand := v.Assign.LHand
+	for lhand.Kind == GroupingValue {
+		lhand = lhand.Grouping.Val
+	}
+	if lhand.Kind != IdentifierValue {
+		return nil
+	}
+	return d.s.DefOf(lhand.Identifier)
+}
+
+// renameUse writes the copy of the left hand of a compound
+// assignment as the variable that it reads from.
+func (d *destructor) renameUse(orig, res *Value) {
+	for orig.Kind == GroupingValue {
+		orig, res = orig.Grouping.Val, res.Grouping.Val
+	}
+	use := d.s.UseOf(orig.Identifier)
+	res.Identifier.Name.Value = d.defName(use.Def, d.s.CFG.Refs[orig.Identifier])
+}
+
+func (d *destructor) add(instr *Instruction) {
+	d.body.AddInstr(instr)
+}
+
+func (d *destructor) assign(to string, from *Value) *Instruction {
+	assign := NewAssign(d.identifier(to), "=", from)
+	return &Instruction{Kind: ExpressionInstr, ExpressionStatement: &Value{Kind: AssignValue, Assign: assign}}
+}
+
+func (d *destructor) copyInstr(instr *Instruction) {
+	s := d.s
+
+	if instr.Kind != LocalInstr {
+		res := &Instruction{Kind: instr.Kind}
+		switch instr.Kind {
+		case AllocaInstr:
+			a := instr.Alloca
+			res.Alloca = &Alloca{a.Name, a.Type, a.Mutable, a.Owned, d.copyValue(a.Val)}
+		case AssignInstr:
+			a := &Instruction{Kind: ExpressionInstr, ExpressionStatement: &Value{Kind: AssignValue, Assign: instr.Assign}}
+			res = &Instruction{Kind: ExpressionInstr, ExpressionStatement: d.copyValue(a.ExpressionStatement)}
+		case ExpressionInstr:
+			res.ExpressionStatement = d.copyValue(instr.ExpressionStatement)
+		default:
+			res = CloneInstr(d.s.CFG.ids, instr)
+		}
+		d.add(res)
+		return
+	}
+
+	local := instr.Local
+	if !s.promoted[local] {
+		// locals that aren't promoted are declared where they
+		// were, but need a name that no other local has since
+		// they are all in the same scope now.
+		val := d.copyValue(local.Val)
+		res := cloneLocal(local)
+		res.Val = val
+		res.Name.Value = d.fresh(local.Name.Value)
+		d.names[local] = res.Name.Value
+		d.add(&Instruction{Kind: LocalInstr, Local: res})
+		return
+	}
+
+	if local.Val != nil {
+		val := d.copyValue(local.Val)
+		d.add(d.assign(d.defName(s.DeclOf(local), local), val))
+	}
+}
+
+type parallelCopy struct {
+	to, from string
+	v        *Local
+}
+
+// copies writes the copies for the phis of the
+// given block along the edge from the pred.
+func (d *destructor) copies(pred, block *BasicBlock) {
+	idx := 0
+	for idx < len(block.Preds) && block.Preds[idx] != pred {
+		idx++
+	}
+
+	pending := []parallelCopy{}
+	for _, phi := range d.s.Phis[block] {
+		arg := phi.Args[idx]
+		if arg == nil {
+			continue
+		}
+		to, from := d.defName(phi.Def, phi.Def.Var), d.defName(arg, arg.Var)
+		if to != from {
+			pending = append(pending, parallelCopy{to, from, phi.Def.Var})
+		}
+	}
+
+	// the copies all happen at once, so a copy can't be
+	// made while its target is read by another copy. if
+	// every copy is blocked then a cycle is broken by
+	// copying one of the targets out of the way first.
+	for len(pending) > 0 {
+		ready := -1
+		for i, cp := range pending {
+			blocked := false
+			for j, other := range pending {
+				if i != j && other.from == cp.to {
+					blocked = true
+					break
+				}
+			}
+			if !blocked {
+				ready = i
+				break
+			}
+		}
+
+		if ready < 0 {
+			cp := pending[0]
+			tmp := d.fresh("krug_tmp")
+			d.declare(tmp, cp.v)
+			d.add(d.assign(tmp, d.identifier(cp.to)))
+			for i := range pending {
+				if pending[i].from == cp.to {
+					pending[i].from = tmp
+				}
+			}
+			continue
+		}
+
+		cp := pending[ready]
+		d.add(d.assign(cp.to, d.identifier(cp.from)))
+		pending = append(pending[:ready], pending[ready+1:]...)
+	}
+}
+
+func (d *destructor) jump(from, to, next *BasicBlock) {
+	d.copies(from, to)
+	if to == next {
+		return
+	}
+	d.targets[to] = true
+	d.add(&Instruction{Kind: JumpInstr, Jump: NewJump(front.Token{Value: blockLabel(to), Kind: front.Identifier})})
+}
+
+func blockLabel(b *BasicBlock) string {
+	return fmt.Sprintf("bb%d", b.ID)
+}
+
+// Destruct turns the ssa form back into a function, each
+// definition of a promoted local is given a variable and
+// the phis are turned into copies along the edges into
+// their blocks. the body is a single block with a label
+// for each basic block that is jumped to.
+func (s *SSA) Destruct() *Function {
+	g := s.CFG
+	fn := g.Func
+
+	d := &destructor{
+		s:       s,
+		body:    NewBlock(g.ids),
+		names:   map[interface{}]string{},
+		taken:   map[string]bool{},
+		targets: map[*BasicBlock]bool{},
+	}
+
+	// the names of params, globals and functions are kept.
+	for _, name := range fn.Param.Order {
+		d.taken[name.Value] = true
+		if def, ok := s.params[fn.Param.Get(name.Value)]; ok {
+			d.names[def] = name.Value
+		}
+	}
+	var reserve func(v *Value)
+	reserve = func(v *Value) {
+		if v == nil {
+			return
+		}
+		if v.Kind == IdentifierValue {
+			if _, ok := g.Refs[v.Identifier]; !ok {
+				d.taken[v.Identifier.Name.Value] = true
+			}
+		}
+		if v.Kind == PathValue {
+			reserve(v.Path.Values[0])
+			return
+		}
+		for _, child := range children(v) {
+			reserve(child)
+		}
+	}
+	for _, block := range g.Blocks {
+		for _, instr := range block.Instr {
+			for _, val := range instrValues(instr) {
+				reserve(val)
+			}
+		}
+		for _, val := range termValues(block.Term) {
+			reserve(val)
+		}
+	}
+
+	blocks := []*BasicBlock{}
+	for _, block := range g.Blocks {
+		if g.Reachable(block) {
+			blocks = append(blocks, block)
+		}
+	}
+
+	labels := map[*BasicBlock]*Instruction{}
+	for idx, block := range blocks {
+		var next *BasicBlock
+		if idx+1 < len(blocks) {
+			next = blocks[idx+1]
+		}
+
+		label := &Instruction{Kind: LabelInstr, Label: NewLabel(front.Token{Value: blockLabel(block), Kind: front.Identifier})}
+		labels[block] = label
+		d.add(label)
+
+		for _, instr := range block.Instr {
+			d.copyInstr(instr)
+		}
+
+		term := block.Term
+		switch term.Kind {
+		case JumpTerm:
+			d.jump(block, term.Target, next)
+
+		case BranchTerm:
+			outer := d.body
+			d.body = NewBlock(d.s.CFG.ids)
+			d.jump(block, term.True, nil)
+			taken := d.body
+			d.body = outer
+
+			d.add(&Instruction{Kind: IfStatementInstr, IfStatement: NewIfStatement(d.copyValue(term.Cond), taken, nil, nil)})
+			d.jump(block, term.False, next)
+
+		case ReturnTerm:
+			d.add(&Instruction{Kind: ReturnInstr, Return: NewReturn(d.copyValue(term.Val))})
+		}
+	}
+
+	instrs := append([]*Instruction{}, d.decls...)
+	for _, instr := range d.body.Instr {
+		if instr.Kind == LabelInstr {
+			used := false
+			for block, label := range labels {
+				if label == instr && d.targets[block] {
+					used = true
+				}
+			}
+			if !used {
+				continue
+			}
+		}
+		instrs = append(instrs, instr)
+	}
+	d.body.Instr = instrs
+
+	res := NewFunction(fn.Name, fn.Param, fn.ReturnType, d.body)
+	res.Stab = fn.Stab
+	res.Receiver = fn.Receiver
+	return res
+}
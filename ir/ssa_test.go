@@ -0,0 +1,397 @@
+package ir
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildSSA builds the ssa form of the function f in
+// the module written in the given ir text.
+func buildSSA(t *testing.T, src string) (*Module, *SSA) {
+	mod, errs := ParseText(`module "main";
+global {
+}
+` + src)
+	assert.Empty(t, errs)
+
+	s, errs := BuildSSA(mod, mod.Functions["f"])
+	assert.Empty(t, errs)
+	return mod, s
+}
+
+// phiVars returns the name of the local of each phi in the block.
+func phiVars(s *SSA, b *BasicBlock) []string {
+	res := []string{}
+	for _, phi := range s.Phis[b] {
+		res = append(res, phi.Def.Var.Name.Value)
+	}
+	return res
+}
+
+// phiOf returns the phi in the block for the named local.
+func phiOf(s *SSA, b *BasicBlock, name string) *Phi {
+	for _, phi := range s.Phis[b] {
+		if phi.Def.Var.Name.Value == name {
+			return phi
+		}
+	}
+	return nil
+}
+
+// run runs a function that has been destructed, the body is
+// only locals, assignments, labels, jumps, branches to a jump
+// and a return of integers.
+func run(t *testing.T, fn *Function, args map[string]int64) int64 {
+	vars := map[string]*Constant{}
+	for name, val := range args {
+		vars[name] = &Constant{Kind: IntegerValueValue, Int: big.NewInt(val)}
+	}
+
+	var eval func(v *Value) *Constant
+	eval = func(v *Value) *Constant {
+		switch v.Kind {
+		case IdentifierValue:
+			c, ok := vars[v.Identifier.Name.Value]
+			assert.True(t, ok, "%s read before it is set", v.Identifier.Name.Value)
+			return c
+		case GroupingValue:
+			return eval(v.Grouping.Val)
+		case BinaryExpressionValue:
+			bin := v.BinaryExpression
+			res, ok := FoldBinary(bin.Op, eval(bin.LHand), eval(bin.RHand))
+			assert.True(t, ok)
+			return res
+		}
+		c, ok := ConstantOf(v)
+		assert.True(t, ok, "unexpected %s", v.Kind)
+		return c
+	}
+
+	labels := map[string]int{}
+	for i, instr := range fn.Body.Instr {
+		if instr.Kind == LabelInstr {
+			labels[instr.Label.Name.Value] = i
+		}
+	}
+
+	var exec func(instrs []*Instruction) (jump string, ret *Constant)
+	exec = func(instrs []*Instruction) (string, *Constant) {
+		for _, instr := range instrs {
+			switch instr.Kind {
+			case LocalInstr:
+				if instr.Local.Val != nil {
+					vars[instr.Local.Name.Value] = eval(instr.Local.Val)
+				}
+			case ExpressionInstr:
+				a := instr.ExpressionStatement.Assign
+				vars[a.LHand.Identifier.Name.Value] = eval(a.RHand)
+			case IfStatementInstr:
+				if eval(instr.IfStatement.Cond).Bool {
+					if to, ret := exec(instr.IfStatement.True.Instr); to != "" || ret != nil {
+						return to, ret
+					}
+				}
+			case JumpInstr:
+				return instr.Jump.Location.Value, nil
+			case ReturnInstr:
+				return "", eval(instr.Return.Val)
+			}
+		}
+		return "", nil
+	}
+
+	pc := 0
+	for steps := 0; steps < 1000; steps++ {
+		to, ret := exec(fn.Body.Instr[pc:])
+		if ret != nil {
+			return ret.Int.Int64()
+		}
+		idx, ok := labels[to]
+		if !assert.True(t, ok, "no label %q", to) {
+			return 0
+		}
+		pc = idx
+	}
+	t.Fatal("function doesn't return")
+	return 0
+}
+
+// assertDestructs destructs the ssa form and checks that
+// the function is valid and round trips through the text.
+func assertDestructs(t *testing.T, mod *Module, s *SSA) *Function {
+	fn := s.Destruct()
+	mod.Functions["f"] = fn
+	assert.Empty(t, Verify(mod))
+	assertRoundTrips(t, fn.Name.Value, mod)
+	return fn
+}
+
+// declares returns whether the function declares the local.
+func declares(fn *Function, name string) bool {
+	for _, instr := range fn.Body.Instr {
+		if instr.Kind == LocalInstr && instr.Local.Name.Value == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSSAPhiPlacement(t *testing.T) {
+	t.Run("join point", func(t *testing.T) {
+		_, s := buildSSA(t, `fn i32 f(owned k i32) {
+	mut owned x = 0;
+	mut owned y = 0;
+	if (k == 0) {
+		x = 1;
+	}
+	else {
+		x = 2;
+		y = 3;
+	}
+	return (x + y);
+}
+`)
+		g := s.CFG
+		assert.Equal(t, []int{1, 2}, blockIDs(block(g, 3).Preds))
+		assert.Equal(t, []string{"x", "y"}, phiVars(s, block(g, 3)))
+
+		x := phiOf(s, block(g, 3), "x")
+		assert.Equal(t, []*BasicBlock{block(g, 1), block(g, 2)}, []*BasicBlock{x.Args[0].Block, x.Args[1].Block})
+
+		// y keeps its declaration along the edge
+		// that doesn't assign to it.
+		y := phiOf(s, block(g, 3), "y")
+		assert.Equal(t, s.DeclOf(y.Def.Var), y.Args[0])
+		assert.Equal(t, block(g, 2), y.Args[1].Block)
+
+		for _, b := range g.Blocks {
+			if b.ID != 3 {
+				assert.Empty(t, s.Phis[b], "bb%d", b.ID)
+			}
+		}
+	})
+
+	t.Run("loop header", func(t *testing.T) {
+		_, s := buildSSA(t, `fn i32 f(owned k i32) {
+	mut owned i = 0;
+	let owned n = k;
+	while (i < n) {
+		i = (i + 1);
+	}
+	return i;
+}
+`)
+		g := s.CFG
+		header := block(g, 1)
+		assert.Equal(t, []int{0, 2}, blockIDs(header.Preds))
+
+		// n and k are never assigned to, so they need no phi.
+		assert.Equal(t, []string{"i"}, phiVars(s, header))
+
+		i := phiOf(s, header, "i")
+		assert.Equal(t, s.DeclOf(i.Def.Var), i.Args[0])
+		assert.Equal(t, block(g, 2), i.Args[1].Block)
+		assert.Empty(t, s.Phis[block(g, 3)])
+	})
+}
+
+func TestSSADefUse(t *testing.T) {
+	_, s := buildSSA(t, `fn i32 f(owned k i32) {
+	mut owned x = 1;
+	(x = (x + k));
+	return x;
+}
+`)
+	g := s.CFG
+	entry := g.Entry
+	decl, assign := entry.Instr[0], entry.Instr[1]
+
+	x := decl.Local
+	k := g.Func.Param.Get("k")
+	assert.True(t, s.Promoted(x))
+	assert.True(t, s.Promoted(k))
+
+	a := assign.ExpressionStatement.Assign
+	lhand := a.LHand.Identifier
+	rhand := a.RHand
+	for rhand.Kind == GroupingValue {
+		rhand = rhand.Grouping.Val
+	}
+	sum := rhand.BinaryExpression
+	xRead, kRead := sum.LHand.Identifier, sum.RHand.Identifier
+	ret := entry.Term.Val.Identifier
+
+	first := s.DeclOf(x)
+	assert.Equal(t, decl, first.Instr)
+	assert.Nil(t, first.Iden)
+	assert.Nil(t, s.DefOf(xRead))
+
+	second := s.DefOf(lhand)
+	assert.Equal(t, assign, second.Instr)
+	assert.Equal(t, lhand, second.Iden)
+	assert.Equal(t, []*Def{first, second}, s.DefsOf(x))
+
+	// the read of x in the assignment sees the declaration,
+	// the return sees the assignment.
+	assert.Equal(t, first, s.UseOf(xRead).Def)
+	assert.Equal(t, assign, s.UseOf(xRead).Instr)
+	assert.Equal(t, []*Use{s.UseOf(xRead)}, first.Uses)
+
+	assert.Equal(t, second, s.UseOf(ret).Def)
+	assert.Nil(t, s.UseOf(ret).Instr)
+	assert.Equal(t, []*Use{s.UseOf(ret)}, second.Uses)
+
+	param := s.DeclOf(k)
+	assert.True(t, param.IsParam())
+	assert.Equal(t, param, s.UseOf(kRead).Def)
+	assert.Equal(t, []*Use{s.UseOf(kRead)}, param.Uses)
+}
+
+func TestSSADestructRoundTrips(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		args map[string]int64
+		res  int64
+	}{
+		{
+			name: "join point",
+			src: `fn i32 f(owned k i32) {
+	mut owned x = 0;
+	if (k == 0) {
+		x = 1;
+	}
+	else {
+		x = (x + k);
+	}
+	return x;
+}
+`,
+			args: map[string]int64{"k": 5},
+			res:  5,
+		},
+		{
+			name: "loop",
+			src: `fn i32 f(owned k i32) {
+	mut owned i = 0;
+	mut owned sum = 0;
+	while (i < k) {
+		sum = (sum + i);
+		i = (i + 1);
+	}
+	return sum;
+}
+`,
+			args: map[string]int64{"k": 5},
+			res:  10,
+		},
+		{
+			name: "rotation",
+			src: `fn i32 f(owned k i32) {
+	mut owned a = 1;
+	mut owned b = 2;
+	mut owned c = 3;
+	mut owned i = 0;
+	while (i < k) {
+		let owned t = a;
+		a = b;
+		b = c;
+		c = t;
+		i = (i + 1);
+	}
+	return (((a * 100) + (b * 10)) + c);
+}
+`,
+			args: map[string]int64{"k": 4},
+			res:  231,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mod, s := buildSSA(t, test.src)
+			fn := assertDestructs(t, mod, s)
+			assert.Equal(t, test.res, run(t, fn, test.args))
+		})
+	}
+}
+
+// TestSSADestructCycles checks the copies of phis that read each
+// other along the back edge, which need a temporary to break
+// the cycle.
+func TestSSADestructCycles(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+
+		// from is the local that each phi
+		// reads along the back edge.
+		from map[string]string
+		args map[string]int64
+		res  int64
+	}{
+		{
+			name: "swap",
+			src: `fn i32 f(owned k i32) {
+	mut owned a = 1;
+	mut owned b = 2;
+	mut owned i = 0;
+	while (i < k) {
+		a = b;
+		b = a;
+		i = (i + 1);
+	}
+	return ((a * 10) + b);
+}
+`,
+			from: map[string]string{"a": "b", "b": "a"},
+			args: map[string]int64{"k": 3},
+			res:  21,
+		},
+		{
+			name: "three cycle",
+			src: `fn i32 f(owned k i32) {
+	mut owned a = 1;
+	mut owned b = 2;
+	mut owned c = 3;
+	mut owned i = 0;
+	while (i < k) {
+		a = b;
+		b = c;
+		c = a;
+		i = (i + 1);
+	}
+	return (((a * 100) + (b * 10)) + c);
+}
+`,
+			from: map[string]string{"a": "b", "b": "c", "c": "a"},
+			args: map[string]int64{"k": 4},
+			res:  231,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mod, s := buildSSA(t, test.src)
+			header := block(s.CFG, 1)
+			assert.Equal(t, []int{0, 2}, blockIDs(header.Preds))
+
+			// the phis are rewired so that along the back edge
+			// each reads the phi of another, the assignments in
+			// the body are left without a use.
+			phis := map[string]*Phi{}
+			for name := range test.from {
+				phis[name] = phiOf(s, header, name)
+			}
+			for name, from := range test.from {
+				phis[name].Args[1] = phis[from].Def
+			}
+
+			fn := assertDestructs(t, mod, s)
+			assert.True(t, declares(fn, "krug_tmp"))
+			assert.Equal(t, test.res, run(t, fn, test.args))
+		})
+	}
+}
@@ -1,8 +1,6 @@
 package ir
 
 import (
-	"math/rand"
-
 	"github.com/krug-lang/caasper/front"
 )
 
@@ -50,7 +48,8 @@ func (s *SymbolTable) String() string {
 	res := "{"
 
 	idx := 0
-	for _, sym := range s.Symbols {
+	for _, name := range s.SymbolSet {
+		sym := s.Symbols[name]
 		if idx != 0 {
 			res += " "
 		}
@@ -105,13 +104,15 @@ func (s *SymbolTable) Lookup(name string) (*SymbolValue, bool) {
 	return nil, false
 }
 
-func NewSymbolTable(outer *SymbolTable) *SymbolTable {
+// NewSymbolTable creates a new symbol table inside of
+// outer, with an id from the given source.
+func NewSymbolTable(ids *IDSource, outer *SymbolTable) *SymbolTable {
 	outerID := -1
 	if outer != nil {
 		outerID = outer.ID
 	}
 	return &SymbolTable{
-		ID:        rand.Intn(30000),
+		ID:        int(ids.NextID()),
 		OuterID:   outerID,
 		Inner:     []*SymbolTable{},
 		Symbols:   map[string]*SymbolValue{},
@@ -12,8 +12,11 @@ const (
 	FloatKind     TypeOf = "float"
 	IntegerKind          = "int"
 	ArrayKind            = "array"
+	SliceKind            = "slice"
 	FunctionKind         = "fn"
 	VoidKind             = "void"
+	StringKind           = "str"
+	BoolKind             = "bool"
 	StructKind           = "struct"
 	PointerKind          = "ptr"
 	TupleKind            = "tuple"
@@ -24,9 +27,12 @@ type Type struct {
 	Kind TypeOf `json:"kind"`
 
 	VoidType     *VoidType      `json:"voidType,omitempty"`
+	StringType   *StringType    `json:"stringType,omitempty"`
+	BoolType     *BoolType      `json:"boolType,omitempty"`
 	FloatingType *FloatingType  `json:"floatingType,omitempty"`
 	IntegerType  *IntegerType   `json:"integerType,omitempty"`
 	ArrayType    *ArrayType     `json:"arrayType,omitempty"`
+	Slice        *SliceType     `json:"slice,omitempty"`
 	Function     *Function      `json:"function,omitempty"`
 	Tuple        *TupleType     `json:"tuple,omitempty"`
 	Structure    *Structure     `json:"structure,omitempty"`
@@ -38,12 +44,18 @@ func (t *Type) String() string {
 	switch t.Kind {
 	case VoidKind:
 		return t.VoidType.String()
+	case StringKind:
+		return t.StringType.String()
+	case BoolKind:
+		return t.BoolType.String()
 	case FloatKind:
 		return t.FloatingType.String()
 	case IntegerKind:
 		return t.IntegerType.String()
 	case ArrayKind:
 		return t.ArrayType.String()
+	case SliceKind:
+		return t.Slice.String()
 	case FunctionKind:
 		return t.Function.String()
 	case StructKind:
@@ -52,6 +64,8 @@ func (t *Type) String() string {
 		return t.Pointer.String()
 	case ReferenceKind:
 		return t.Reference.String()
+	case TupleKind:
+		return t.Tuple.String()
 	default:
 		panic("unhandled type in Type::String()")
 	}
@@ -72,8 +86,9 @@ var (
 	Uint64 = &Type{Kind: IntegerKind, IntegerType: NewIntegerType(64, false)}
 
 	Void = &Type{Kind: VoidKind, VoidType: &VoidType{}}
+	Str  = &Type{Kind: StringKind, StringType: &StringType{}}
 
-	Bool = Uint32
+	Bool = &Type{Kind: BoolKind, BoolType: &BoolType{}}
 	Rune = Int32
 )
 
@@ -92,6 +107,7 @@ var PrimitiveType = map[string]*Type{
 	"u64": Uint64,
 
 	"void": Void,
+	"str":  Str,
 	"bool": Bool,
 	"rune": Rune,
 
@@ -105,6 +121,20 @@ func (v *VoidType) String() string {
 	return "void"
 }
 
+// StringType is a utf-8 string, it's a pointer to
+// the bytes of the string and its length in bytes.
+type StringType struct{}
+
+func (s *StringType) String() string {
+	return "str"
+}
+
+type BoolType struct{}
+
+func (b *BoolType) String() string {
+	return "bool"
+}
+
 // INTEGER TYPE
 
 type IntegerType struct {
@@ -176,13 +206,50 @@ type ArrayType struct {
 }
 
 func (a *ArrayType) String() string {
-	return fmt.Sprintf("[%s; %s]", a.Base.String(), a.Size)
+	if lit, ok := IntegerLiteral(a.Size); ok {
+		return fmt.Sprintf("[%s; %s]", a.Base.String(), lit.RawValue)
+	}
+	return fmt.Sprintf("[%s; %v]", a.Base.String(), a.Size)
 }
 
 func NewArrayType(base *Type, size *Value) *ArrayType {
 	return &ArrayType{base, size}
 }
 
+// SLICE TYPE
+
+// SliceType is a view of some elements of an array,
+// it's a pointer to the first element and a length.
+type SliceType struct {
+	Base *Type `json:"base"`
+}
+
+func (s *SliceType) String() string {
+	return fmt.Sprintf("[]%s", s.Base.String())
+}
+
+func NewSliceType(base *Type) *SliceType {
+	return &SliceType{base}
+}
+
+// ElementType returns the type of the elements of the
+// given array, slice or pointer, or nil if it has none.
+func ElementType(t *Type) *Type {
+	if t == nil {
+		return nil
+	}
+
+	switch t.Kind {
+	case ArrayKind:
+		return t.ArrayType.Base
+	case SliceKind:
+		return t.Slice.Base
+	case PointerKind:
+		return t.Pointer.Base
+	}
+	return nil
+}
+
 // POINTER TYPE
 
 type PointerType struct {
@@ -222,7 +289,7 @@ func (t TypeDict) String() string {
 	for _, name := range t.Order {
 		field, _ := t.Data[name.Value]
 		// FIXME?
-		fields += fmt.Sprintf("%s:%s,", name, field)
+		fields += fmt.Sprintf("%s:%v,", name, field)
 	}
 	return fields
 }
@@ -260,16 +327,33 @@ type Function struct {
 	Param      *TypeDict    `json:"param"`
 	ReturnType *Type        `json:"return_type,omitempty"`
 	Body       *Block       `json:"body"`
+
+	// Receiver is only set for methods, the
+	// receiver is also the first of the params.
+	Receiver *Receiver `json:"receiver,omitempty"`
+}
+
+// Receiver is how a method takes the value
+// that it is called on.
+type Receiver struct {
+	Kind   front.ReceiverKind `json:"kind"`
+	Parent string             `json:"parent"`
+}
+
+func NewReceiver(kind front.ReceiverKind, parent string) *Receiver {
+	return &Receiver{kind, parent}
 }
 
 func (f *Function) String() string {
 	return f.ReturnType.String()
 }
 
-func NewFunction(name front.Token, params *TypeDict, ret *Type) *Function {
-	return &Function{name, nil, params, ret, NewBlock()}
+func NewFunction(name front.Token, params *TypeDict, ret *Type, body *Block) *Function {
+	return &Function{name, nil, params, ret, body, nil}
 }
 
+// UnclaimedMethod is a method in an impl that
+// does not name a structure in the module.
 type UnclaimedMethod struct {
 	Parent string    `json:"parent"`
 	Method *Function `json:"method"`
@@ -281,16 +365,18 @@ type Impl struct {
 	Name    front.Token          `json:"name"`
 	Stab    *SymbolTable         `json:"stab,omitempty"`
 	Methods map[string]*Function `json:"methods"`
+	Order   []front.Token        `json:"order"`
 }
 
 func (i *Impl) RegisterMethod(fn *Function) bool {
 	if _, ok := i.Methods[fn.Name.Value]; ok {
 		return false
 	}
+	i.Order = append(i.Order, fn.Name)
 	i.Methods[fn.Name.Value] = fn
 	return true
 }
 
 func NewImpl(name front.Token) *Impl {
-	return &Impl{name, nil, map[string]*Function{}}
+	return &Impl{name, nil, map[string]*Function{}, []front.Token{}}
 }
@@ -1,7 +1,6 @@
 package ir
 
 import (
-	"fmt"
 	"math/big"
 
 	"github.com/krug-lang/caasper/front"
@@ -15,6 +14,7 @@ const (
 	FloatingValueValue    = "FloatingValue"
 	StringValueValue      = "StringValue"
 	CharacterValueValue   = "CharValue"
+	BooleanValueValue     = "BoolValue"
 	BinaryExpressionValue = "BinaryExpression"
 	IdentifierValue       = "Identifier"
 	BuiltinValue          = "Builtin"
@@ -25,6 +25,8 @@ const (
 	IndexValue            = "Index"
 	AssignValue           = "Assign"
 	InitValue             = "Init"
+	CastValue             = "Cast"
+	SliceValue            = "Slice"
 )
 
 type Value struct {
@@ -33,6 +35,7 @@ type Value struct {
 	FloatingValue  *FloatingValue
 	StringValue    *StringValue
 	CharacterValue *CharacterValue
+	BooleanValue   *BooleanValue
 
 	BinaryExpression *BinaryExpression
 	Identifier       *Identifier
@@ -44,9 +47,14 @@ type Value struct {
 	Path             *Path
 	Index            *Index
 	Init             *Init
+	Cast             *Cast
+	Slice            *Slice
 }
 
-// FIXME! this is shit
+// InferredType returns the type of the value that can be
+// worked out from the value alone, or nil if it depends on
+// what an identifier refers to. the type of every value is
+// worked out by type inference, see TypeMap.
 func (v *Value) InferredType() *Type {
 	switch v.Kind {
 	case IntegerValueValue:
@@ -57,6 +65,8 @@ func (v *Value) InferredType() *Type {
 		return v.StringValue.InferredType()
 	case CharacterValueValue:
 		return v.CharacterValue.InferredType()
+	case BooleanValueValue:
+		return v.BooleanValue.InferredType()
 	case BinaryExpressionValue:
 		return v.BinaryExpression.InferredType()
 	case IdentifierValue:
@@ -73,8 +83,14 @@ func (v *Value) InferredType() *Type {
 		return v.Path.InferredType()
 	case IndexValue:
 		return v.Index.InferredType()
+	case CastValue:
+		return v.Cast.InferredType()
+	case SliceValue:
+		return v.Slice.InferredType()
+	case InitValue:
+		return v.Init.InferredType()
 	case AssignValue:
-		panic("uh")
+		return v.Assign.LHand.InferredType()
 	default:
 		panic("unhandled Value::InferredType()")
 	}
@@ -103,9 +119,28 @@ type BinaryExpression struct {
 }
 
 func (b *BinaryExpression) InferredType() *Type {
-	// TODO pick widest type, for now we pick the left type
-	fmt.Println(b.LHand, b.Op, b.RHand)
-	return b.LHand.InferredType()
+	if IsComparison(b.Op) {
+		return Bool
+	}
+
+	lh, rh := b.LHand.InferredType(), b.RHand.InferredType()
+	if widest := Widest(lh, rh); widest != nil {
+		return widest
+	}
+
+	// there is no implicit conversion between the two, this
+	// is reported in the conversion check so we pick the left.
+	return lh
+}
+
+// IsComparison returns whether the given binary
+// operator results in a bool, e.g. == or &&.
+func IsComparison(op string) bool {
+	switch op {
+	case "==", "!=", "<", ">", "<=", ">=", "&&", "||":
+		return true
+	}
+	return false
 }
 
 func NewBinaryExpression(lh *Value, op string, rh *Value) *BinaryExpression {
@@ -175,6 +210,20 @@ func NewCharacterValue(val string) *CharacterValue {
 	return &CharacterValue{val}
 }
 
+// BOOLEAN VALUE
+
+type BooleanValue struct {
+	Value bool
+}
+
+func (b *BooleanValue) InferredType() *Type {
+	return Bool
+}
+
+func NewBooleanValue(val bool) *BooleanValue {
+	return &BooleanValue{val}
+}
+
 // STRING VALUE
 
 type StringValue struct {
@@ -182,11 +231,7 @@ type StringValue struct {
 }
 
 func (s *StringValue) InferredType() *Type {
-	// TODO rune*
-	return &Type{
-		Kind:    PointerKind,
-		Pointer: NewPointerType(Int32),
-	}
+	return Str
 }
 
 func NewStringValue(val string) *StringValue {
@@ -199,8 +244,10 @@ type Identifier struct {
 	Name front.Token
 }
 
+// InferredType is nil, the type of an identifier depends on
+// what it refers to so it's worked out by type inference.
 func (i *Identifier) InferredType() *Type {
-	panic("we need to deal with this later after name resolution")
+	return nil
 }
 
 func NewIdentifier(name front.Token) *Identifier {
@@ -216,7 +263,13 @@ type Builtin struct {
 }
 
 func (b *Builtin) InferredType() *Type {
-	panic("todo")
+	switch b.Name {
+	case "sizeof", "len":
+		return Uint64
+	case "free":
+		return Void
+	}
+	return nil
 }
 
 func NewBuiltin(name string, iden *Identifier, args []*Value) *Builtin {
@@ -230,8 +283,10 @@ type Call struct {
 	Params []*Value
 }
 
+// InferredType is nil, the type of a call is the return
+// type of its function so it's worked out by type inference.
 func (c *Call) InferredType() *Type {
-	panic("same thing as identifier, needs to be inferred from c.Left's ReturnType ")
+	return nil
 }
 
 func NewCall(left *Value, params []*Value) *Call {
@@ -244,8 +299,10 @@ type Path struct {
 	Values []*Value
 }
 
+// InferredType is nil, the fields of a path are
+// looked up by type inference.
 func (p *Path) InferredType() *Type {
-	panic("also needs to be inferred in a later stage in sema")
+	return nil
 }
 
 func NewPath(values []*Value) *Path {
@@ -255,17 +312,45 @@ func NewPath(values []*Value) *Path {
 // INIT
 
 type Init struct {
-	Kind   front.InitializerKind
-	LHand  *Identifier
+	Kind  front.InitializerKind
+	LHand *Identifier
+
+	// Names is the field name for each value when
+	// the fields of a structure are given by name.
+	Names  []front.Token `json:"names,omitempty"`
 	Values []*Value
+
+	// Defaults is the number of values at the end of
+	// Values that were filled in from field defaults.
+	Defaults int `json:"defaults,omitempty"`
 }
 
 func (i *Init) InferredType() *Type {
-	panic("shit")
+	if i.Kind == front.InitStructure {
+		return &Type{Kind: ReferenceKind, Reference: NewReferenceType(i.LHand.Name.Value)}
+	}
+	return nil
 }
 
-func NewInit(kind front.InitializerKind, lhand *Identifier, values []*Value) *Init {
-	return &Init{kind, lhand, values}
+func NewInit(kind front.InitializerKind, lhand *Identifier, names []front.Token, values []*Value) *Init {
+	return &Init{kind, lhand, names, values, 0}
+}
+
+// CAST
+
+// Cast is an explicit conversion of the value
+// Val to the type Type, e.g. x as f32
+type Cast struct {
+	Val  *Value
+	Type *Type
+}
+
+func (c *Cast) InferredType() *Type {
+	return c.Type
+}
+
+func NewCast(val *Value, typ *Type) *Cast {
+	return &Cast{val, typ}
 }
 
 // INDEX
@@ -273,12 +358,40 @@ func NewInit(kind front.InitializerKind, lhand *Identifier, values []*Value) *In
 type Index struct {
 	Left *Value
 	Sub  *Value
+
+	// Span is where the index was written, this is
+	// reported when a bounds check fails.
+	Span []int
 }
 
 func (i *Index) InferredType() *Type {
-	panic("needs to be inferred from i.Left's Base Type!")
+	return ElementType(i.Left.InferredType())
+}
+
+func NewIndex(left, sub *Value, span []int) *Index {
+	return &Index{left, sub, span}
+}
+
+// SLICE
+
+// Slice is a view of the elements Low up to but not
+// including High of Left. Low and High are nil when they
+// are omitted, i.e. the start and the end of Left.
+type Slice struct {
+	Left *Value
+	Low  *Value
+	High *Value
+	Span []int
+}
+
+func (s *Slice) InferredType() *Type {
+	base := ElementType(s.Left.InferredType())
+	if base == nil {
+		return nil
+	}
+	return &Type{Kind: SliceKind, Slice: NewSliceType(base)}
 }
 
-func NewIndex(left, sub *Value) *Index {
-	return &Index{left, sub}
+func NewSlice(left, low, high *Value, span []int) *Slice {
+	return &Slice{left, low, high, span}
 }
@@ -0,0 +1,566 @@
+package ir
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/krug-lang/caasper/api"
+)
+
+/*
+	the verifier checks that a module is well formed before
+	it is handed to a pass, e.g. that the kind of every
+	instruction, value and type matches the field that is
+	set on it. it doesn't check the program itself, only the
+	shape of the ir, so that later passes don't panic on it.
+*/
+
+type verifier struct {
+	errs []api.CompilerError
+
+	// at is the span of the closest enclosing
+	// node that has a position in the source.
+	at []int
+
+	structures map[*Structure]bool
+}
+
+func (v *verifier) error(format string, args ...interface{}) {
+	v.errs = append(v.errs, api.NewMalformedIR(fmt.Sprintf(format, args...), v.at...))
+}
+
+// within sets the span for any errors reported
+// until the returned func is called.
+func (v *verifier) within(span []int) func() {
+	prev := v.at
+	if len(span) != 0 {
+		v.at = span
+	}
+	return func() { v.at = prev }
+}
+
+// kinds checks that exactly one of the fields is set, and
+// that it's the field for the given kind.
+func (v *verifier) kinds(what string, kind string, set []string) bool {
+	if kind == "" {
+		v.error("%s has no kind", what)
+		return false
+	}
+	if len(set) == 0 {
+		v.error("%s of kind '%s' has no fields set", what, kind)
+		return false
+	}
+	if len(set) > 1 {
+		v.error("%s of kind '%s' has more than one field set (%s)", what, kind, strings.Join(set, ", "))
+		return false
+	}
+	if set[0] != kind {
+		v.error("%s of kind '%s' has the field for '%s' set", what, kind, set[0])
+		return false
+	}
+	return true
+}
+
+// TYPES
+
+func typeFields(t *Type) []string {
+	res := []string{}
+	add := func(set bool, kind string) {
+		if set {
+			res = append(res, kind)
+		}
+	}
+	add(t.VoidType != nil, VoidKind)
+	add(t.StringType != nil, StringKind)
+	add(t.BoolType != nil, BoolKind)
+	add(t.FloatingType != nil, string(FloatKind))
+	add(t.IntegerType != nil, IntegerKind)
+	add(t.ArrayType != nil, ArrayKind)
+	add(t.Slice != nil, SliceKind)
+	add(t.Function != nil, FunctionKind)
+	add(t.Tuple != nil, TupleKind)
+	add(t.Structure != nil, StructKind)
+	add(t.Pointer != nil, PointerKind)
+	add(t.Reference != nil, ReferenceKind)
+	return res
+}
+
+func (v *verifier) verifyType(t *Type) {
+	if t == nil {
+		v.error("nil type")
+		return
+	}
+	if !v.kinds("type", string(t.Kind), typeFields(t)) {
+		return
+	}
+
+	switch t.Kind {
+	case ArrayKind:
+		v.verifyType(t.ArrayType.Base)
+		if t.ArrayType.Size == nil {
+			v.error("array type has no size")
+		} else {
+			v.verifyValue(t.ArrayType.Size)
+		}
+	case SliceKind:
+		v.verifyType(t.Slice.Base)
+	case PointerKind:
+		v.verifyType(t.Pointer.Base)
+	case TupleKind:
+		for _, typ := range t.Tuple.Types {
+			v.verifyType(typ)
+		}
+	case ReferenceKind:
+		if t.Reference.Name == "" {
+			v.error("reference type has no name")
+		}
+	case StructKind:
+		v.verifyStructure(t.Structure)
+	case FunctionKind:
+		v.verifyFunc(t.Function)
+	}
+}
+
+func (v *verifier) verifyDict(what string, dict *TypeDict) {
+	if dict == nil {
+		v.error("%s are nil", what)
+		return
+	}
+	if len(dict.Order) != len(dict.Data) {
+		v.error("%s have %d entries but %d in their order", what, len(dict.Data), len(dict.Order))
+	}
+
+	for _, name := range dict.Order {
+		local := dict.Get(name.Value)
+		if local == nil {
+			done := v.within(name.Span)
+			v.error("'%s' is in the order of %s but has no entry", name.Value, what)
+			done()
+			continue
+		}
+		v.verifyLocal(local)
+	}
+}
+
+func (v *verifier) verifyStructure(s *Structure) {
+	if v.structures[s] {
+		return
+	}
+	v.structures[s] = true
+
+	defer v.within(s.Name.Span)()
+	v.verifyDict("fields of '"+s.Name.Value+"'", s.Fields)
+}
+
+// VALUES
+
+func valueFields(val *Value) []string {
+	res := []string{}
+	add := func(set bool, kind string) {
+		if set {
+			res = append(res, kind)
+		}
+	}
+	add(val.IntegerValue != nil, IntegerValueValue)
+	add(val.FloatingValue != nil, FloatingValueValue)
+	add(val.StringValue != nil, StringValueValue)
+	add(val.CharacterValue != nil, CharacterValueValue)
+	add(val.BooleanValue != nil, BooleanValueValue)
+	add(val.BinaryExpression != nil, BinaryExpressionValue)
+	add(val.Identifier != nil, IdentifierValue)
+	add(val.Grouping != nil, GroupingValue)
+	add(val.Assign != nil, AssignValue)
+	add(val.Builtin != nil, BuiltinValue)
+	add(val.UnaryExpression != nil, UnaryExpressionValue)
+	add(val.Call != nil, CallValue)
+	add(val.Path != nil, PathValue)
+	add(val.Index != nil, IndexValue)
+	add(val.Init != nil, InitValue)
+	add(val.Cast != nil, CastValue)
+	add(val.Slice != nil, SliceValue)
+	return res
+}
+
+// operand verifies a value that must be set.
+func (v *verifier) operand(what string, val *Value) {
+	if val == nil {
+		v.error("%s is nil", what)
+		return
+	}
+	v.verifyValue(val)
+}
+
+func (v *verifier) verifyValue(val *Value) {
+	if !v.kinds("value", string(val.Kind), valueFields(val)) {
+		return
+	}
+
+	switch val.Kind {
+	case IntegerValueValue:
+		if val.IntegerValue.RawValue == nil {
+			v.error("integer value has no value")
+		}
+
+	case IdentifierValue:
+		if val.Identifier.Name.Value == "" {
+			v.error("identifier has no name")
+		}
+
+	case GroupingValue:
+		v.operand("grouped value", val.Grouping.Val)
+
+	case BinaryExpressionValue:
+		bin := val.BinaryExpression
+		if bin.Op == "" {
+			v.error("binary expression has no operator")
+		}
+		v.operand("left hand of '"+bin.Op+"'", bin.LHand)
+		v.operand("right hand of '"+bin.Op+"'", bin.RHand)
+
+	case AssignValue:
+		v.verifyAssign(val.Assign)
+
+	case UnaryExpressionValue:
+		if val.UnaryExpression.Op == "" {
+			v.error("unary expression has no operator")
+		}
+		v.operand("operand of '"+val.UnaryExpression.Op+"'", val.UnaryExpression.Val)
+
+	case BuiltinValue:
+		b := val.Builtin
+		if b.Name == "" {
+			v.error("builtin has no name")
+		}
+		for _, arg := range b.Args {
+			v.operand("argument of '"+b.Name+"'", arg)
+		}
+
+	case CallValue:
+		v.operand("callee", val.Call.Left)
+		for _, param := range val.Call.Params {
+			v.operand("argument", param)
+		}
+
+	case PathValue:
+		if len(val.Path.Values) == 0 {
+			v.error("path has no values")
+		}
+		for _, elem := range val.Path.Values {
+			v.operand("path element", elem)
+		}
+
+	case IndexValue:
+		defer v.within(val.Index.Span)()
+		v.operand("indexed value", val.Index.Left)
+		v.operand("subscript", val.Index.Sub)
+
+	case SliceValue:
+		defer v.within(val.Slice.Span)()
+		v.operand("sliced value", val.Slice.Left)
+		if val.Slice.Low != nil {
+			v.verifyValue(val.Slice.Low)
+		}
+		if val.Slice.High != nil {
+			v.verifyValue(val.Slice.High)
+		}
+
+	case InitValue:
+		v.verifyInit(val.Init)
+
+	case CastValue:
+		v.operand("cast value", val.Cast.Val)
+		v.verifyType(val.Cast.Type)
+	}
+}
+
+func (v *verifier) verifyAssign(a *Assign) {
+	if a.Op == "" {
+		v.error("assignment has no operator")
+	}
+	v.operand("left hand of '"+a.Op+"'", a.LHand)
+	v.operand("right hand of '"+a.Op+"'", a.RHand)
+}
+
+func (v *verifier) verifyInit(i *Init) {
+	if i.LHand != nil {
+		defer v.within(i.LHand.Name.Span)()
+	}
+	if i.Names != nil && len(i.Names) != len(i.Values) {
+		v.error("initializer has %d names for %d values", len(i.Names), len(i.Values))
+	}
+	if i.Defaults < 0 || i.Defaults > len(i.Values) {
+		v.error("initializer has %d defaults for %d values", i.Defaults, len(i.Values))
+	}
+	for _, val := range i.Values {
+		v.operand("initializer value", val)
+	}
+}
+
+// INSTRUCTIONS
+
+func instrFields(instr *Instruction) []string {
+	res := []string{}
+	add := func(set bool, kind string) {
+		if set {
+			res = append(res, kind)
+		}
+	}
+	add(instr.Block != nil, BlockInstr)
+	add(instr.Assign != nil, AssignInstr)
+	add(instr.Local != nil, LocalInstr)
+	add(instr.Alloca != nil, AllocaInstr)
+	add(instr.Next != nil, NextInstr)
+	add(instr.Break != nil, BreakInstr)
+	add(instr.Return != nil, ReturnInstr)
+	add(instr.Loop != nil, LoopInstr)
+	add(instr.Defer != nil, DeferInstr)
+	add(instr.Label != nil, LabelInstr)
+	add(instr.Jump != nil, JumpInstr)
+	add(instr.WhileLoop != nil, WhileLoopInstr)
+	add(instr.ElseIfStatement != nil, ElseIfStatementInstr)
+	add(instr.IfStatement != nil, IfStatementInstr)
+	add(instr.ExpressionStatement != nil, ExpressionInstr)
+	add(instr.TypeAliasStatement != nil, TypeAliasInstr)
+	return res
+}
+
+func (v *verifier) verifyLocal(l *Local) {
+	defer v.within(l.Name.Span)()
+	if l.Name.Value == "" {
+		v.error("local has no name")
+	}
+	if l.Type == nil && l.Val == nil {
+		v.error("local '%s' has no type and no value to infer it from", l.Name.Value)
+	}
+	if l.Type != nil {
+		v.verifyType(l.Type)
+	}
+	if l.Val != nil {
+		v.verifyValue(l.Val)
+	}
+}
+
+func (v *verifier) verifyCond(what string, cond *Value, body *Block) {
+	v.operand(what+" condition", cond)
+	v.verifyBlock(body)
+}
+
+func (v *verifier) verifyInstr(instr *Instruction) {
+	if instr == nil {
+		v.error("nil instruction")
+		return
+	}
+	if !v.kinds("instruction", string(instr.Kind), instrFields(instr)) {
+		return
+	}
+
+	switch instr.Kind {
+	case BlockInstr:
+		v.verifyBlock(instr.Block)
+
+	case AssignInstr:
+		v.verifyAssign(instr.Assign)
+
+	case LocalInstr:
+		v.verifyLocal(instr.Local)
+
+	case AllocaInstr:
+		a := instr.Alloca
+		defer v.within(a.Name.Span)()
+		v.verifyType(a.Type)
+		if a.Val != nil {
+			v.verifyValue(a.Val)
+		}
+
+	case ReturnInstr:
+		if instr.Return.Val != nil {
+			v.verifyValue(instr.Return.Val)
+		}
+
+	case LoopInstr:
+		v.verifyBlock(instr.Loop.Body)
+
+	case WhileLoopInstr:
+		while := instr.WhileLoop
+		if while.Post != nil {
+			v.verifyValue(while.Post)
+		}
+		v.verifyCond("while", while.Cond, while.Body)
+
+	case IfStatementInstr:
+		iff := instr.IfStatement
+		v.verifyCond("if", iff.Cond, iff.True)
+		for _, elif := range iff.ElseIf {
+			if elif == nil {
+				v.error("nil else if")
+				continue
+			}
+			v.verifyCond("else if", elif.Cond, elif.Body)
+		}
+		if iff.Else != nil {
+			v.verifyBlock(iff.Else)
+		}
+
+	case ElseIfStatementInstr:
+		v.error("else if outside of an if statement")
+
+	case ExpressionInstr:
+		v.verifyValue(instr.ExpressionStatement)
+
+	case LabelInstr:
+		defer v.within(instr.Label.Name.Span)()
+		if instr.Label.Name.Value == "" {
+			v.error("label has no name")
+		}
+
+	case JumpInstr:
+		defer v.within(instr.Jump.Location.Span)()
+		if instr.Jump.Location.Value == "" {
+			v.error("jump has no label")
+		}
+
+	case DeferInstr:
+		// defers are pushed onto the defer stack of
+		// their block rather than added as instructions.
+		v.error("defer in the instructions of a block")
+
+	case TypeAliasInstr:
+		alias := instr.TypeAliasStatement
+		defer v.within(alias.Name.Span)()
+		v.verifyType(alias.Type)
+	}
+}
+
+func (v *verifier) verifyDefer(def *Defer, b *Block, after int) {
+	if def == nil {
+		v.error("nil defer")
+		return
+	}
+
+	if (def.Stat == nil) == (def.Block == nil) {
+		v.error("defer must have either a statement or a block")
+	}
+	if def.After < after || def.After > len(b.Instr) {
+		v.error("defer runs after %d instructions in a block of %d", def.After, len(b.Instr))
+	}
+
+	if def.Stat != nil {
+		v.verifyInstr(def.Stat)
+	}
+	if def.Block != nil {
+		v.verifyBlock(def.Block)
+	}
+}
+
+func (v *verifier) verifyBlock(b *Block) {
+	if b == nil {
+		v.error("nil block")
+		return
+	}
+
+	for _, instr := range b.Instr {
+		v.verifyInstr(instr)
+	}
+
+	after := 0
+	for _, def := range b.DeferStack {
+		v.verifyDefer(def, b, after)
+		if def != nil {
+			after = def.After
+		}
+	}
+}
+
+// DECLARATIONS
+
+func (v *verifier) verifyFunc(fn *Function) {
+	defer v.within(fn.Name.Span)()
+
+	v.verifyDict("params of '"+fn.Name.Value+"'", fn.Param)
+	v.verifyType(fn.ReturnType)
+
+	if fn.Receiver != nil {
+		if fn.Receiver.Parent == "" {
+			v.error("receiver of '%s' has no parent", fn.Name.Value)
+		}
+		if fn.Param == nil || len(fn.Param.Order) == 0 {
+			v.error("method '%s' has no param for its receiver", fn.Name.Value)
+		}
+	}
+
+	v.verifyBlock(fn.Body)
+}
+
+func (v *verifier) verifyModule(mod *Module) {
+	for _, name := range mod.StructureOrder {
+		done := v.within(name.Span)
+		if st, ok := mod.Structures[name.Value]; !ok || st == nil {
+			v.error("structure '%s' is declared but has no entry", name.Value)
+		} else {
+			v.verifyStructure(st)
+		}
+		done()
+	}
+	if len(mod.StructureOrder) != len(mod.Structures) {
+		v.error("module has %d structures but %d in their order", len(mod.Structures), len(mod.StructureOrder))
+	}
+
+	if mod.Global == nil {
+		v.error("module has no global block")
+	} else {
+		v.verifyBlock(mod.Global)
+	}
+
+	for _, name := range mod.FunctionOrder {
+		done := v.within(name.Span)
+		if fn, ok := mod.Functions[name.Value]; !ok || fn == nil {
+			v.error("function '%s' is declared but has no entry", name.Value)
+		} else {
+			v.verifyFunc(fn)
+		}
+		done()
+	}
+	if len(mod.FunctionOrder) != len(mod.Functions) {
+		v.error("module has %d functions but %d in their order", len(mod.Functions), len(mod.FunctionOrder))
+	}
+
+	for _, name := range mod.ImplsOrder {
+		done := v.within(name.Span)
+		impl, ok := mod.Impls[name.Value]
+		if !ok || impl == nil {
+			v.error("impl '%s' is declared but has no entry", name.Value)
+			done()
+			continue
+		}
+
+		for _, method := range impl.Order {
+			if fn, ok := impl.Methods[method.Value]; !ok || fn == nil {
+				v.error("method '%s.%s' is declared but has no entry", name.Value, method.Value)
+			} else {
+				v.verifyFunc(fn)
+			}
+		}
+		if len(impl.Order) != len(impl.Methods) {
+			v.error("impl '%s' has %d methods but %d in their order", name.Value, len(impl.Methods), len(impl.Order))
+		}
+		done()
+	}
+	if len(mod.ImplsOrder) != len(mod.Impls) {
+		v.error("module has %d impls but %d in their order", len(mod.Impls), len(mod.ImplsOrder))
+	}
+}
+
+// Verify checks that the given module is well formed, and
+// returns an error for every structural violation in it.
+func Verify(mod *Module) []api.CompilerError {
+	v := &verifier{
+		errs:       []api.CompilerError{},
+		structures: map[*Structure]bool{},
+	}
+	if mod == nil {
+		v.error("nil module")
+		return v.errs
+	}
+	v.verifyModule(mod)
+	return v.errs
+}
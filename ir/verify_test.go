@@ -0,0 +1,150 @@
+package ir
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const verifySource = `type Point = struct { x i32, y i32, };
+fn main() int {
+	let a i32 = 1 + 2;
+	let b = a;
+	defer let c = 3;
+	return a;
+}`
+
+// mainInstr returns the nth instruction of main.
+func mainInstr(mod *Module, n int) *Instruction {
+	return mod.Functions["main"].Body.Instr[n]
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(mod *Module)
+		want    string
+	}{
+		{
+			name:    "well formed",
+			corrupt: func(mod *Module) {},
+		},
+		{
+			name: "instruction kind doesn't match its field",
+			corrupt: func(mod *Module) {
+				mainInstr(mod, 0).Kind = ReturnInstr
+			},
+			want: "Malformed IR: instruction of kind 'returnInstr' has the field for 'localInstr' set",
+		},
+		{
+			name: "instruction with more than one field",
+			corrupt: func(mod *Module) {
+				mainInstr(mod, 0).Return = NewReturn(nil)
+			},
+			want: "Malformed IR: instruction of kind 'localInstr' has more than one field set (localInstr, returnInstr)",
+		},
+		{
+			name: "instruction with no field",
+			corrupt: func(mod *Module) {
+				mainInstr(mod, 0).Local = nil
+			},
+			want: "Malformed IR: instruction of kind 'localInstr' has no fields set",
+		},
+		{
+			name: "value with an empty kind",
+			corrupt: func(mod *Module) {
+				mainInstr(mod, 1).Local.Val.Kind = ""
+			},
+			want: "Malformed IR: value has no kind",
+		},
+		{
+			name: "value kind doesn't match its field",
+			corrupt: func(mod *Module) {
+				mainInstr(mod, 1).Local.Val.Kind = IntegerValueValue
+			},
+			want: "Malformed IR: value of kind 'IntegerValue' has the field for 'Identifier' set",
+		},
+		{
+			name: "nil operand",
+			corrupt: func(mod *Module) {
+				mainInstr(mod, 0).Local.Val.BinaryExpression.RHand = nil
+			},
+			want: "Malformed IR: right hand of '+' is nil",
+		},
+		{
+			name: "nil type",
+			corrupt: func(mod *Module) {
+				mod.Functions["main"].ReturnType = nil
+			},
+			want: "Malformed IR: nil type",
+		},
+		{
+			name: "type kind doesn't match its field",
+			corrupt: func(mod *Module) {
+				mainInstr(mod, 0).Local.Type = &Type{Kind: FloatKind, IntegerType: NewIntegerType(32, true)}
+			},
+			want: "Malformed IR: type of kind 'float' has the field for 'int' set",
+		},
+		{
+			name: "local with no type or value",
+			corrupt: func(mod *Module) {
+				mainInstr(mod, 1).Local.Val = nil
+			},
+			want: "Malformed IR: local 'b' has no type and no value to infer it from",
+		},
+		{
+			name: "defer in the instructions",
+			corrupt: func(mod *Module) {
+				body := mod.Functions["main"].Body
+				body.Instr = append(body.Instr, &Instruction{Kind: DeferInstr, Defer: body.DeferStack[0]})
+			},
+			want: "Malformed IR: defer in the instructions of a block",
+		},
+		{
+			name: "defer after the end of its block",
+			corrupt: func(mod *Module) {
+				mod.Functions["main"].Body.DeferStack[0].After = 10
+			},
+			want: "Malformed IR: defer runs after 10 instructions in a block of 3",
+		},
+		{
+			name: "structure fields out of order",
+			corrupt: func(mod *Module) {
+				fields := mod.Structures["Point"].Fields
+				fields.Order = fields.Order[:1]
+			},
+			want: "Malformed IR: fields of 'Point' have 2 entries but 1 in their order",
+		},
+		{
+			name: "function declared without an entry",
+			corrupt: func(mod *Module) {
+				delete(mod.Functions, "main")
+			},
+			want: "Malformed IR: function 'main' is declared but has no entry",
+		},
+		{
+			name: "nil block",
+			corrupt: func(mod *Module) {
+				mod.Functions["main"].Body = nil
+			},
+			want: "Malformed IR: nil block",
+		},
+	}
+
+	for _, test := range tests {
+		mod := buildModule(t, verifySource)
+		test.corrupt(mod)
+
+		errs := Verify(mod)
+		if test.want == "" {
+			assert.Empty(t, errs, test.name)
+			continue
+		}
+
+		titles := []string{}
+		for _, err := range errs {
+			titles = append(titles, err.Title)
+		}
+		assert.Contains(t, titles, test.want, test.name)
+	}
+}
@@ -0,0 +1,373 @@
+package ir
+
+// Node is any part of the ir that can be walked, one of
+// *Module, *Structure, *Function, *Block, *Defer,
+// *Instruction or *Value.
+type Node interface{}
+
+// Cursor describes a node that is being walked
+// and where it is in the tree.
+type Cursor struct {
+	w       *walker
+	node    Node
+	parent  Node
+	set     func(Node)
+	iter    *iterator
+	deleted bool
+}
+
+// iterator is the position of the walk in the
+// instructions of a block.
+type iterator struct {
+	index int
+}
+
+// Node returns the node being walked.
+func (c *Cursor) Node() Node {
+	return c.node
+}
+
+// Parent returns the node that the current node is in,
+// or nil if it's the node that the walk started from.
+func (c *Cursor) Parent() Node {
+	return c.parent
+}
+
+// Block returns the innermost block that the node is in,
+// or nil if it isn't in one.
+func (c *Cursor) Block() *Block {
+	if len(c.w.blocks) == 0 {
+		return nil
+	}
+	return c.w.blocks[len(c.w.blocks)-1]
+}
+
+// Function returns the function that the node is in,
+// or nil if it isn't in one.
+func (c *Cursor) Function() *Function {
+	if len(c.w.fns) == 0 {
+		return nil
+	}
+	return c.w.fns[len(c.w.fns)-1]
+}
+
+// Index returns the index of the node in the instructions
+// of its parent block, or -1 if it isn't an instruction
+// in a block.
+func (c *Cursor) Index() int {
+	if c.iter == nil {
+		return -1
+	}
+	return c.iter.index
+}
+
+// Replace replaces the node with n, which must be the same
+// kind of node. if it's called in pre the children of n are
+// walked instead.
+func (c *Cursor) Replace(n Node) {
+	if c.deleted {
+		panic("Replace: node has been deleted")
+	}
+	c.set(n)
+	c.node = n
+}
+
+// Delete removes the node from its block, it must be an
+// instruction in a block. if it's called in pre the children
+// of the node are not walked and post is not called.
+func (c *Cursor) Delete() {
+	if c.iter == nil {
+		panic("Delete: node is not an instruction in a block")
+	}
+	if c.deleted {
+		return
+	}
+
+	b := c.parent.(*Block)
+	idx := c.iter.index
+	b.Instr = append(b.Instr[:idx], b.Instr[idx+1:]...)
+
+	// the defers after the instruction move up with
+	// the instructions that follow it.
+	for _, def := range b.DeferStack {
+		if def.After > idx {
+			def.After--
+		}
+	}
+
+	c.iter.index--
+	c.deleted = true
+}
+
+type walker struct {
+	pre, post func(*Cursor) bool
+	stopped   bool
+
+	blocks []*Block
+	fns    []*Function
+}
+
+// Walk walks the tree of nodes under root in depth first order.
+// pre is called for each node before its children are walked,
+// if it returns false the children are skipped and post is not
+// called. post is called for each node after its children, if
+// it returns false the walk is stopped. either can be nil.
+//
+// nodes can be changed with the cursor while they are walked,
+// the root is returned in case it was replaced.
+//
+// the methods of a structure are registered with its impl,
+// so a module walks them in the impl and not the structure.
+// the defers of a block are walked between the instructions
+// that they were pushed between.
+func Walk(root Node, pre, post func(*Cursor) bool) Node {
+	w := &walker{pre: pre, post: post}
+	w.apply(nil, root, func(n Node) { root = n }, nil)
+	return root
+}
+
+// Inspect walks the tree of nodes under root in depth first
+// order. f is called for each node, and the children of the
+// node are walked if it returns true, followed by f(nil).
+func Inspect(root Node, f func(Node) bool) {
+	Walk(root, func(c *Cursor) bool {
+		return f(c.Node())
+	}, func(c *Cursor) bool {
+		f(nil)
+		return true
+	})
+}
+
+func isNil(n Node) bool {
+	switch n := n.(type) {
+	case *Module:
+		return n == nil
+	case *Structure:
+		return n == nil
+	case *Function:
+		return n == nil
+	case *Block:
+		return n == nil
+	case *Defer:
+		return n == nil
+	case *Instruction:
+		return n == nil
+	case *Value:
+		return n == nil
+	}
+	return n == nil
+}
+
+func (w *walker) apply(parent, node Node, set func(Node), iter *iterator) {
+	if w.stopped || isNil(node) {
+		return
+	}
+
+	c := &Cursor{w: w, node: node, parent: parent, set: set, iter: iter}
+	if w.pre != nil && !w.pre(c) {
+		return
+	}
+	if c.deleted {
+		return
+	}
+
+	w.children(c.node)
+
+	if w.stopped {
+		return
+	}
+	if w.post != nil && !w.post(c) {
+		w.stopped = true
+	}
+}
+
+func (w *walker) value(parent Node, slot **Value) {
+	w.apply(parent, *slot, func(n Node) { *slot = n.(*Value) }, nil)
+}
+
+func (w *walker) block(parent Node, slot **Block) {
+	w.apply(parent, *slot, func(n Node) { *slot = n.(*Block) }, nil)
+}
+
+func (w *walker) children(node Node) {
+	switch n := node.(type) {
+	case *Module:
+		w.module(n)
+
+	case *Structure:
+		if n.Fields == nil {
+			return
+		}
+		for _, name := range n.Fields.Order {
+			if field, ok := n.Fields.Data[name.Value]; ok {
+				w.value(n, &field.Val)
+			}
+		}
+
+	case *Function:
+		w.fns = append(w.fns, n)
+		w.block(n, &n.Body)
+		w.fns = w.fns[:len(w.fns)-1]
+
+	case *Block:
+		w.blocks = append(w.blocks, n)
+		w.instrs(n)
+		w.blocks = w.blocks[:len(w.blocks)-1]
+
+	case *Defer:
+		w.apply(n, n.Stat, func(r Node) { n.Stat = r.(*Instruction) }, nil)
+		w.block(n, &n.Block)
+
+	case *Instruction:
+		for _, slot := range instrSlots(n) {
+			switch s := slot.(type) {
+			case **Value:
+				w.value(n, s)
+			case **Block:
+				w.block(n, s)
+			case **Defer:
+				w.apply(n, *s, func(r Node) { *s = r.(*Defer) }, nil)
+			}
+		}
+
+	case *Value:
+		for _, slot := range valueSlots(n) {
+			w.value(n, slot)
+		}
+	}
+}
+
+func (w *walker) module(m *Module) {
+	w.block(m, &m.Global)
+
+	for _, name := range m.StructureOrder {
+		key := name.Value
+		w.apply(m, m.Structures[key], func(r Node) {
+			m.Structures[key] = r.(*Structure)
+		}, nil)
+	}
+
+	seen := map[string]bool{}
+	for _, name := range m.FunctionOrder {
+		key := name.Value
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		w.apply(m, m.Functions[key], func(r Node) {
+			m.Functions[key] = r.(*Function)
+		}, nil)
+	}
+
+	for _, name := range m.ImplsOrder {
+		impl := m.Impls[name.Value]
+		for _, method := range impl.Order {
+			key := method.Value
+			w.apply(m, impl.Methods[key], func(r Node) {
+				impl.Methods[key] = r.(*Function)
+			}, nil)
+		}
+	}
+}
+
+func (w *walker) instrs(b *Block) {
+	iter := &iterator{}
+	next := 0
+	for iter.index = 0; iter.index < len(b.Instr); iter.index++ {
+		for next < len(b.DeferStack) && b.DeferStack[next].After <= iter.index {
+			w.deferred(b, next)
+			next++
+		}
+		w.apply(b, b.Instr[iter.index], func(n Node) {
+			b.Instr[iter.index] = n.(*Instruction)
+		}, iter)
+	}
+	for ; next < len(b.DeferStack); next++ {
+		w.deferred(b, next)
+	}
+}
+
+func (w *walker) deferred(b *Block, idx int) {
+	w.apply(b, b.DeferStack[idx], func(n Node) {
+		b.DeferStack[idx] = n.(*Defer)
+	}, nil)
+}
+
+// instrSlots returns where the children of the instruction
+// are kept, in the order that they're walked. each is a
+// **Value, **Block or **Defer.
+func instrSlots(i *Instruction) []interface{} {
+	switch i.Kind {
+	case BlockInstr:
+		return []interface{}{&i.Block}
+	case AssignInstr:
+		return []interface{}{&i.Assign.LHand, &i.Assign.RHand}
+	case LocalInstr:
+		return []interface{}{&i.Local.Val}
+	case AllocaInstr:
+		return []interface{}{&i.Alloca.Val}
+	case ReturnInstr:
+		return []interface{}{&i.Return.Val}
+	case LoopInstr:
+		return []interface{}{&i.Loop.Body}
+	case WhileLoopInstr:
+		w := i.WhileLoop
+		return []interface{}{&w.Cond, &w.Post, &w.Body}
+	case IfStatementInstr:
+		iff := i.IfStatement
+		res := []interface{}{&iff.Cond, &iff.True}
+		for _, elif := range iff.ElseIf {
+			res = append(res, &elif.Cond, &elif.Body)
+		}
+		return append(res, &iff.Else)
+	case ElseIfStatementInstr:
+		elif := i.ElseIfStatement
+		return []interface{}{&elif.Cond, &elif.Body}
+	case ExpressionInstr:
+		return []interface{}{&i.ExpressionStatement}
+	case DeferInstr:
+		return []interface{}{&i.Defer}
+	}
+	return nil
+}
+
+// valueSlots returns where the values directly inside of
+// the value are kept, in the order they are worked out.
+func valueSlots(v *Value) []**Value {
+	switch v.Kind {
+	case GroupingValue:
+		return []**Value{&v.Grouping.Val}
+	case BinaryExpressionValue:
+		b := v.BinaryExpression
+		return []**Value{&b.LHand, &b.RHand}
+	case AssignValue:
+		return []**Value{&v.Assign.LHand, &v.Assign.RHand}
+	case UnaryExpressionValue:
+		return []**Value{&v.UnaryExpression.Val}
+	case BuiltinValue:
+		return sliceSlots(v.Builtin.Args)
+	case CallValue:
+		return append([]**Value{&v.Call.Left}, sliceSlots(v.Call.Params)...)
+	case PathValue:
+		return sliceSlots(v.Path.Values)
+	case IndexValue:
+		return []**Value{&v.Index.Left, &v.Index.Sub}
+	case SliceValue:
+		s := v.Slice
+		return []**Value{&s.Left, &s.Low, &s.High}
+	case InitValue:
+		return sliceSlots(v.Init.Values)
+	case CastValue:
+		return []**Value{&v.Cast.Val}
+	}
+	return nil
+}
+
+func sliceSlots(vals []*Value) []**Value {
+	res := make([]**Value, len(vals))
+	for i := range vals {
+		res[i] = &vals[i]
+	}
+	return res
+}
@@ -0,0 +1,273 @@
+package ir
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildFunc builds a module with a single
+// function f and returns the function.
+func buildFunc(t *testing.T, src string) *Function {
+	return buildModule(t, src).Functions["f"]
+}
+
+// describe names the node so the order of a walk
+// can be compared against a list of strings.
+func describe(n Node) string {
+	switch n := n.(type) {
+	case *Function:
+		return "fn " + n.Name.Value
+	case *Block:
+		return "{"
+	case *Defer:
+		return "defer"
+	case *Instruction:
+		if n.Kind == LocalInstr {
+			return "let " + n.Local.Name.Value
+		}
+		return string(n.Kind)
+	case *Value:
+		switch n.Kind {
+		case IdentifierValue:
+			return n.Identifier.Name.Value
+		case IntegerValueValue:
+			return n.IntegerValue.RawValue.String()
+		}
+		return string(n.Kind)
+	}
+	return "?"
+}
+
+func TestWalkOrder(t *testing.T) {
+	fn := buildFunc(t, `fn f() {
+	let a = 1;
+	defer let d = 2;
+	let b = a;
+	defer let e = 3;
+}`)
+
+	var pre, post []string
+	Walk(fn, func(c *Cursor) bool {
+		pre = append(pre, describe(c.Node()))
+		return true
+	}, func(c *Cursor) bool {
+		post = append(post, describe(c.Node()))
+		return true
+	})
+
+	// defers are walked after the instructions
+	// that come before them in the block.
+	assert.Equal(t, []string{
+		"fn f", "{",
+		"let a", "1",
+		"defer", "let d", "2",
+		"let b", "a",
+		"defer", "let e", "3",
+	}, pre)
+	assert.Equal(t, []string{
+		"1", "let a",
+		"2", "let d", "defer",
+		"a", "let b",
+		"3", "let e", "defer",
+		"{", "fn f",
+	}, post)
+}
+
+func TestWalkCursor(t *testing.T) {
+	fn := buildFunc(t, `fn f() {
+	let a = 1;
+	defer let d = 2;
+}`)
+
+	Walk(fn, func(c *Cursor) bool {
+		switch n := c.Node().(type) {
+		case *Block:
+			assert.Equal(t, fn, c.Function())
+			assert.Equal(t, -1, c.Index())
+		case *Instruction:
+			assert.Equal(t, fn.Body, c.Block())
+			if n.Local.Name.Value == "a" {
+				assert.Equal(t, fn.Body, c.Parent())
+				assert.Equal(t, 0, c.Index())
+			} else {
+				// the statement of a defer isn't
+				// an instruction in the block.
+				assert.IsType(t, &Defer{}, c.Parent())
+				assert.Equal(t, -1, c.Index())
+			}
+		}
+		return true
+	}, nil)
+}
+
+func TestWalkReplaceInPre(t *testing.T) {
+	fn := buildFunc(t, `fn f() {
+	let a = 1;
+	let b = a;
+}`)
+
+	// the identifier is replaced with a binary expression
+	// in pre, so its children are walked in place of it.
+	var seen []string
+	Walk(fn, func(c *Cursor) bool {
+		v, ok := c.Node().(*Value)
+		if ok && v.Kind == IdentifierValue {
+			c.Replace(&Value{Kind: BinaryExpressionValue, BinaryExpression: &BinaryExpression{
+				LHand: &Value{Kind: IntegerValueValue, IntegerValue: NewIntegerValue(big.NewInt(4))},
+				Op:    "+",
+				RHand: &Value{Kind: IntegerValueValue, IntegerValue: NewIntegerValue(big.NewInt(5))},
+			}})
+		}
+		seen = append(seen, describe(c.Node()))
+		return true
+	}, nil)
+
+	assert.Equal(t, []string{
+		"fn f", "{",
+		"let a", "1",
+		"let b", BinaryExpressionValue, "4", "5",
+	}, seen)
+
+	val := fn.Body.Instr[1].Local.Val
+	assert.EqualValues(t, BinaryExpressionValue, val.Kind)
+}
+
+func TestWalkReplaceRoot(t *testing.T) {
+	fn := buildFunc(t, `fn f() {
+}`)
+
+	other := &Function{Name: fn.Name, Body: fn.Body}
+	res := Walk(fn, func(c *Cursor) bool {
+		if c.Node() == fn {
+			c.Replace(other)
+		}
+		return true
+	}, nil)
+	assert.Equal(t, other, res)
+}
+
+func TestWalkDelete(t *testing.T) {
+	fn := buildFunc(t, `fn f() {
+	let a = 1;
+	let b = 2;
+	defer let d = 3;
+	let c = 4;
+	defer let e = 5;
+}`)
+
+	// deleting b moves the defers after it up by one,
+	// and c is still walked after the delete.
+	var seen []string
+	Walk(fn, func(c *Cursor) bool {
+		seen = append(seen, describe(c.Node()))
+		if i, ok := c.Node().(*Instruction); ok && c.Index() != -1 && i.Local.Name.Value == "b" {
+			c.Delete()
+			assert.Equal(t, 0, c.Index())
+		}
+		return true
+	}, nil)
+
+	assert.Equal(t, []string{
+		"fn f", "{",
+		"let a", "1",
+		"let b",
+		"defer", "let d", "3",
+		"let c", "4",
+		"defer", "let e", "5",
+	}, seen)
+
+	body := fn.Body
+	assert.Len(t, body.Instr, 2)
+	assert.Equal(t, "a", body.Instr[0].Local.Name.Value)
+	assert.Equal(t, "c", body.Instr[1].Local.Name.Value)
+	assert.Equal(t, 1, body.DeferStack[0].After)
+	assert.Equal(t, 2, body.DeferStack[1].After)
+}
+
+func TestWalkDeleteInPost(t *testing.T) {
+	fn := buildFunc(t, `fn f() {
+	let a = 1;
+	let b = 2;
+	let c = 3;
+}`)
+
+	var seen []string
+	Walk(fn, nil, func(c *Cursor) bool {
+		seen = append(seen, describe(c.Node()))
+		if i, ok := c.Node().(*Instruction); ok && i.Local.Name.Value != "c" {
+			c.Delete()
+		}
+		return true
+	})
+
+	assert.Equal(t, []string{
+		"1", "let a",
+		"2", "let b",
+		"3", "let c",
+		"{", "fn f",
+	}, seen)
+	assert.Len(t, fn.Body.Instr, 1)
+	assert.Equal(t, "c", fn.Body.Instr[0].Local.Name.Value)
+}
+
+func TestWalkStopInPost(t *testing.T) {
+	fn := buildFunc(t, `fn f() {
+	let a = 1;
+	let b = 2;
+	let c = 3;
+}`)
+
+	// returning false from post stops the walk, so
+	// nothing after b is visited by pre or post.
+	var pre, post []string
+	Walk(fn, func(c *Cursor) bool {
+		pre = append(pre, describe(c.Node()))
+		return true
+	}, func(c *Cursor) bool {
+		post = append(post, describe(c.Node()))
+		i, ok := c.Node().(*Instruction)
+		return !ok || i.Local.Name.Value != "b"
+	})
+
+	assert.Equal(t, []string{"fn f", "{", "let a", "1", "let b", "2"}, pre)
+	assert.Equal(t, []string{"1", "let a", "2", "let b"}, post)
+}
+
+func TestWalkSkipChildren(t *testing.T) {
+	fn := buildFunc(t, `fn f() {
+	let a = 1;
+	let b = 2;
+}`)
+
+	// returning false from pre skips the children
+	// and post of the node, but not its siblings.
+	var post []string
+	Walk(fn, func(c *Cursor) bool {
+		i, ok := c.Node().(*Instruction)
+		return !ok || i.Local.Name.Value != "a"
+	}, func(c *Cursor) bool {
+		post = append(post, describe(c.Node()))
+		return true
+	})
+
+	assert.Equal(t, []string{"2", "let b", "{", "fn f"}, post)
+}
+
+func TestInspect(t *testing.T) {
+	fn := buildFunc(t, `fn f() {
+	let a = 1;
+}`)
+
+	var seen []string
+	Inspect(fn, func(n Node) bool {
+		if n == nil {
+			seen = append(seen, "end")
+			return true
+		}
+		seen = append(seen, describe(n))
+		return true
+	})
+	assert.Equal(t, []string{"fn f", "{", "let a", "1", "end", "end", "end", "end"}, seen)
+}
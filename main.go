@@ -9,11 +9,16 @@ import (
 	raven "github.com/getsentry/raven-go"
 	"github.com/gin-gonic/gin"
 	"github.com/krug-lang/caasper/controller"
+	"github.com/krug-lang/caasper/service"
 )
 
 func main() {
 	raven.SetDSN(os.Getenv("SENTRY_KEY"))
 
+	// verify the ir module between passes, for
+	// debugging passes that leave it malformed.
+	service.VerifyIR = os.Getenv("VERIFY_IR") != ""
+
 	router := gin.Default()
 	controller.RegisterRoutes(router)
 
@@ -80,6 +80,10 @@ type borrowChecker struct {
 	scopeDict     *ir.ScopeDict
 	lifetime      *lifetime
 	prevLifetimes []*lifetime
+
+	// lhands is what the values being
+	// walked are loaned to.
+	lhands []*ir.Value
 }
 
 /*
@@ -123,28 +127,6 @@ func (b *borrowChecker) pushLifetime() {
 	b.lifetime = newLifetime
 }
 
-func (b *borrowChecker) visitLocal(loc *ir.Local) {
-	if loc.Val != nil {
-		b.visitExpr(loc.Val, loc.Val)
-	}
-
-	// this variable owns its memory,
-	// add it to the lifetime.
-	if loc.Owned {
-		lt := b.lifetime
-		lt.addLocal(newLoc(loc))
-	}
-}
-
-func (b *borrowChecker) visitCall(call *ir.Call) {
-	// we dont care about the call sites type information
-	// we can check for params that are identifiers
-	// and see if they are using owned values or not
-	for _, param := range call.Params {
-		b.visitExpr(call.Left, param)
-	}
-}
-
 // getIdentifierRef will look for the local that this
 // identifier is referencing in the lifetimes or parent lifetimes
 func (b *borrowChecker) getIdentifierRef(iden *ir.Identifier) *local {
@@ -188,123 +170,119 @@ func (b *borrowChecker) tryLoan(iden *ir.Identifier, loanee *ir.Value) {
 	}
 }
 
-func (b *borrowChecker) visitExpr(lhand *ir.Value, expr *ir.Value) {
-	switch expr.Kind {
-	case ir.CallValue:
-		b.visitCall(expr.Call)
-	case ir.IdentifierValue:
-		b.tryLoan(expr.Identifier, lhand)
-
-	case ir.UnaryExpressionValue:
-		b.visitExpr(lhand, expr.UnaryExpression.Val)
-
-	case ir.BinaryExpressionValue:
-		b.visitExpr(lhand, expr.BinaryExpression.LHand)
-		b.visitExpr(lhand, expr.BinaryExpression.RHand)
-
-	case ir.IntegerValueValue:
-		break
-	case ir.FloatingValueValue:
-		break
-	case ir.StringValueValue:
-		break
-
-	case ir.BuiltinValue:
-		b.visitBuiltin(lhand, expr.Builtin)
-
-	case ir.AssignValue:
-		b.visitExpr(expr.Assign.LHand, expr.Assign.RHand)
-
-	case ir.PathValue:
-		// TODO!
+// loanee returns the value that the value being walked
+// is loaned to, from where the value is.
+func (b *borrowChecker) loanee(c *ir.Cursor) *ir.Value {
+	switch p := c.Parent().(type) {
+	case *ir.Instruction:
+		switch p.Kind {
+		case ir.LocalInstr:
+			return p.Local.Val
+		case ir.AssignInstr:
+			return p.Assign.LHand
+		}
 
-	default:
-		panic(fmt.Sprintf("unhandled expr %s", expr.Kind))
+	case *ir.Value:
+		switch p.Kind {
+		case ir.CallValue:
+			return p.Call.Left
+		case ir.AssignValue:
+			return p.Assign.LHand
+		}
+		return b.lhands[len(b.lhands)-1]
 	}
+	return nil
 }
 
-func (b *borrowChecker) visitInstr(parent *ir.Block, instr *ir.Instruction) {
-	stab := b.scopeDict.Data[parent.ID]
-	fmt.Println("{", stab, "}")
-
-	switch instr.Kind {
-	case ir.LocalInstr:
-		b.visitLocal(instr.Local)
-	case ir.ExpressionInstr:
-		b.visitExpr(nil, instr.ExpressionStatement)
-	case ir.ReturnInstr:
-		ret := instr.Return
-		if ret.Val != nil {
-			b.visitExpr(nil, ret.Val)
+// loaned returns whether the value being walked
+// can be loaned, the function that is called and
+// the value being assigned to are not.
+func loaned(c *ir.Cursor) bool {
+	val := c.Node().(*ir.Value)
+	switch p := c.Parent().(type) {
+	case *ir.Instruction:
+		if p.Kind == ir.AssignInstr {
+			return val != p.Assign.LHand
 		}
 
-	case ir.WhileLoopInstr:
-		b.visitExpr(nil, instr.WhileLoop.Cond)
-		if post := instr.WhileLoop.Post; post != nil {
-			b.visitExpr(nil, post)
+	case *ir.Value:
+		switch p.Kind {
+		case ir.CallValue:
+			return val != p.Call.Left
+		case ir.AssignValue:
+			return val != p.Assign.LHand
 		}
-		b.visitBlock(instr.WhileLoop.Body)
+	}
+	return true
+}
 
-	case ir.LoopInstr:
-		b.visitBlock(instr.Loop.Body)
+func (b *borrowChecker) enter(c *ir.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *ir.Block:
+		b.pushLifetime()
 
-	case ir.BreakInstr:
-		break
+	case *ir.Value:
+		if !loaned(c) {
+			return false
+		}
 
-	case ir.IfStatementInstr:
-		b.visitExpr(nil, instr.IfStatement.Cond)
-		b.visitBlock(instr.IfStatement.True)
-		// TODO else if and elses.
+		lhand := b.loanee(c)
+		switch n.Kind {
+		case ir.IdentifierValue:
+			b.tryLoan(n.Identifier, lhand)
 
-	case ir.JumpInstr:
-	case ir.LabelInstr:
+		case ir.BuiltinValue:
+			b.visitBuiltin(lhand, n.Builtin)
+			return false
 
-	default:
-		panic(fmt.Sprintf("unhandled instruction %s", instr.Kind))
+		case ir.PathValue:
+			// TODO!
+			return false
+		}
+		b.lhands = append(b.lhands, lhand)
 	}
+	return true
 }
 
-func (b *borrowChecker) popLifetime() {
-	b.lifetime = b.lifetime.outer
-}
-
-func (b *borrowChecker) visitBlockPre(block *ir.Block, preVisit func()) {
-	b.pushLifetime()
+func (b *borrowChecker) leave(c *ir.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *ir.Block:
+		b.popLifetime()
 
-	if preVisit != nil {
-		preVisit()
-	}
-
-	for _, instr := range block.Instr {
-		switch instr.Kind {
-		case ir.BlockInstr:
-			b.visitBlock(instr.Block)
-		default:
-			b.visitInstr(block, instr)
+	case *ir.Instruction:
+		// this variable owns its memory,
+		// add it to the lifetime.
+		if n.Kind == ir.LocalInstr && n.Local.Owned {
+			b.lifetime.addLocal(newLoc(n.Local))
 		}
-	}
 
-	b.popLifetime()
+	case *ir.Value:
+		b.lhands = b.lhands[:len(b.lhands)-1]
+	}
+	return true
 }
 
-func (b *borrowChecker) visitBlock(block *ir.Block) {
-	b.visitBlockPre(block, nil)
+func (b *borrowChecker) popLifetime() {
+	b.lifetime = b.lifetime.outer
 }
 
 func (b *borrowChecker) validate(fn *ir.Function) {
 	b.block = fn.Body
 
-	b.visitBlockPre(b.block, func() {
-		for _, tok := range fn.Param.Order {
-			loc, ok := fn.Param.Data[tok.Value]
-			if !ok {
-				panic("this should never happen")
-			}
-			if loc.Owned {
-				b.lifetime.addLocal(newLoc(loc))
-			}
+	// the params are in a lifetime around the body.
+	b.pushLifetime()
+	for _, tok := range fn.Param.Order {
+		loc, ok := fn.Param.Data[tok.Value]
+		if !ok {
+			panic("this should never happen")
 		}
-	})
+		if loc.Owned {
+			b.lifetime.addLocal(newLoc(loc))
+		}
+	}
+
+	ir.Walk(fn.Body, b.enter, b.leave)
+	b.popLifetime()
 }
 
 func borrowCheck(mod *ir.Module, scopeDict *ir.ScopeDict) []api.CompilerError {
@@ -24,7 +24,7 @@ func (b *builder) pushStab(name string) *ir.SymbolTable {
 	prev := b.curr
 
 	// setup a new stab to push
-	pushed := ir.NewSymbolTable(prev)
+	pushed := ir.NewSymbolTable(b.mod.IDSource(), prev)
 	// store as the current
 	b.curr = pushed
 
@@ -63,39 +63,33 @@ func (b *builder) pushBlock(id int) *ir.SymbolTable {
 	return b.pushStab(fmt.Sprintf("%d", id))
 }
 
-func (b *builder) visitBlock(i *ir.Block) *ir.SymbolTable {
-	i.Stab = b.pushBlock(b.blockCount)
-	b.blockCount++
+// enter pushes a stab for each block, and registers any
+// locals in the current stab. the body of a function
+// shares the stab of the function.
+func (b *builder) enter(c *ir.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *ir.Block:
+		if c.Parent() == nil {
+			return true
+		}
+		n.Stab = b.pushBlock(b.blockCount)
+		b.blockCount++
 
-	for _, instr := range i.Instr {
-		b.visitInstr(instr)
+	case *ir.Instruction:
+		b.visitInstr(n)
 	}
-
-	b.popStab()
-	return i.Stab
+	return true
 }
 
-func (b *builder) visitIfStat(iff *ir.IfStatement) {
-	b.visitBlock(iff.True)
-	for _, e := range iff.ElseIf {
-		b.visitBlock(e.Body)
-	}
-	if iff.Else != nil {
-		b.visitBlock(iff.Else)
+func (b *builder) leave(c *ir.Cursor) bool {
+	if _, ok := c.Node().(*ir.Block); ok && c.Parent() != nil {
+		b.popStab()
 	}
-}
-
-func (b *builder) visitWhileLoop(while *ir.WhileLoop) {
-	b.visitBlock(while.Body)
-}
-
-func (b *builder) visitLoop(loop *ir.Loop) {
-	b.visitBlock(loop.Body)
+	return true
 }
 
 func (b *builder) visitInstr(i *ir.Instruction) {
 	switch i.Kind {
-
 	case ir.AllocaInstr:
 		instr := i.Alloca
 		ok := b.curr.Register(instr.Name.Value, &ir.SymbolValue{
@@ -115,38 +109,24 @@ func (b *builder) visitInstr(i *ir.Instruction) {
 		if !ok {
 			b.error(api.NewSymbolError(instr.Name.Value, instr.Name.Span...))
 		}
+	}
+}
 
-	case ir.IfStatementInstr:
-		instr := i.IfStatement
-		b.visitIfStat(instr)
-	case ir.WhileLoopInstr:
-		instr := i.WhileLoop
-		b.visitWhileLoop(instr)
-	case ir.LoopInstr:
-		instr := i.Loop
-		b.visitLoop(instr)
-
-	case ir.BlockInstr:
-		instr := i.Block
-		b.visitBlock(instr)
-
-	case ir.ExpressionInstr:
-		fmt.Println(i.ExpressionStatement)
-
-		/*
-			case *ir.Path:
-				return
-			case *ir.Return:
-				return
-			case *ir.Call:
-				return
-			case *ir.Assign:
-				return
-		*/
-
-	default:
-		panic(fmt.Sprintf("unhandled instr %s", i.Kind))
+// visitGlobal registers the modules global
+// variables in a new symbol table.
+func (b *builder) visitGlobal(global *ir.Block) *ir.SymbolTable {
+	b.clearScope()
+	res := b.pushStab("global")
+
+	for _, instr := range global.Instr {
+		if instr.Kind != ir.LocalInstr {
+			continue
+		}
+		b.visitInstr(instr)
 	}
+
+	b.popStab()
+	return res
 }
 
 // hack we shouldnt have to do this in the first place?
@@ -173,11 +153,9 @@ func (b *builder) visitFunc(fn *ir.Function) *ir.SymbolTable {
 		}
 	}
 
-	// manually visit the functions body as we've
-	// already pushed a scope.
-	for _, instr := range fn.Body.Instr {
-		b.visitInstr(instr)
-	}
+	// the body is walked without pushing a scope
+	// as we've already pushed one.
+	ir.Walk(fn.Body, b.enter, b.leave)
 
 	b.popStab()
 
@@ -212,9 +190,17 @@ func BuildScope(mod *ir.Module) (*ir.ScopeMap, []api.CompilerError) {
 	// the returned scope map that we are creating
 	// we traverse all of the relevant nodes
 	// and append to this scope map.
-	scopeMap := ir.NewScopeMap()
-
-	for _, fn := range mod.Functions {
+	scopeMap := ir.NewScopeMap(mod.IDSource())
+	scopeMap.Global = b.visitGlobal(mod.Global)
+
+	// visit in order so the stabs get the same ids each time.
+	seen := map[string]bool{}
+	for _, name := range mod.FunctionOrder {
+		if seen[name.Value] {
+			continue
+		}
+		seen[name.Value] = true
+		fn := mod.Functions[name.Value]
 		stab := b.visitFunc(fn)
 
 		ok := scopeMap.RegisterFunction(fn.Name.Value, stab)
@@ -0,0 +1,73 @@
+package middle
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/krug-lang/caasper/ir"
+	"github.com/stretchr/testify/assert"
+)
+
+const scopeSource = `fn f(n i32) i32 {
+	mut i = 0;
+	while i < n {
+		let step = 1;
+		i = i + step;
+	}
+	return i;
+}
+
+fn main() {
+	let a = f(3);
+	{
+		let b = a;
+	}
+}`
+
+// stabIDs returns the ids of the symbol table
+// and every table nested in it.
+func stabIDs(stab *ir.SymbolTable) []int {
+	res := []int{stab.ID}
+	for _, inner := range stab.Inner {
+		res = append(res, stabIDs(inner)...)
+	}
+	return res
+}
+
+func TestScopeIDsAreStable(t *testing.T) {
+	a, errs := BuildScope(buildModule(t, scopeSource))
+	assert.Empty(t, errs)
+	b, errs := BuildScope(buildModule(t, scopeSource))
+	assert.Empty(t, errs)
+
+	aJSON, err := json.Marshal(a)
+	assert.NoError(t, err)
+	bJSON, err := json.Marshal(b)
+	assert.NoError(t, err)
+	assert.Equal(t, string(aJSON), string(bJSON))
+}
+
+func TestScopeIDsAreUnique(t *testing.T) {
+	mod := buildModule(t, scopeSource)
+	scope, errs := BuildScope(mod)
+	assert.Empty(t, errs)
+
+	// the symbol tables take their ids from the same
+	// source as the blocks of the module.
+	seen := map[int]bool{}
+	ir.Inspect(mod, func(n ir.Node) bool {
+		if b, ok := n.(*ir.Block); ok {
+			seen[int(b.ID)] = true
+		}
+		return true
+	})
+
+	ids := stabIDs(scope.Global)
+	for _, name := range []string{"f", "main"} {
+		ids = append(ids, stabIDs(scope.Functions[name])...)
+	}
+	for _, id := range ids {
+		assert.False(t, seen[id], "id %d is used twice", id)
+		seen[id] = true
+	}
+}
@@ -0,0 +1,330 @@
+package middle
+
+import (
+	"github.com/krug-lang/caasper/api"
+	"github.com/krug-lang/caasper/ir"
+)
+
+/*
+	this pass evaluates the operators on constants at
+	compile time, and replaces the uses of immutable
+	locals that are given a constant with the constant:
+
+	let x = 2 + 3; // let x = 5;
+	let y = x * 2; // let y = (10 as i32);
+
+	a constant that has a type, e.g. from a local declared
+	with one, is kept as a cast to that type so that the
+	type of the expression doesn't change. it's an error
+	for a constant to overflow its type or be divided by zero.
+
+	the operators are evaluated by ir.FoldBinary and ir.FoldUnary.
+*/
+
+type constFolder struct {
+	mod  *ir.Module
+	errs []api.CompilerError
+
+	// scopes are the locals in scope, innermost last. a local
+	// is nil if it isn't constant, it still hides outer locals.
+	scopes []map[string]*ir.Constant
+
+	// span is where the instruction being folded was
+	// written, errors are reported here if the value
+	// has no span of its own.
+	span []int
+}
+
+func (f *constFolder) error(err api.CompilerError) {
+	f.errs = append(f.errs, err)
+}
+
+func (f *constFolder) push() {
+	f.scopes = append(f.scopes, map[string]*ir.Constant{})
+}
+
+func (f *constFolder) pop() {
+	f.scopes = f.scopes[:len(f.scopes)-1]
+}
+
+func (f *constFolder) declare(name string, c *ir.Constant) {
+	f.scopes[len(f.scopes)-1][name] = c
+}
+
+func (f *constFolder) lookup(name string) *ir.Constant {
+	for i := len(f.scopes) - 1; i >= 0; i-- {
+		if c, ok := f.scopes[i][name]; ok {
+			return c
+		}
+	}
+	return nil
+}
+
+func (f *constFolder) spanOf(v *ir.Value) []int {
+	if span := spanOf(v); span != nil {
+		return span
+	}
+	return f.span
+}
+
+// fits checks that the constant fits in its type.
+func (f *constFolder) fits(c *ir.Constant, at *ir.Value) bool {
+	if c.Fits() {
+		return true
+	}
+	f.error(api.NewConstantOverflow(c.String(), c.Type.String(), f.spanOf(at)...))
+	return false
+}
+
+func (f *constFolder) foldBinary(v *ir.Value) (*ir.Constant, bool) {
+	if f.checkDivisor(v) {
+		return nil, false
+	}
+
+	bin := v.BinaryExpression
+	lh, ok := ir.ConstantOf(bin.LHand)
+	if !ok {
+		return nil, false
+	}
+	rh, ok := ir.ConstantOf(bin.RHand)
+	if !ok {
+		return nil, false
+	}
+
+	res, ok := ir.FoldBinary(bin.Op, lh, rh)
+	if !ok {
+		return nil, false
+	}
+	return res, f.fits(res, v)
+}
+
+// checkDivisor reports integer division by a constant
+// zero, it returns whether the division was reported.
+func (f *constFolder) checkDivisor(v *ir.Value) bool {
+	bin := v.BinaryExpression
+	if bin.Op != "/" && bin.Op != "%" {
+		return false
+	}
+	rh, ok := ir.ConstantOf(bin.RHand)
+	if !ok || rh.Kind != ir.IntegerValueValue || rh.Int.Sign() != 0 {
+		return false
+	}
+
+	// floats divided by zero are left to the runtime.
+	if lh, ok := ir.ConstantOf(bin.LHand); ok && lh.Kind == ir.FloatingValueValue {
+		return false
+	}
+	f.error(api.NewDivisionByZero(f.spanOf(v)...))
+	return true
+}
+
+func (f *constFolder) foldUnary(v *ir.Value) (*ir.Constant, bool) {
+	u := v.UnaryExpression
+	c, ok := ir.ConstantOf(u.Val)
+	if !ok {
+		return nil, false
+	}
+
+	res, ok := ir.FoldUnary(u.Op, c)
+	if !ok {
+		return nil, false
+	}
+	return res, f.fits(res, v)
+}
+
+// propagates returns whether the constant value of a local
+// can be used in place of the identifier. identifiers that
+// are written to or have their address taken are kept.
+func propagates(ident *ir.Value, parent ir.Node) bool {
+	p, ok := parent.(*ir.Value)
+	if !ok {
+		return true
+	}
+
+	switch p.Kind {
+	case ir.PathValue:
+		return false
+	case ir.UnaryExpressionValue:
+		return p.UnaryExpression.Op != "&"
+	case ir.AssignValue:
+		return p.Assign.LHand != ident
+	case ir.CallValue:
+		return p.Call.Left != ident
+	}
+	return true
+}
+
+func (f *constFolder) fold(c *ir.Cursor, v *ir.Value) {
+	var res *ir.Constant
+	var ok bool
+
+	switch v.Kind {
+	case ir.BinaryExpressionValue:
+		res, ok = f.foldBinary(v)
+	case ir.UnaryExpressionValue:
+		// negative literals are already folded.
+		if _, lit := ir.ConstantOf(v); lit {
+			return
+		}
+		res, ok = f.foldUnary(v)
+	case ir.GroupingValue:
+		res, ok = ir.ConstantOf(v.Grouping.Val)
+	case ir.IdentifierValue:
+		res = f.lookup(v.Identifier.Name.Value)
+		ok = res != nil && propagates(v, c.Parent())
+	}
+
+	if ok {
+		c.Replace(res.Value())
+	}
+}
+
+// foldType folds the size of any array in the given type.
+func (f *constFolder) foldType(t *ir.Type) {
+	if t == nil {
+		return
+	}
+
+	switch t.Kind {
+	case ir.PointerKind:
+		f.foldType(t.Pointer.Base)
+	case ir.SliceKind:
+		f.foldType(t.Slice.Base)
+	case ir.TupleKind:
+		for _, typ := range t.Tuple.Types {
+			f.foldType(typ)
+		}
+	case ir.ArrayKind:
+		arr := t.ArrayType
+		f.foldType(arr.Base)
+		if arr.Size == nil {
+			return
+		}
+
+		arr.Size = ir.Walk(arr.Size, f.enter, f.leave).(*ir.Value)
+		// the size of an array is written
+		// as a plain integer literal.
+		if c, ok := ir.ConstantOf(arr.Size); ok && c.Kind == ir.IntegerValueValue {
+			arr.Size = (&ir.Constant{Kind: c.Kind, Int: c.Int}).Value()
+		}
+	}
+}
+
+// declareLocal declares the given local, it's a constant if
+// it can't be changed and its value is a constant that fits
+// in its type.
+func (f *constFolder) declareLocal(l *ir.Local) {
+	f.foldType(l.Type)
+
+	var res *ir.Constant
+	if l.Val != nil {
+		res, _ = ir.ConstantOf(l.Val)
+	}
+	if res == nil {
+		f.declare(l.Name.Value, nil)
+		return
+	}
+
+	// the constant takes on the type of the local.
+	typ := l.Type
+	if typ != nil {
+		res = &ir.Constant{Kind: res.Kind, Int: res.Int, Float: res.Float, Bool: res.Bool}
+	} else if res.Type == nil {
+		typ = ir.ConstantType(l.Val)
+	}
+
+	if res.Type == nil {
+		lit := res
+		var ok bool
+		if res, ok = lit.Typed(typ); !ok {
+			if lit.Kind == ir.IntegerValueValue && typ.Kind == ir.IntegerKind {
+				f.error(api.NewConstantOverflow(lit.String(), typ.String(), l.Name.Span...))
+			}
+			f.declare(l.Name.Value, nil)
+			return
+		}
+	}
+
+	if l.Mutable {
+		res = nil
+	}
+	f.declare(l.Name.Value, res)
+}
+
+func (f *constFolder) declareParams(fn *ir.Function) {
+	for _, name := range fn.Param.Order {
+		f.foldType(fn.Param.Get(name.Value).Type)
+		f.declare(name.Value, nil)
+	}
+	f.foldType(fn.ReturnType)
+}
+
+func (f *constFolder) enter(c *ir.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *ir.Block:
+		// the globals are in the outermost scope.
+		if n != f.mod.Global {
+			f.push()
+		}
+
+	case *ir.Structure:
+		for _, name := range n.Fields.Order {
+			f.foldType(n.Fields.Get(name.Value).Type)
+		}
+
+	case *ir.Function:
+		f.push()
+		f.declareParams(n)
+
+	case *ir.Instruction:
+		if span := instrSpan(n); span != nil {
+			f.span = span
+		}
+		if n.Kind == ir.AllocaInstr {
+			f.foldType(n.Alloca.Type)
+		}
+	}
+	return true
+}
+
+func (f *constFolder) leave(c *ir.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *ir.Block:
+		if n != f.mod.Global {
+			f.pop()
+		}
+
+	case *ir.Function:
+		f.pop()
+
+	case *ir.Instruction:
+		switch n.Kind {
+		case ir.LocalInstr:
+			f.declareLocal(n.Local)
+		case ir.AllocaInstr:
+			f.declare(n.Alloca.Name.Value, nil)
+		case ir.TypeAliasInstr:
+			f.foldType(n.TypeAliasStatement.Type)
+		}
+
+	case *ir.Value:
+		if n.Kind == ir.CastValue {
+			f.foldType(n.Cast.Type)
+		}
+		f.fold(c, n)
+	}
+	return true
+}
+
+// FoldConstants evaluates the constant expressions in the given
+// module and replaces the uses of constant locals with their value.
+func FoldConstants(mod *ir.Module) []api.CompilerError {
+	f := &constFolder{
+		mod:  mod,
+		errs: []api.CompilerError{},
+	}
+
+	f.push()
+	ir.Walk(mod, f.enter, f.leave)
+	return f.errs
+}
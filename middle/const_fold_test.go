@@ -0,0 +1,116 @@
+package middle
+
+import (
+	"testing"
+
+	"github.com/krug-lang/caasper/api"
+	"github.com/krug-lang/caasper/ir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFoldIntegerPlusFloatLiteral(t *testing.T) {
+	mod := buildModule(t, `fn main() {
+		let a i32 = 1;
+		let b = a + 1.5;
+	}`)
+	assert.Empty(t, FoldConstants(mod))
+
+	b := localOf(mod.Functions["main"], "b")
+	c, ok := ir.ConstantOf(b.Val)
+	assert.True(t, ok)
+	assert.EqualValues(t, ir.FloatingValueValue, c.Kind)
+	assert.Equal(t, 2.5, c.Float)
+	assert.Nil(t, c.Type)
+}
+
+func TestFoldFloatPlusIntegerLiteral(t *testing.T) {
+	mod := buildModule(t, `fn main() {
+		let a f32 = 1.5;
+		let b = a + 2;
+	}`)
+	assert.Empty(t, FoldConstants(mod))
+
+	b := localOf(mod.Functions["main"], "b")
+	c, ok := ir.ConstantOf(b.Val)
+	assert.True(t, ok)
+	assert.EqualValues(t, ir.FloatingValueValue, c.Kind)
+	assert.Equal(t, 3.5, c.Float)
+	assert.True(t, ir.TypesEqual(ir.Float32, c.Type))
+}
+
+func TestFoldArraySize(t *testing.T) {
+	mod := buildModule(t, `fn main() {
+		let arr [i32; 4 * 1024];
+	}`)
+	assert.Empty(t, FoldConstants(mod))
+
+	arr := localOf(mod.Functions["main"], "arr")
+	size, ok := ir.IntegerLiteral(arr.Type.ArrayType.Size)
+	assert.True(t, ok)
+	assert.Equal(t, int64(4096), size.RawValue.Int64())
+}
+
+func TestPropagateImmutableLocal(t *testing.T) {
+	mod := buildModule(t, `fn main() {
+		let x = 2 + 3;
+		let y = x * 2;
+	}`)
+	assert.Empty(t, FoldConstants(mod))
+
+	y := localOf(mod.Functions["main"], "y")
+	c, ok := ir.ConstantOf(y.Val)
+	assert.True(t, ok)
+	assert.Equal(t, int64(10), c.Int.Int64())
+}
+
+func TestPropagateKeepsLocals(t *testing.T) {
+	mod := buildModule(t, `fn main() {
+		mut x = 2;
+		let y = x;
+		let z = 3;
+		let p = &z;
+	}`)
+	assert.Empty(t, FoldConstants(mod))
+
+	// a mutable local can change, and a local that has
+	// its address taken has to stay a local.
+	fn := mod.Functions["main"]
+	y := localOf(fn, "y")
+	assert.EqualValues(t, ir.IdentifierValue, y.Val.Kind)
+
+	p := localOf(fn, "p")
+	assert.EqualValues(t, ir.UnaryExpressionValue, p.Val.Kind)
+	assert.EqualValues(t, ir.IdentifierValue, p.Val.UnaryExpression.Val.Kind)
+}
+
+func TestFoldConstantOverflow(t *testing.T) {
+	mod := buildModule(t, `fn main() {
+		let a u8 = 300;
+		let b u8 = 200;
+		let c = b + 100;
+	}`)
+
+	errs := FoldConstants(mod)
+	assert.Len(t, errs, 2)
+	for _, err := range errs {
+		assert.Equal(t, api.NewConstantOverflow("", "").ErrorCode, err.ErrorCode)
+	}
+	assert.Contains(t, errs[0].Title, "300")
+	assert.Contains(t, errs[1].Title, "300")
+}
+
+func TestFoldDivisionByZero(t *testing.T) {
+	mod := buildModule(t, `fn main() {
+		let a = 1 / 0;
+		let b = 4 % 0;
+		let c = 1.0 / 0;
+	}`)
+
+	// floats divided by zero are left to the runtime.
+	errs := FoldConstants(mod)
+	assert.Len(t, errs, 2)
+	for _, err := range errs {
+		assert.Equal(t, api.NewDivisionByZero().ErrorCode, err.ErrorCode)
+		assert.NotEmpty(t, err.CodeContext)
+	}
+}
@@ -0,0 +1,465 @@
+package middle
+
+import (
+	"github.com/krug-lang/caasper/api"
+	"github.com/krug-lang/caasper/front"
+	"github.com/krug-lang/caasper/ir"
+)
+
+/*
+	this pass checks that values are only implicitly
+	converted between types when the conversion widens
+	the value, see ir.Widens. anything else must be done
+	with an explicit 'as' cast, which is also checked here.
+
+	the values given to locals, assignments, calls and
+	returns are checked by the type check, see TypeCheck.
+*/
+
+type convChecker struct {
+	mod  *ir.Module
+	errs []api.CompilerError
+	env  *ir.Env
+}
+
+func (c *convChecker) error(err api.CompilerError) {
+	c.errs = append(c.errs, err)
+}
+
+// spanOf returns the span of the first identifier in
+// the given value, values don't store their own position.
+func spanOf(v *ir.Value) []int {
+	if v == nil {
+		return nil
+	}
+
+	switch v.Kind {
+	case ir.IdentifierValue:
+		return v.Identifier.Name.Span
+	case ir.GroupingValue:
+		return spanOf(v.Grouping.Val)
+	case ir.UnaryExpressionValue:
+		return spanOf(v.UnaryExpression.Val)
+	case ir.BinaryExpressionValue:
+		if span := spanOf(v.BinaryExpression.LHand); span != nil {
+			return span
+		}
+		return spanOf(v.BinaryExpression.RHand)
+	case ir.CastValue:
+		return spanOf(v.Cast.Val)
+	case ir.CallValue:
+		return spanOf(v.Call.Left)
+	case ir.IndexValue:
+		return v.Index.Span
+	case ir.SliceValue:
+		return v.Slice.Span
+	case ir.PathValue:
+		return spanOf(v.Path.Values[0])
+	}
+	return nil
+}
+
+// instrSpan returns where the given instruction was written.
+func instrSpan(i *ir.Instruction) []int {
+	switch i.Kind {
+	case ir.LocalInstr:
+		return i.Local.Name.Span
+	case ir.AllocaInstr:
+		return i.Alloca.Name.Span
+	case ir.ReturnInstr:
+		return i.Return.Span
+	case ir.AssignInstr:
+		return spanOf(i.Assign.LHand)
+	case ir.ExpressionInstr:
+		return spanOf(i.ExpressionStatement)
+	case ir.IfStatementInstr:
+		return spanOf(i.IfStatement.Cond)
+	case ir.WhileLoopInstr:
+		return spanOf(i.WhileLoop.Cond)
+	case ir.LabelInstr:
+		return i.Label.Name.Span
+	case ir.JumpInstr:
+		return i.Jump.Location.Span
+	case ir.BreakInstr:
+		if i.Break.Label != nil {
+			return i.Break.Label.Span
+		}
+	case ir.NextInstr:
+		if i.Next.Label != nil {
+			return i.Next.Label.Span
+		}
+	}
+	return nil
+}
+
+func (c *convChecker) expect(v *ir.Value, to *ir.Type) {
+	if to == nil || to.Kind == ir.VoidKind {
+		return
+	}
+	if !c.env.Assignable(v, to) {
+		from := c.env.TypeOf(v)
+		c.error(api.NewConversionError(from.String(), to.String(), spanOf(v)...))
+	}
+}
+
+func (c *convChecker) checkBinary(bin *ir.BinaryExpression) {
+	c.checkValue(bin.LHand)
+	c.checkValue(bin.RHand)
+
+	if bin.Op == "&&" || bin.Op == "||" {
+		c.expect(bin.LHand, ir.Bool)
+		c.expect(bin.RHand, ir.Bool)
+		return
+	}
+
+	lh, rh := c.env.TypeOf(bin.LHand), c.env.TypeOf(bin.RHand)
+	if isString(lh) || isString(rh) {
+		c.checkStringOp(bin.Op, bin.LHand, lh, rh)
+		return
+	}
+	if isBool(lh) || isBool(rh) {
+		c.checkBoolOp(bin.Op, bin.LHand, lh, rh)
+		return
+	}
+	if !ir.IsNumeric(lh) || !ir.IsNumeric(rh) {
+		return
+	}
+
+	if c.env.Assignable(bin.LHand, rh) || c.env.Assignable(bin.RHand, lh) {
+		return
+	}
+	c.error(api.NewConversionError(lh.String(), rh.String(), spanOf(bin.LHand)...))
+}
+
+func isString(t *ir.Type) bool {
+	return t != nil && t.Kind == ir.StringKind
+}
+
+// checkStringOp checks an operation on a string, strings can
+// only be concatenated with + and compared with other strings.
+func (c *convChecker) checkStringOp(op string, lhand *ir.Value, lh, rh *ir.Type) {
+	if lh == nil || rh == nil {
+		return
+	}
+	if !isString(lh) || !isString(rh) {
+		c.error(api.NewConversionError(rh.String(), lh.String(), spanOf(lhand)...))
+		return
+	}
+
+	switch op {
+	case "+", "+=", "=", "==", "!=", "<", ">", "<=", ">=":
+		return
+	}
+	c.error(api.NewInvalidOperator(op, lh.String(), spanOf(lhand)...))
+}
+
+func isBool(t *ir.Type) bool {
+	return t != nil && t.Kind == ir.BoolKind
+}
+
+// checkBoolOp checks an operation on a bool, bools can only
+// be compared with other bools. && and || are checked separately.
+func (c *convChecker) checkBoolOp(op string, lhand *ir.Value, lh, rh *ir.Type) {
+	if lh == nil || rh == nil {
+		return
+	}
+	if !isBool(lh) || !isBool(rh) {
+		c.error(api.NewConversionError(rh.String(), lh.String(), spanOf(lhand)...))
+		return
+	}
+
+	switch op {
+	case "=", "==", "!=":
+		return
+	}
+	c.error(api.NewInvalidOperator(op, lh.String(), spanOf(lhand)...))
+}
+
+func (c *convChecker) checkCast(cast *ir.Cast) {
+	c.checkValue(cast.Val)
+
+	from := c.env.TypeOf(cast.Val)
+	if from == nil || cast.Type == nil {
+		return
+	}
+	if !ir.CanCast(from, cast.Type) {
+		c.error(api.NewInvalidCastError(from.String(), cast.Type.String(), spanOf(cast.Val)...))
+	}
+}
+
+func (c *convChecker) checkCall(call *ir.Call) {
+	c.checkValue(call.Left)
+	for _, p := range call.Params {
+		c.checkValue(p)
+	}
+}
+
+// checkInit checks the values of a structure initializer
+// against the fields of the structure, every field must be
+// given exactly once, either by name or by position.
+func (c *convChecker) checkInit(init *ir.Init) {
+	// defaults are checked with their structure.
+	given := len(init.Values) - init.Defaults
+	for _, val := range init.Values[:given] {
+		c.checkValue(val)
+	}
+
+	if init.Kind != front.InitStructure {
+		return
+	}
+
+	name := init.LHand.Name
+	st, ok := c.mod.Structures[name.Value]
+	if !ok {
+		c.error(api.NewUnresolvedSymbol(name.Value, name.Span...))
+		return
+	}
+
+	fields := st.Fields
+	if init.Names == nil {
+		if len(init.Values) > len(fields.Order) {
+			c.error(api.NewFieldCountError(name.Value, len(fields.Order), len(init.Values), name.Span...))
+			return
+		}
+
+		for i, val := range init.Values[:given] {
+			c.expect(val, fields.Get(fields.Order[i].Value).Type)
+		}
+		for _, field := range fields.Order[len(init.Values):] {
+			if fields.Get(field.Value).Val == nil {
+				c.error(api.NewMissingField(field.Value, name.Value, name.Span...))
+			}
+		}
+		return
+	}
+
+	named := map[string]bool{}
+	for i, field := range init.Names {
+		if named[field.Value] {
+			c.error(api.NewDuplicateField(field.Value, name.Value, field.Span...))
+			continue
+		}
+		named[field.Value] = true
+
+		loc := fields.Get(field.Value)
+		if loc == nil {
+			c.error(api.NewUnknownField(field.Value, name.Value, field.Span...))
+			continue
+		}
+		if i < given {
+			c.expect(init.Values[i], loc.Type)
+		}
+	}
+
+	for _, field := range fields.Order {
+		if !named[field.Value] {
+			c.error(api.NewMissingField(field.Value, name.Value, name.Span...))
+		}
+	}
+}
+
+// checkIndex checks that left can be indexed and that the
+// subscripts of an index or slice expression are integers.
+func (c *convChecker) checkIndex(left *ir.Value, subs ...*ir.Value) {
+	c.checkValue(left)
+	if typ := c.env.TypeOf(left); typ != nil && ir.ElementType(typ) == nil {
+		c.error(api.NewNotIndexable(typ.String(), spanOf(left)...))
+	}
+
+	for _, sub := range subs {
+		if sub == nil {
+			continue
+		}
+		c.checkValue(sub)
+
+		if typ := c.env.TypeOf(sub); typ != nil && typ.Kind != ir.IntegerKind {
+			c.error(api.NewConversionError(typ.String(), ir.Uint64.String(), spanOf(sub)...))
+		}
+	}
+}
+
+// checkSlice checks the bounds of a slice expression, a
+// pointer has no length so the upper bound must be given.
+func (c *convChecker) checkSlice(s *ir.Slice) {
+	c.checkIndex(s.Left, s.Low, s.High)
+
+	typ := c.env.TypeOf(s.Left)
+	if typ != nil && typ.Kind == ir.PointerKind && s.High == nil {
+		c.error(api.NewUnboundedSlice(typ.String(), s.Span...))
+	}
+}
+
+func (c *convChecker) checkBuiltin(b *ir.Builtin) {
+	for _, arg := range b.Args {
+		c.checkValue(arg)
+	}
+
+	if b.Name != "len" {
+		return
+	}
+	typ := c.env.Lookup(b.Iden.Name.Value)
+	if typ != nil && typ.Kind != ir.ArrayKind && typ.Kind != ir.SliceKind && typ.Kind != ir.StringKind {
+		c.error(api.NewNoLength(typ.String(), b.Iden.Name.Span...))
+	}
+}
+
+func (c *convChecker) checkValue(v *ir.Value) {
+	switch v.Kind {
+	case ir.GroupingValue:
+		c.checkValue(v.Grouping.Val)
+	case ir.UnaryExpressionValue:
+		c.checkValue(v.UnaryExpression.Val)
+		if v.UnaryExpression.Op == "!" {
+			c.expect(v.UnaryExpression.Val, ir.Bool)
+		}
+	case ir.BinaryExpressionValue:
+		c.checkBinary(v.BinaryExpression)
+	case ir.CastValue:
+		c.checkCast(v.Cast)
+	case ir.CallValue:
+		c.checkCall(v.Call)
+	case ir.IndexValue:
+		c.checkIndex(v.Index.Left, v.Index.Sub)
+	case ir.SliceValue:
+		c.checkSlice(v.Slice)
+	case ir.BuiltinValue:
+		c.checkBuiltin(v.Builtin)
+	case ir.AssignValue:
+		c.checkAssign(v.Assign)
+	case ir.InitValue:
+		c.checkInit(v.Init)
+	}
+}
+
+func (c *convChecker) checkAssign(a *ir.Assign) {
+	c.checkValue(a.LHand)
+	c.checkValue(a.RHand)
+
+	// plain assignments are checked by the type check.
+	if a.Op == "=" {
+		return
+	}
+
+	lh := c.env.TypeOf(a.LHand)
+	if isString(lh) {
+		c.checkStringOp(a.Op, a.LHand, lh, c.env.TypeOf(a.RHand))
+		return
+	}
+	if isBool(lh) {
+		c.error(api.NewInvalidOperator(a.Op, lh.String(), spanOf(a.LHand)...))
+		return
+	}
+	c.expect(a.RHand, lh)
+}
+
+// checkCond checks the condition of an if or while, which must
+// be a bool. integers and pointers are not implicitly truthy.
+func (c *convChecker) checkCond(cond *ir.Value) {
+	c.checkValue(cond)
+	c.expect(cond, ir.Bool)
+}
+
+func (c *convChecker) checkLocal(l *ir.Local) {
+	if l.Val != nil {
+		c.checkValue(l.Val)
+	}
+	c.env.DeclareLocal(l)
+}
+
+func (c *convChecker) checkInstr(instr *ir.Instruction) {
+	switch instr.Kind {
+	case ir.LocalInstr:
+		c.checkLocal(instr.Local)
+
+	case ir.AssignInstr:
+		c.checkAssign(instr.Assign)
+
+	case ir.ExpressionInstr:
+		c.checkValue(instr.ExpressionStatement)
+
+	case ir.ReturnInstr:
+		if val := instr.Return.Val; val != nil {
+			c.checkValue(val)
+		}
+	}
+}
+
+// checkControl checks a value that controls an if or a
+// while, these are checked in order with their blocks.
+func (c *convChecker) checkControl(instr *ir.Instruction, v *ir.Value) {
+	switch instr.Kind {
+	case ir.IfStatementInstr:
+		c.checkCond(v)
+	case ir.ElseIfStatementInstr:
+		c.checkCond(v)
+	case ir.WhileLoopInstr:
+		if v == instr.WhileLoop.Post {
+			c.checkValue(v)
+			return
+		}
+		c.checkCond(v)
+	}
+}
+
+func (c *convChecker) enter(cur *ir.Cursor) bool {
+	switch n := cur.Node().(type) {
+	case *ir.Block:
+		c.env.Push()
+
+	case *ir.Instruction:
+		c.checkInstr(n)
+
+	// values are checked as a whole by the
+	// instruction that they're in.
+	case *ir.Value:
+		if instr, ok := cur.Parent().(*ir.Instruction); ok {
+			c.checkControl(instr, n)
+		}
+		return false
+	}
+	return true
+}
+
+func (c *convChecker) leave(cur *ir.Cursor) bool {
+	if _, ok := cur.Node().(*ir.Block); ok {
+		c.env.Pop()
+	}
+	return true
+}
+
+func (c *convChecker) checkFunc(fn *ir.Function) {
+	c.env.Push()
+	c.env.DeclareParams(fn)
+	ir.Walk(fn.Body, c.enter, c.leave)
+	c.env.Pop()
+}
+
+// ConversionCheck checks the implicit conversions of the
+// operators and conditions, and the explicit casts in the
+// given module.
+func ConversionCheck(mod *ir.Module) []api.CompilerError {
+	c := &convChecker{
+		mod:  mod,
+		errs: []api.CompilerError{},
+		env:  ir.NewEnv(mod),
+	}
+
+	for _, instr := range mod.Global.Instr {
+		if instr.Kind == ir.LocalInstr && instr.Local.Val != nil {
+			c.checkValue(instr.Local.Val)
+		}
+	}
+
+	for _, name := range mod.FunctionOrder {
+		c.checkFunc(mod.Functions[name.Value])
+	}
+
+	for _, name := range mod.ImplsOrder {
+		impl := mod.Impls[name.Value]
+		for _, name := range impl.Order {
+			c.checkFunc(impl.Methods[name.Value])
+		}
+	}
+
+	return c.errs
+}
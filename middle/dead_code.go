@@ -0,0 +1,320 @@
+package middle
+
+import (
+	"github.com/krug-lang/caasper/api"
+	"github.com/krug-lang/caasper/front"
+	"github.com/krug-lang/caasper/ir"
+)
+
+/*
+	this pass removes the code that can never run, with a
+	warning for each piece that is removed:
+
+	- instructions after a return, break, next or jump, or
+	  after a loop that is never broken out of.
+	- branches of an if, and while loops, with a condition
+	  that is always false, and the branches after one with
+	  a condition that is always true.
+
+	a label can be jumped to, so the code after a label is
+	always kept, as is any branch that contains a label.
+*/
+
+// deadBlock is a block that is being walked, dead is set
+// once the end of the block can't be reached. each dead
+// run of instructions is only warned about once.
+type deadBlock struct {
+	block  *ir.Block
+	dead   bool
+	warned bool
+}
+
+// deadLoop is a loop being walked and whether
+// it is ever broken out of.
+type deadLoop struct {
+	instr  *ir.Instruction
+	label  *front.Token
+	broken bool
+}
+
+type deadCodeEliminator struct {
+	errs   []api.CompilerError
+	blocks []*deadBlock
+	loops  []*deadLoop
+
+	// exits is whether the end of each
+	// block that has been walked is reached.
+	exits map[*ir.Block]bool
+
+	// defers are the defers that can't be reached.
+	defers map[*ir.Defer]bool
+}
+
+func (d *deadCodeEliminator) warn(span []int) {
+	d.errs = append(d.errs, api.NewUnreachableCode(span...))
+}
+
+func (d *deadCodeEliminator) top() *deadBlock {
+	return d.blocks[len(d.blocks)-1]
+}
+
+// firstSpan returns the span of the first thing
+// written in the given node that has one.
+func firstSpan(n ir.Node) []int {
+	var res []int
+	ir.Inspect(n, func(n ir.Node) bool {
+		if res != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ir.Instruction:
+			res = instrSpan(n)
+		case *ir.Value:
+			res = spanOf(n)
+		}
+		return res == nil
+	})
+	return res
+}
+
+func hasLabel(n ir.Node) bool {
+	found := false
+	ir.Inspect(n, func(n ir.Node) bool {
+		if i, ok := n.(*ir.Instruction); ok && i.Kind == ir.LabelInstr {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// constBool returns the value of the condition
+// if it's always true or always false.
+func constBool(cond *ir.Value) (val bool, ok bool) {
+	c, ok := ir.ConstantOf(cond)
+	if !ok || c.Kind != ir.BooleanValueValue {
+		return false, false
+	}
+	return c.Bool, true
+}
+
+// simplifyIf removes the branches of the if statement that can't
+// be taken. it returns false if the whole statement was removed.
+func (d *deadCodeEliminator) simplifyIf(c *ir.Cursor, iff *ir.IfStatement) bool {
+	conds := []*ir.Value{iff.Cond}
+	bodies := []*ir.Block{iff.True}
+	for _, elif := range iff.ElseIf {
+		conds = append(conds, elif.Cond)
+		bodies = append(bodies, elif.Body)
+	}
+
+	var keptConds []*ir.Value
+	var keptBodies []*ir.Block
+	var dropped []*ir.Block
+	els := iff.Else
+
+branches:
+	for i, cond := range conds {
+		val, ok := constBool(cond)
+		switch {
+		case !ok:
+			keptConds = append(keptConds, cond)
+			keptBodies = append(keptBodies, bodies[i])
+			continue
+		case !val:
+			dropped = append(dropped, bodies[i])
+			continue
+		}
+
+		// this branch is always taken, so it's
+		// the else of the branches before it.
+		dropped = append(dropped, bodies[i+1:]...)
+		if els != nil {
+			dropped = append(dropped, els)
+		}
+		els = bodies[i]
+		break branches
+	}
+
+	if len(dropped) == 0 && len(keptConds) == len(conds) {
+		return true
+	}
+	for _, b := range dropped {
+		if hasLabel(b) {
+			return true
+		}
+	}
+	for _, b := range dropped {
+		if len(b.Instr) > 0 {
+			d.warn(firstSpan(b))
+		}
+	}
+
+	switch {
+	case len(keptConds) > 0:
+		iff.Cond, iff.True = keptConds[0], keptBodies[0]
+		iff.ElseIf = iff.ElseIf[:0]
+		for i, cond := range keptConds[1:] {
+			iff.ElseIf = append(iff.ElseIf, ir.NewElseIfStatement(cond, keptBodies[i+1]))
+		}
+		iff.Else = els
+	case els != nil:
+		c.Replace(&ir.Instruction{Kind: ir.BlockInstr, Block: els})
+	default:
+		c.Delete()
+		return false
+	}
+	return true
+}
+
+// breakLoop marks the loop that the break leaves as broken.
+func (d *deadCodeEliminator) breakLoop(b *ir.Break) {
+	for i := len(d.loops) - 1; i >= 0; i-- {
+		loop := d.loops[i]
+		if b.Label == nil || (loop.label != nil && loop.label.Value == b.Label.Value) {
+			loop.broken = true
+			return
+		}
+	}
+}
+
+// exits returns whether the code after the
+// given instruction can be reached from it.
+func (d *deadCodeEliminator) exitsInstr(i *ir.Instruction, loop *deadLoop) bool {
+	switch i.Kind {
+	case ir.ReturnInstr, ir.BreakInstr, ir.NextInstr, ir.JumpInstr:
+		return false
+	case ir.LoopInstr:
+		return loop.broken
+	case ir.WhileLoopInstr:
+		val, ok := constBool(i.WhileLoop.Cond)
+		return !ok || !val || loop.broken
+	case ir.BlockInstr:
+		return d.exits[i.Block]
+	case ir.IfStatementInstr:
+		iff := i.IfStatement
+		if iff.Else == nil || d.exits[iff.True] || d.exits[iff.Else] {
+			return true
+		}
+		for _, elif := range iff.ElseIf {
+			if d.exits[elif.Body] {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (d *deadCodeEliminator) enterInstr(c *ir.Cursor, i *ir.Instruction) bool {
+	if c.Index() >= 0 {
+		// a label can be jumped to, even if it's
+		// nested in an instruction, e.g. an if.
+		b := d.top()
+		if hasLabel(i) {
+			b.dead, b.warned = false, false
+		}
+		if b.dead {
+			if !b.warned {
+				d.warn(firstSpan(i))
+				b.warned = true
+			}
+			c.Delete()
+			return false
+		}
+	}
+
+	switch i.Kind {
+	case ir.IfStatementInstr:
+		return d.simplifyIf(c, i.IfStatement)
+
+	case ir.WhileLoopInstr:
+		w := i.WhileLoop
+		if val, ok := constBool(w.Cond); ok && !val && !hasLabel(w.Body) {
+			if len(w.Body.Instr) > 0 {
+				d.warn(firstSpan(w.Body))
+			}
+			c.Delete()
+			return false
+		}
+		d.loops = append(d.loops, &deadLoop{instr: i, label: w.Label})
+
+	case ir.LoopInstr:
+		d.loops = append(d.loops, &deadLoop{instr: i, label: i.Loop.Label})
+
+	case ir.BreakInstr:
+		d.breakLoop(i.Break)
+	}
+	return true
+}
+
+func (d *deadCodeEliminator) leaveInstr(c *ir.Cursor, i *ir.Instruction) {
+	var loop *deadLoop
+	if i.Kind == ir.LoopInstr || i.Kind == ir.WhileLoopInstr {
+		loop = d.loops[len(d.loops)-1]
+		d.loops = d.loops[:len(d.loops)-1]
+	}
+
+	if c.Index() >= 0 && !d.exitsInstr(i, loop) {
+		d.top().dead = true
+	}
+}
+
+func (d *deadCodeEliminator) enter(c *ir.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *ir.Block:
+		d.blocks = append(d.blocks, &deadBlock{block: n})
+
+	case *ir.Defer:
+		// a defer pushed after the block is
+		// left is never run.
+		if b := d.top(); b.dead {
+			if !b.warned {
+				d.warn(firstSpan(n))
+				b.warned = true
+			}
+			d.defers[n] = true
+			return false
+		}
+
+	case *ir.Instruction:
+		return d.enterInstr(c, n)
+
+	case *ir.Value:
+		return false
+	}
+	return true
+}
+
+func (d *deadCodeEliminator) leave(c *ir.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *ir.Block:
+		d.exits[n] = !d.top().dead
+		d.blocks = d.blocks[:len(d.blocks)-1]
+
+		defers := n.DeferStack[:0]
+		for _, def := range n.DeferStack {
+			if !d.defers[def] {
+				defers = append(defers, def)
+			}
+		}
+		n.DeferStack = defers
+
+	case *ir.Instruction:
+		d.leaveInstr(c, n)
+	}
+	return true
+}
+
+// EliminateDeadCode removes the code that can never run from
+// the given module, a warning is returned for each piece removed.
+func EliminateDeadCode(mod *ir.Module) []api.CompilerError {
+	d := &deadCodeEliminator{
+		errs:   []api.CompilerError{},
+		exits:  map[*ir.Block]bool{},
+		defers: map[*ir.Defer]bool{},
+	}
+
+	ir.Walk(mod, d.enter, d.leave)
+	return d.errs
+}
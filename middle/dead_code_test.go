@@ -0,0 +1,161 @@
+package middle
+
+import (
+	"testing"
+
+	"github.com/krug-lang/caasper/api"
+	"github.com/krug-lang/caasper/ir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadCodeAfterReturn(t *testing.T) {
+	mod := buildModule(t, `fn main() int {
+		return 1;
+		let dead = 2;
+		let also = 3;
+	}`)
+	errs := EliminateDeadCode(mod)
+
+	// the run of dead code is only warned about once.
+	assert.Len(t, errs, 1)
+	assert.Len(t, mod.Functions["main"].Body.Instr, 1)
+	assert.Nil(t, localOf(mod.Functions["main"], "dead"))
+	assert.Empty(t, ir.Verify(mod))
+}
+
+func TestDeadCodeKeepsNestedLabel(t *testing.T) {
+	mod := buildModule(t, `fn main() int {
+		mut k = 0;
+		jump later;
+		if k == 0 {
+			$later;
+			k = 5;
+		}
+		return k;
+	}`)
+	assert.Empty(t, JumpCheck(mod))
+	assert.Empty(t, EliminateDeadCode(mod))
+
+	body := mod.Functions["main"].Body
+	assert.Len(t, body.Instr, 4)
+	assert.EqualValues(t, ir.IfStatementInstr, body.Instr[2].Kind)
+}
+
+func TestDeadCodeAfterBreakAndNext(t *testing.T) {
+	mod := buildModule(t, `fn main() {
+		mut i = 0;
+		while i < 10 {
+			i = i + 1;
+			if i == 5 {
+				break;
+				i = 0;
+			}
+			next;
+			i = 2;
+		}
+	}`)
+	errs := EliminateDeadCode(mod)
+	assert.Len(t, errs, 2)
+
+	loop := mod.Functions["main"].Body.Instr[1].WhileLoop
+	assert.Len(t, loop.Body.Instr, 3)
+	assert.EqualValues(t, ir.NextInstr, loop.Body.Instr[2].Kind)
+
+	iff := loop.Body.Instr[1].IfStatement
+	assert.Len(t, iff.True.Instr, 1)
+	assert.EqualValues(t, ir.BreakInstr, iff.True.Instr[0].Kind)
+	assert.Empty(t, ir.Verify(mod))
+}
+
+func TestDeadCodeAfterInfiniteLoop(t *testing.T) {
+	mod := buildModule(t, `fn main() {
+		mut i = 0;
+		loop {
+			i = i + 1;
+		}
+		let dead = 1;
+	}`)
+	assert.Len(t, EliminateDeadCode(mod), 1)
+	assert.Len(t, mod.Functions["main"].Body.Instr, 2)
+	assert.Nil(t, localOf(mod.Functions["main"], "dead"))
+}
+
+func TestDeadCodeAfterLabelledBreak(t *testing.T) {
+	mod := buildModule(t, `fn main() {
+		mut i = 0;
+		outer: loop {
+			loop {
+				break outer;
+			}
+			let dead = 1;
+		}
+		let alive = 2;
+	}`)
+
+	// the inner loop is never left, but the outer
+	// loop is broken out of from inside of it.
+	assert.Len(t, EliminateDeadCode(mod), 1)
+
+	fn := mod.Functions["main"]
+	assert.Len(t, fn.Body.Instr, 3)
+	assert.Nil(t, localOf(fn, "dead"))
+	assert.NotNil(t, localOf(fn, "alive"))
+}
+
+func TestDeadCodeFalseConditions(t *testing.T) {
+	mod := buildModule(t, `fn main() {
+		mut i = 0;
+		if false {
+			i = 1;
+		}
+		while false {
+			i = 2;
+		}
+		i = 3;
+	}`)
+	errs := EliminateDeadCode(mod)
+	assert.Len(t, errs, 2)
+	for _, err := range errs {
+		assert.Equal(t, api.NewUnreachableCode().ErrorCode, err.ErrorCode)
+	}
+
+	body := mod.Functions["main"].Body
+	assert.Len(t, body.Instr, 2)
+	assert.EqualValues(t, ir.LocalInstr, body.Instr[0].Kind)
+	assert.Empty(t, ir.Verify(mod))
+}
+
+func TestDeadCodeTrueCondition(t *testing.T) {
+	mod := buildModule(t, `fn main() {
+		mut i = 0;
+		if true {
+			i = 1;
+		} else {
+			i = 2;
+		}
+	}`)
+
+	// the else is dropped and the if
+	// becomes the block it always runs.
+	assert.Len(t, EliminateDeadCode(mod), 1)
+
+	body := mod.Functions["main"].Body
+	assert.Len(t, body.Instr, 2)
+	assert.EqualValues(t, ir.BlockInstr, body.Instr[1].Kind)
+	assert.Len(t, body.Instr[1].Block.Instr, 1)
+	assert.Empty(t, ir.Verify(mod))
+}
+
+func TestDeadCodeDropsDefer(t *testing.T) {
+	mod := buildModule(t, `fn main() int {
+		defer let a = 1;
+		return 0;
+		defer let b = 2;
+	}`)
+	assert.Len(t, EliminateDeadCode(mod), 1)
+
+	body := mod.Functions["main"].Body
+	assert.Len(t, body.DeferStack, 1)
+	assert.Equal(t, "a", body.DeferStack[0].Stat.Local.Name.Value)
+	assert.Empty(t, ir.Verify(mod))
+}
@@ -0,0 +1,58 @@
+package middle
+
+import (
+	"github.com/krug-lang/caasper/api"
+	"github.com/krug-lang/caasper/ir"
+)
+
+/*
+	this pass checks that deferred code doesn't return, a
+	defer runs while its scope is being left so a return
+	would skip the rest of the unwinding.
+*/
+
+type deferChecker struct {
+	errs []api.CompilerError
+
+	// how many defers the instructions
+	// being checked are inside of.
+	deferred int
+}
+
+func (d *deferChecker) error(err api.CompilerError) {
+	d.errs = append(d.errs, err)
+}
+
+func (d *deferChecker) enter(c *ir.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *ir.Defer:
+		d.deferred++
+
+	case *ir.Instruction:
+		if n.Kind == ir.ReturnInstr && d.deferred > 0 {
+			d.error(api.NewReturnInDefer(n.Return.Span...))
+		}
+
+	case *ir.Value:
+		return false
+	}
+	return true
+}
+
+func (d *deferChecker) leave(c *ir.Cursor) bool {
+	if _, ok := c.Node().(*ir.Defer); ok {
+		d.deferred--
+	}
+	return true
+}
+
+// DeferCheck checks the deferred code
+// in the given module.
+func DeferCheck(mod *ir.Module) []api.CompilerError {
+	d := &deferChecker{
+		errs: []api.CompilerError{},
+	}
+
+	ir.Walk(mod, d.enter, d.leave)
+	return d.errs
+}
@@ -0,0 +1,25 @@
+package middle
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/krug-lang/caasper/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReturnInDefer(t *testing.T) {
+	srcs := []string{
+		`fn main() { defer { return; } }`,
+		`fn main() i32 { defer { return 1; } return 0; }`,
+	}
+	for _, src := range srcs {
+		errs := DeferCheck(buildModule(t, src))
+		if assert.Len(t, errs, 1, src) {
+			assert.Equal(t, api.NewReturnInDefer().ErrorCode, errs[0].ErrorCode, src)
+			if assert.NotEmpty(t, errs[0].CodeContext, src) {
+				assert.Equal(t, strings.Index(src, "return"), errs[0].CodeContext[0], src)
+			}
+		}
+	}
+}
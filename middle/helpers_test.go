@@ -0,0 +1,33 @@
+package middle
+
+import (
+	"testing"
+
+	"github.com/krug-lang/caasper/front"
+	"github.com/krug-lang/caasper/ir"
+	"github.com/stretchr/testify/assert"
+)
+
+// buildModule builds the ir module of the given source,
+// failing the test if it doesn't parse or build.
+func buildModule(t *testing.T, src string) *ir.Module {
+	tokens, errs := front.TokenizeInput(src, true)
+	assert.Empty(t, errs)
+	nodes, errs := front.ParseTokenStream(tokens)
+	assert.Empty(t, errs)
+	mod, errs := ir.Build([][]*front.ParseTreeNode{nodes}, map[string]string{})
+	assert.Empty(t, errs)
+	return mod
+}
+
+// localOf returns the local with the given name in the function.
+func localOf(fn *ir.Function, name string) *ir.Local {
+	var res *ir.Local
+	ir.Inspect(fn.Body, func(n ir.Node) bool {
+		if i, ok := n.(*ir.Instruction); ok && i.Kind == ir.LocalInstr && i.Local.Name.Value == name {
+			res = i.Local
+		}
+		return res == nil
+	})
+	return res
+}
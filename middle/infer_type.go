@@ -0,0 +1,187 @@
+package middle
+
+import (
+	"github.com/krug-lang/caasper/api"
+	"github.com/krug-lang/caasper/ir"
+)
+
+/*
+	this pass works out the type of every value in a
+	module. locals that are declared without a type are
+	given the type of their value, e.g.
+
+	let x = foo(); // x is given the return type of foo
+
+	literals and initializers take on the type of the
+	local that they are assigned to if it has one.
+*/
+
+type typeInferrer struct {
+	errs  []api.CompilerError
+	env   *ir.Env
+	types *ir.TypeMap
+}
+
+func (t *typeInferrer) error(err api.CompilerError) {
+	t.errs = append(t.errs, err)
+}
+
+func (t *typeInferrer) set(v *ir.Value, typ *ir.Type) {
+	t.types.Set(v, typ)
+	if v.Kind == ir.IdentifierValue && typ != nil && typ.Kind != ir.FunctionKind {
+		t.types.AddSymbol(v.Identifier.Name, typ)
+	}
+}
+
+// inferPath infers the type of each value in the path, the
+// values after the first are fields or a method of the value
+// before them so they aren't looked up in the environment.
+func (t *typeInferrer) inferPath(p *ir.Path) {
+	ir.Walk(p.Values[0], t.enter, t.leave)
+
+	for i, val := range p.Values[1:] {
+		if val.Kind != ir.IdentifierValue {
+			ir.Walk(val, t.enter, t.leave)
+			continue
+		}
+
+		prefix := &ir.Value{Kind: ir.PathValue, Path: ir.NewPath(p.Values[:i+2])}
+		t.set(val, t.env.TypeOf(prefix))
+	}
+}
+
+// inferValue gives the value the type that the env works out
+// for it, the env takes the types of the values inside it from
+// the type map so each value is only inferred once.
+func (t *typeInferrer) inferValue(v *ir.Value) {
+	t.set(v, t.env.TypeOf(v))
+}
+
+// expect gives the value the type that it's assigned to if
+// the value is a literal or an initializer, which have no
+// type of their own.
+func (t *typeInferrer) expect(v *ir.Value, typ *ir.Type) {
+	if v == nil || typ == nil {
+		return
+	}
+
+	switch v.Kind {
+	case ir.IntegerValueValue, ir.FloatingValueValue:
+		if t.env.Assignable(v, typ) {
+			t.types.Set(v, typ)
+		}
+	case ir.GroupingValue:
+		if t.env.Assignable(v, typ) {
+			t.types.Set(v, typ)
+			t.expect(v.Grouping.Val, typ)
+		}
+	case ir.InitValue:
+		t.types.Set(v, typ)
+	}
+}
+
+func (t *typeInferrer) inferLocal(l *ir.Local) {
+	if l.Type == nil && l.Val != nil {
+		l.Type = t.types.TypeOf(l.Val)
+	}
+	if l.Type == nil {
+		t.error(api.NewCannotInferType(l.Name.Value, l.Name.Span...))
+	}
+
+	t.expect(l.Val, l.Type)
+	t.env.DeclareLocal(l)
+	if l.Type != nil {
+		t.types.AddSymbol(l.Name, l.Type)
+	}
+}
+
+func (t *typeInferrer) enter(c *ir.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *ir.Block:
+		t.env.Push()
+
+	case *ir.Value:
+		if n.Kind == ir.PathValue {
+			t.inferPath(n.Path)
+			t.inferValue(n)
+			return false
+		}
+	}
+	return true
+}
+
+func (t *typeInferrer) leave(c *ir.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *ir.Block:
+		t.env.Pop()
+
+	case *ir.Instruction:
+		switch n.Kind {
+		case ir.LocalInstr:
+			t.inferLocal(n.Local)
+		case ir.AllocaInstr:
+			a := n.Alloca
+			t.env.Declare(a.Name.Value, a.Type, a.Mutable)
+			t.types.AddSymbol(a.Name, a.Type)
+		case ir.AssignInstr:
+			t.expect(n.Assign.RHand, t.types.TypeOf(n.Assign.LHand))
+		}
+
+	case *ir.Value:
+		t.inferValue(n)
+	}
+	return true
+}
+
+func (t *typeInferrer) inferFunc(fn *ir.Function) {
+	t.env.Push()
+	t.env.DeclareParams(fn)
+	for _, name := range fn.Param.Order {
+		t.types.AddSymbol(name, fn.Param.Get(name.Value).Type)
+	}
+
+	ir.Walk(fn.Body, t.enter, t.leave)
+	t.env.Pop()
+}
+
+// InferTypes works out the type of every value in the given
+// module, the locals that have no type are given the type of
+// their value.
+func InferTypes(mod *ir.Module) (*ir.TypeMap, []api.CompilerError) {
+	t := &typeInferrer{
+		errs:  []api.CompilerError{},
+		env:   ir.NewEnv(mod),
+		types: ir.NewTypeMap(),
+	}
+	t.env.UseTypes(t.types)
+
+	ir.Walk(mod.Global, t.enter, t.leave)
+
+	for _, name := range mod.StructureOrder {
+		st := mod.Structures[name.Value]
+		ir.Walk(st, t.enter, t.leave)
+
+		for _, name := range st.Fields.Order {
+			field := st.Fields.Get(name.Value)
+			t.expect(field.Val, field.Type)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, name := range mod.FunctionOrder {
+		if seen[name.Value] {
+			continue
+		}
+		seen[name.Value] = true
+		t.inferFunc(mod.Functions[name.Value])
+	}
+
+	for _, name := range mod.ImplsOrder {
+		impl := mod.Impls[name.Value]
+		for _, name := range impl.Order {
+			t.inferFunc(impl.Methods[name.Value])
+		}
+	}
+
+	return t.types, t.errs
+}
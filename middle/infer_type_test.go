@@ -0,0 +1,43 @@
+package middle
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/krug-lang/caasper/ir"
+	"github.com/stretchr/testify/assert"
+)
+
+const inferSource = `type Point = struct { x i32, y f64, };
+impl Point {
+	fn norm(self) f64 { return self.y; }
+}
+fn main() {
+	let p = :Point{1, 2.0};
+	let n = p.norm();
+	let x = p.x;
+}`
+
+func TestInferTypes(t *testing.T) {
+	mod := buildModule(t, inferSource)
+	_, errs := InferTypes(mod)
+	assert.Empty(t, errs)
+
+	main := mod.Functions["main"]
+	assert.True(t, ir.TypesEqual(ir.Float64, localOf(main, "n").Type))
+	assert.True(t, ir.TypesEqual(ir.Int32, localOf(main, "x").Type))
+}
+
+func TestSymbolAt(t *testing.T) {
+	mod := buildModule(t, inferSource)
+	types, _ := InferTypes(mod)
+
+	sym, ok := types.SymbolAt(strings.Index(inferSource, "let n") + len("let "))
+	if assert.True(t, ok) {
+		assert.Equal(t, "n", sym.Name)
+		assert.True(t, ir.TypesEqual(ir.Float64, sym.Type))
+	}
+
+	_, ok = types.SymbolAt(strings.Index(inferSource, "impl"))
+	assert.False(t, ok)
+}
@@ -0,0 +1,201 @@
+package middle
+
+import (
+	"github.com/krug-lang/caasper/api"
+	"github.com/krug-lang/caasper/ir"
+)
+
+/*
+	this pass checks the labels and jumps in each function.
+	a jump must name a label defined once in the same function,
+	and it can't skip over the initialisation of a local or
+	enter a scope that has already pushed a defer.
+*/
+
+// site is where an instruction sits in a function, the
+// enclosing blocks (outermost first) and the index of
+// the instruction in each of those blocks.
+type site struct {
+	blocks []*ir.Block
+	index  []int
+}
+
+func (s site) enter(b *ir.Block, idx int) site {
+	return site{
+		append(append([]*ir.Block{}, s.blocks...), b),
+		append(append([]int{}, s.index...), idx),
+	}
+}
+
+// locals returns the locals that are in scope at the site.
+func (s site) locals() map[*ir.Local]bool {
+	res := map[*ir.Local]bool{}
+	for depth, b := range s.blocks {
+		for _, instr := range b.Instr[:s.index[depth]] {
+			if instr.Kind == ir.LocalInstr {
+				res[instr.Local] = true
+			}
+		}
+	}
+	return res
+}
+
+type jumpSite struct {
+	jump *ir.Jump
+	site site
+}
+
+type labelSite struct {
+	label *ir.Label
+	site  site
+}
+
+type jumpChecker struct {
+	errs []api.CompilerError
+
+	// the function each label is defined in
+	// across the whole module.
+	owners map[string]string
+
+	labels []labelSite
+	jumps  []jumpSite
+}
+
+func (j *jumpChecker) error(err api.CompilerError) {
+	j.errs = append(j.errs, err)
+}
+
+func (j *jumpChecker) walkBlock(b *ir.Block, outer site) {
+	for idx, instr := range b.Instr {
+		s := outer.enter(b, idx)
+
+		switch instr.Kind {
+		case ir.LabelInstr:
+			j.labels = append(j.labels, labelSite{instr.Label, s})
+
+		case ir.JumpInstr:
+			j.jumps = append(j.jumps, jumpSite{instr.Jump, s})
+
+		case ir.BlockInstr:
+			j.walkBlock(instr.Block, s)
+
+		case ir.IfStatementInstr:
+			iff := instr.IfStatement
+			j.walkBlock(iff.True, s)
+			for _, elif := range iff.ElseIf {
+				j.walkBlock(elif.Body, s)
+			}
+			if iff.Else != nil {
+				j.walkBlock(iff.Else, s)
+			}
+
+		case ir.WhileLoopInstr:
+			j.walkBlock(instr.WhileLoop.Body, s)
+
+		case ir.LoopInstr:
+			j.walkBlock(instr.Loop.Body, s)
+		}
+	}
+}
+
+// collect walks the body of a function, deferred code
+// is not walked as it is emitted away from its block.
+func (j *jumpChecker) collect(body *ir.Block) {
+	j.labels, j.jumps = nil, nil
+	j.walkBlock(body, site{})
+}
+
+func (j *jumpChecker) registerLabels(fn string, body *ir.Block) {
+	j.collect(body)
+	for _, l := range j.labels {
+		if _, ok := j.owners[l.label.Name.Value]; !ok {
+			j.owners[l.label.Name.Value] = fn
+		}
+	}
+}
+
+func (j *jumpChecker) checkJump(js jumpSite, ls labelSite) {
+	name := ls.label.Name.Value
+	span := js.jump.Location.Span
+
+	visible := js.site.locals()
+	for local := range ls.site.locals() {
+		if !visible[local] {
+			j.error(api.NewJumpSkipsLocal(name, local.Name.Value, span...))
+		}
+	}
+
+	// the blocks the jump and label share.
+	common := 0
+	for common < len(js.site.blocks) && common < len(ls.site.blocks) &&
+		js.site.blocks[common] == ls.site.blocks[common] {
+		common++
+	}
+
+	for depth := common; depth < len(ls.site.blocks); depth++ {
+		for _, def := range ls.site.blocks[depth].DeferStack {
+			if def.After <= ls.site.index[depth] {
+				j.error(api.NewJumpIntoDefers(name, span...))
+				return
+			}
+		}
+	}
+}
+
+func (j *jumpChecker) checkFunc(fn string, body *ir.Block) {
+	j.collect(body)
+
+	labels := map[string]labelSite{}
+	for _, l := range j.labels {
+		name := l.label.Name
+		if _, ok := labels[name.Value]; ok {
+			j.error(api.NewDuplicateLabel(name.Value, name.Span...))
+			continue
+		}
+		labels[name.Value] = l
+	}
+
+	for _, js := range j.jumps {
+		loc := js.jump.Location
+		ls, ok := labels[loc.Value]
+		if ok {
+			j.checkJump(js, ls)
+			continue
+		}
+
+		if owner, ok := j.owners[loc.Value]; ok {
+			j.error(api.NewLabelInOtherFunction(loc.Value, owner, loc.Span...))
+		} else {
+			j.error(api.NewUndefinedLabel(loc.Value, loc.Span...))
+		}
+	}
+}
+
+// forEachFunc calls visit with the name and body of every
+// function and method in the module.
+func forEachFunc(mod *ir.Module, visit func(name string, body *ir.Block)) {
+	for _, name := range mod.FunctionOrder {
+		visit(name.Value, mod.Functions[name.Value].Body)
+	}
+
+	for _, name := range mod.ImplsOrder {
+		impl := mod.Impls[name.Value]
+		for _, method := range impl.Order {
+			visit(name.Value+"."+method.Value, impl.Methods[method.Value].Body)
+		}
+	}
+}
+
+// JumpCheck checks the labels and jump
+// statements in the given module.
+func JumpCheck(mod *ir.Module) []api.CompilerError {
+	j := &jumpChecker{
+		errs:   []api.CompilerError{},
+		owners: map[string]string{},
+	}
+
+	forEachFunc(mod, j.registerLabels)
+	forEachFunc(mod, j.checkFunc)
+
+	return j.errs
+}
@@ -0,0 +1,110 @@
+package middle
+
+import (
+	"github.com/krug-lang/caasper/api"
+	"github.com/krug-lang/caasper/front"
+	"github.com/krug-lang/caasper/ir"
+)
+
+/*
+	this pass checks that break and next are only used
+	inside of a loop, and that any label they name is the
+	label of a loop that encloses them.
+*/
+
+type loopChecker struct {
+	errs []api.CompilerError
+
+	// the labels of the enclosing loops, innermost
+	// last. loops without a label are nil.
+	loops []*front.Token
+
+	// the loops outside of the defers being checked.
+	outer [][]*front.Token
+}
+
+func (l *loopChecker) error(err api.CompilerError) {
+	l.errs = append(l.errs, err)
+}
+
+func (l *loopChecker) findLoop(name string) bool {
+	for _, label := range l.loops {
+		if label != nil && label.Value == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *loopChecker) checkTarget(kind string, label *front.Token) {
+	if len(l.loops) == 0 {
+		var span []int
+		if label != nil {
+			span = label.Span
+		}
+		l.error(api.NewJumpOutsideLoop(kind, span...))
+		return
+	}
+
+	if label != nil && !l.findLoop(label.Value) {
+		l.error(api.NewUnknownLoopLabel(label.Value, label.Span...))
+	}
+}
+
+func (l *loopChecker) pushLoop(label *front.Token) {
+	if label != nil && l.findLoop(label.Value) {
+		l.error(api.NewShadowedLoopLabel(label.Value, label.Span...))
+	}
+	l.loops = append(l.loops, label)
+}
+
+func (l *loopChecker) enter(c *ir.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *ir.Defer:
+		// deferred code runs as its scope is left, so
+		// it can't break out of or continue the loop.
+		l.outer = append(l.outer, l.loops)
+		l.loops = nil
+
+	case *ir.Instruction:
+		switch n.Kind {
+		case ir.BreakInstr:
+			l.checkTarget("break", n.Break.Label)
+		case ir.NextInstr:
+			l.checkTarget("next", n.Next.Label)
+		case ir.WhileLoopInstr:
+			l.pushLoop(n.WhileLoop.Label)
+		case ir.LoopInstr:
+			l.pushLoop(n.Loop.Label)
+		}
+
+	case *ir.Value:
+		return false
+	}
+	return true
+}
+
+func (l *loopChecker) leave(c *ir.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *ir.Defer:
+		l.loops = l.outer[len(l.outer)-1]
+		l.outer = l.outer[:len(l.outer)-1]
+
+	case *ir.Instruction:
+		if n.Kind == ir.WhileLoopInstr || n.Kind == ir.LoopInstr {
+			l.loops = l.loops[:len(l.loops)-1]
+		}
+	}
+	return true
+}
+
+// LoopCheck checks the break and next
+// statements in the given module.
+func LoopCheck(mod *ir.Module) []api.CompilerError {
+	l := &loopChecker{
+		errs: []api.CompilerError{},
+	}
+
+	ir.Walk(mod, l.enter, l.leave)
+	return l.errs
+}
@@ -0,0 +1,150 @@
+package middle
+
+import (
+	"github.com/krug-lang/caasper/api"
+	"github.com/krug-lang/caasper/front"
+	"github.com/krug-lang/caasper/ir"
+)
+
+/*
+	this pass resolves method calls, e.g. foo.bar(x),
+	against the impl of the receivers type and checks the
+	arguments and receiver of the call.
+*/
+
+type methodChecker struct {
+	mod  *ir.Module
+	errs []api.CompilerError
+	env  *ir.Env
+}
+
+func (m *methodChecker) error(err api.CompilerError) {
+	m.errs = append(m.errs, err)
+}
+
+// rootIdentifier returns the identifier that the given
+// value is a path from, e.g. a in a.b.c
+func rootIdentifier(v *ir.Value) *ir.Identifier {
+	switch v.Kind {
+	case ir.IdentifierValue:
+		return v.Identifier
+	case ir.PathValue:
+		return rootIdentifier(v.Path.Values[0])
+	case ir.GroupingValue:
+		return rootIdentifier(v.Grouping.Val)
+	}
+	return nil
+}
+
+func (m *methodChecker) checkReceiver(recv *ir.Value, method *ir.Function) {
+	if method.Receiver.Kind != front.MutablePointerReceiver {
+		return
+	}
+
+	// we can't tell if what a pointer points to can be
+	// modified, so only values are checked.
+	typ := m.env.TypeOf(recv)
+	if typ != nil && typ.Kind == ir.PointerKind {
+		return
+	}
+
+	root := rootIdentifier(recv)
+	if root != nil && !m.env.IsMutable(root.Name.Value) {
+		parent := method.Receiver.Parent
+		m.error(api.NewReceiverMutabilityError(method.Name.Value, parent, root.Name.Span...))
+	}
+}
+
+func (m *methodChecker) checkCall(call *ir.Call) {
+	recv, method, parent := m.env.MethodCall(call)
+	if parent == nil {
+		return
+	}
+
+	values := call.Left.Path.Values
+	name := values[len(values)-1].Identifier.Name
+
+	if method == nil {
+		// a method without a receiver called on its structure
+		// is checked like a function by the type check.
+		typ := m.env.TypeOf(call.Left)
+		if recv == nil && typ != nil && typ.Function.Receiver == nil {
+			return
+		}
+		m.error(api.NewUnknownMethod(name.Value, parent.Name.Value, name.Span...))
+		return
+	}
+
+	m.checkReceiver(recv, method)
+
+	// the first param is the receiver.
+	params := method.Param.Order[1:]
+	if len(params) != len(call.Params) {
+		m.error(api.NewArgumentCountError(name.Value, len(params), len(call.Params), name.Span...))
+		return
+	}
+
+	for i, p := range params {
+		typ := method.Param.Get(p.Value).Type
+		if arg := call.Params[i]; !m.env.Assignable(arg, typ) {
+			m.error(api.NewConversionError(m.env.TypeOf(arg).String(), typ.String(), spanOf(arg)...))
+		}
+	}
+}
+
+func (m *methodChecker) enter(c *ir.Cursor) bool {
+	if _, ok := c.Node().(*ir.Block); ok {
+		m.env.Push()
+	}
+	return true
+}
+
+// leave checks the calls after their arguments, and
+// declares locals after their values are checked.
+func (m *methodChecker) leave(c *ir.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *ir.Block:
+		m.env.Pop()
+
+	case *ir.Instruction:
+		if n.Kind == ir.LocalInstr {
+			m.env.DeclareLocal(n.Local)
+		}
+
+	case *ir.Value:
+		if n.Kind == ir.CallValue {
+			m.checkCall(n.Call)
+		}
+	}
+	return true
+}
+
+func (m *methodChecker) checkFunc(fn *ir.Function) {
+	m.env.Push()
+	m.env.DeclareParams(fn)
+	ir.Walk(fn.Body, m.enter, m.leave)
+	m.env.Pop()
+}
+
+// MethodCheck resolves and checks all of the
+// method calls in the given module.
+func MethodCheck(mod *ir.Module) []api.CompilerError {
+	m := &methodChecker{
+		mod:  mod,
+		errs: []api.CompilerError{},
+		env:  ir.NewEnv(mod),
+	}
+
+	for _, name := range mod.FunctionOrder {
+		m.checkFunc(mod.Functions[name.Value])
+	}
+
+	for _, name := range mod.ImplsOrder {
+		impl := mod.Impls[name.Value]
+		for _, name := range impl.Order {
+			m.checkFunc(impl.Methods[name.Value])
+		}
+	}
+
+	return m.errs
+}
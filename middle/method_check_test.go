@@ -0,0 +1,57 @@
+package middle
+
+import (
+	"testing"
+
+	"github.com/krug-lang/caasper/api"
+	"github.com/krug-lang/caasper/ir"
+	"github.com/stretchr/testify/assert"
+)
+
+const staticSource = `type Foo = struct { x i32, };
+impl Foo {
+	fn make(v i32) Foo {
+		let res = :Foo{v};
+		return res;
+	}
+	fn get(self) i32 { return self.x; }
+}
+`
+
+func TestStaticMethodCall(t *testing.T) {
+	mod := buildModule(t, staticSource+`fn main() {
+	let f = Foo.make(3);
+	let x = f.get();
+}`)
+	assert.Empty(t, MethodCheck(mod))
+	assert.Empty(t, TypeCheck(mod))
+
+	typ := localOf(mod.Functions["main"], "f").Type
+	if assert.NotNil(t, typ) {
+		st, ok := ir.NewEnv(mod).Structure(typ)
+		assert.True(t, ok)
+		assert.Equal(t, "Foo", st.Name.Value)
+	}
+}
+
+func TestStaticMethodCallErrors(t *testing.T) {
+	// the arguments of a method without a receiver
+	// are checked like the arguments of a function.
+	mod := buildModule(t, staticSource+`fn main() {
+	let f = Foo.make(3, 4);
+}`)
+	assert.Empty(t, MethodCheck(mod))
+	errs := TypeCheck(mod)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, api.NewArgumentCountError("", 0, 0).ErrorCode, errs[0].ErrorCode)
+	}
+
+	// a method with a receiver needs a value to be called on.
+	mod = buildModule(t, staticSource+`fn main() {
+	let x = Foo.get();
+}`)
+	errs = MethodCheck(mod)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, api.NewUnknownMethod("", "").ErrorCode, errs[0].ErrorCode)
+	}
+}
@@ -1,7 +1,6 @@
 package middle
 
 import (
-	"fmt"
 	"github.com/krug-lang/caasper/entity"
 	"net/http"
 
@@ -30,13 +29,20 @@ func isSymbolMutable(sym *ir.SymbolValue) bool {
 }
 
 func (m *mutChecker) checkIdenMutable(parent *ir.Block, iden *ir.Identifier) bool {
-	dict, _ := m.dict.Data[parent.ID]
-
-	if sym, ok := dict.Lookup(iden.Name.Value); ok {
-		return isSymbolMutable(sym)
+	if dict, ok := m.dict.Data[parent.ID]; ok {
+		if sym, ok := dict.Lookup(iden.Name.Value); ok {
+			return isSymbolMutable(sym)
+		}
 	}
 
 	// check parent sym table?
+
+	// fall back to the globals.
+	if dict, ok := m.dict.Data[m.mod.Global.ID]; ok {
+		if sym, ok := dict.Lookup(iden.Name.Value); ok {
+			return isSymbolMutable(sym)
+		}
+	}
 	return false
 }
 
@@ -50,122 +56,37 @@ func (m *mutChecker) checkMutable(parent *ir.Block, val *ir.Value) bool {
 		}
 		return mutable
 
-	case ir.IntegerValueValue:
-	case ir.StringValueValue:
-	case ir.CharacterValueValue:
-	case ir.FloatingValueValue:
-
-	default:
-		panic(fmt.Sprintf("checkMutable: unhandled value %s", val.Kind))
-	}
-
-	return false
-}
-
-func (m *mutChecker) visitIden(parent *ir.Block, iden *ir.Identifier) {
-}
-
-func (m *mutChecker) visitAssign(parent *ir.Block, assign *ir.Assign) {
-	m.checkMutable(parent, assign.LHand)
-}
-
-func (m *mutChecker) visitExpr(parent *ir.Block, expr *ir.Value) {
-	switch expr.Kind {
-	case ir.IdentifierValue:
-		m.visitIden(parent, expr.Identifier)
-
-	case ir.UnaryExpressionValue:
-		m.visitExpr(parent, expr.UnaryExpression.Val)
-
-	case ir.BinaryExpressionValue:
-		m.visitExpr(parent, expr.BinaryExpression.LHand)
-		m.visitExpr(parent, expr.BinaryExpression.RHand)
-
-	case ir.AssignValue:
-		m.visitAssign(parent, expr.Assign)
-
-	case ir.CallValue:
-		// TODO
-		// we need to ensure that the call sites
-		// paramteres share the same mutability as the
-		// values passed through?
-
-	case ir.IntegerValueValue:
-	case ir.StringValueValue:
-	case ir.CharacterValueValue:
-	case ir.FloatingValueValue:
+	case ir.GroupingValue:
+		return m.checkMutable(parent, val.Grouping.Val)
 
+	// assigning to part of a value needs
+	// the value to be mutable.
 	case ir.PathValue:
-		// TODO!
-
-	default:
-		panic(fmt.Sprintf("unhandled expr %s", expr.Kind))
+		return m.checkMutable(parent, val.Path.Values[0])
+	case ir.IndexValue:
+		return m.checkMutable(parent, val.Index.Left)
 	}
-}
 
-func (m *mutChecker) visitLocal(parent *ir.Block, local *ir.Local) {
-	// nop?
+	return false
 }
 
-func (m *mutChecker) visitInstr(parent *ir.Block, instr *ir.Instruction) {
-	switch instr.Kind {
-	case ir.LocalInstr:
-		m.visitLocal(parent, instr.Local)
-
-	case ir.ExpressionInstr:
-		m.visitExpr(parent, instr.ExpressionStatement)
-
-	case ir.ReturnInstr:
-		if val := instr.Return.Val; val != nil {
-			m.visitExpr(parent, val)
-		}
-
-	case ir.IfStatementInstr:
-		iff := instr.IfStatement
-		m.visitExpr(parent, iff.Cond)
-		m.visitBlock(iff.True)
-		for _, elif := range iff.ElseIf {
-			m.visitBlock(elif.Body)
-			// FIXME hm what block is this a part of?
-			m.visitExpr(elif.Body, elif.Cond)
-		}
-		if eb := iff.Else; eb != nil {
-			m.visitBlock(eb)
-		}
-
-	case ir.LoopInstr:
-		m.visitBlock(instr.Loop.Body)
-
-	case ir.WhileLoopInstr:
-		wl := instr.WhileLoop
-		m.visitExpr(wl.Body, wl.Cond)
-		if post := wl.Post; post != nil {
-			m.visitExpr(wl.Body, post)
+func (m *mutChecker) visit(c *ir.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *ir.Instruction:
+		if n.Kind == ir.AssignInstr {
+			m.checkMutable(c.Block(), n.Assign.LHand)
 		}
-		m.visitBlock(wl.Body)
-
-	case ir.LabelInstr:
-	case ir.JumpInstr:
-		// nop
-
-	default:
-		panic(fmt.Sprintf("unhandled instr %s", instr.Kind))
-	}
-}
 
-func (m *mutChecker) visitBlock(block *ir.Block) {
-	for _, instr := range block.Instr {
-		switch instr.Kind {
-		case ir.BlockInstr:
-			m.visitBlock(instr.Block)
-		default:
-			m.visitInstr(block, instr)
+	case *ir.Value:
+		if n.Kind == ir.AssignValue {
+			m.checkMutable(c.Block(), n.Assign.LHand)
 		}
 	}
+	return true
 }
 
 func (m *mutChecker) check(fn *ir.Function) {
-	m.visitBlock(fn.Body)
+	ir.Walk(fn, m.visit, nil)
 }
 
 func mutCheck(mod *ir.Module, dict *ir.ScopeDict) []api.CompilerError {
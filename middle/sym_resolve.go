@@ -1,9 +1,6 @@
 package middle
 
 import (
-	"fmt"
-	"reflect"
-
 	"github.com/krug-lang/caasper/api"
 	"github.com/krug-lang/caasper/ir"
 )
@@ -11,8 +8,7 @@ import (
 type symResolvePass struct {
 	mod    *ir.Module
 	errors []api.CompilerError
-	curr   *ir.SymbolTable
-	outer  *ir.SymbolTable
+	scopes []*ir.SymbolTable
 }
 
 func (s *symResolvePass) error(err api.CompilerError) {
@@ -20,179 +16,92 @@ func (s *symResolvePass) error(err api.CompilerError) {
 }
 
 func (s *symResolvePass) push(stab *ir.SymbolTable) {
-	s.curr = stab
+	s.scopes = append(s.scopes, stab)
 }
 
 func (s *symResolvePass) pop() {
-	if s.curr != nil {
-		s.curr = s.outer
-	}
+	s.scopes = s.scopes[:len(s.scopes)-1]
 }
 
 func (s *symResolvePass) resolveIden(i *ir.Identifier) (*ir.SymbolValue, bool) {
-	val, ok := s.curr.Lookup(i.Name.Value)
-	if !ok {
-		s.error(api.NewUnresolvedSymbol(i.Name.Value, i.Name.Span...))
-	}
-	return val, ok
-}
-
-func (s *symResolvePass) resolveAssign(a *ir.Assign) {
-	s.resolveValue(a.LHand)
-	s.resolveValue(a.RHand)
-}
-
-func (s *symResolvePass) resolveValue(e *ir.Value) *ir.SymbolValue {
-	switch e.Kind {
-	case ir.IntegerValueValue:
-		return nil
-	case ir.StringValueValue:
-		return nil
-	case ir.FloatingValueValue:
-		return nil
-
-	case ir.AssignValue:
-		s.resolveAssign(e.Assign)
-		return nil
-
-	case ir.BinaryExpressionValue:
-		s.resolveValue(e.BinaryExpression.LHand)
-		s.resolveValue(e.BinaryExpression.RHand)
-		return nil
-
-	case ir.IdentifierValue:
-		stab, _ := s.resolveIden(e.Identifier)
-		return stab
-
-	default:
-		panic(fmt.Sprintf("unhandled val %s", reflect.TypeOf(e)))
-	}
-}
-
-func (s *symResolvePass) resolveAlloca(v *ir.Alloca) {
-	if v.Val != nil {
-		s.resolveValue(v.Val)
+	for idx := len(s.scopes) - 1; idx >= 0; idx-- {
+		if val, ok := s.scopes[idx].Lookup(i.Name.Value); ok {
+			return val, ok
+		}
 	}
+	s.error(api.NewUnresolvedSymbol(i.Name.Value, i.Name.Span...))
+	return nil, false
 }
 
-func (s *symResolvePass) resolveLocal(v *ir.Local) {
-	if v.Val != nil {
-		s.resolveValue(v.Val)
+// scopeOf returns the stab that the node introduces, if any.
+func scopeOf(n ir.Node) *ir.SymbolTable {
+	switch n := n.(type) {
+	case *ir.Function:
+		return n.Stab
+	case *ir.Block:
+		return n.Stab
 	}
+	return nil
 }
 
-func (s *symResolvePass) resolveBlock(b *ir.Block) {
-	s.push(b.Stab)
-	for _, instr := range b.Instr {
-		s.resolveInstr(instr)
+func (s *symResolvePass) enter(c *ir.Cursor) bool {
+	if stab := scopeOf(c.Node()); stab != nil {
+		s.push(stab)
 	}
-	s.pop()
-}
 
-func (s *symResolvePass) resolveCall(c *ir.Call) {
-	// TODO:
-}
-
-func (s *symResolvePass) resolveValueVia(last *ir.SymbolTable, val *ir.Value) *ir.SymbolValue {
-	if last == nil {
-		return s.resolveValue(val)
+	val, ok := c.Node().(*ir.Value)
+	if !ok {
+		return true
 	}
 
 	switch val.Kind {
 	case ir.IdentifierValue:
-		v := val.Identifier
-		val, ok := last.Lookup(v.Name.Value)
-		if !ok {
-			s.error(api.NewUnresolvedSymbol(v.Name.Value))
+		// TODO: resolve the functions being called.
+		if call, ok := c.Parent().(*ir.Value); ok && call.Kind == ir.CallValue && call.Call.Left == val {
+			return true
 		}
-		return val
+		s.resolveIden(val.Identifier)
 
-	default:
-		panic(fmt.Sprintf("unhandled value %s", reflect.TypeOf(val)))
+	case ir.PathValue:
+		// only the start of the path is a symbol,
+		// the rest are the names of fields.
+		ir.Walk(val.Path.Values[0], s.enter, s.leave)
+		return false
 	}
+	return true
 }
 
-func (s *symResolvePass) resolvePath(p *ir.Path) {
-	s.resolveValue(p.Values[0])
-}
-
-func (s *symResolvePass) resolveInstr(i *ir.Instruction) {
-	switch i.Kind {
-	case ir.AllocaInstr:
-		instr := i.Alloca
-		s.resolveAlloca(instr)
-	case ir.LocalInstr:
-		instr := i.Local
-		s.resolveLocal(instr)
-
-	case ir.ReturnInstr:
-		instr := i.Return
-		if instr.Val != nil {
-			s.resolveValue(instr.Val)
-		}
-
-	// FIXME
-	case ir.PathValue:
-		// s.resolvePath(instr)
-
-	case ir.IfStatementInstr:
-		instr := i.IfStatement
-		s.resolveValue(instr.Cond)
-		s.resolveBlock(instr.True)
-		for _, e := range instr.ElseIf {
-			s.resolveBlock(e.Body)
-		}
-		if instr.Else != nil {
-			s.resolveBlock(instr.Else)
-		}
-	case ir.WhileLoopInstr:
-		instr := i.WhileLoop
-		s.resolveValue(instr.Cond)
-		if instr.Post != nil {
-			s.resolveValue(instr.Post)
-		}
-		s.resolveBlock(instr.Body)
-	case ir.LoopInstr:
-		instr := i.Loop
-		s.resolveBlock(instr.Body)
-
-	case ir.BlockInstr:
-		instr := i.Block
-		s.resolveBlock(instr)
-
-	// FIXME
-	case ir.CallValue:
-		// s.resolveCall(instr)
-
-	case ir.AssignInstr:
-		instr := i.Assign
-		s.resolveAssign(instr)
-
-	default:
-		panic(fmt.Sprintf("unhandled instr %s", reflect.TypeOf(i)))
+func (s *symResolvePass) leave(c *ir.Cursor) bool {
+	if stab := scopeOf(c.Node()); stab != nil {
+		s.pop()
 	}
+	return true
 }
 
 func (s *symResolvePass) resolveFunc(fn *ir.Function) {
-	s.push(fn.Stab)
+	ir.Walk(fn, s.enter, s.leave)
+}
 
-	/*
-			json, err := jsoniter.MarshalIndent(s.curr, "", "  ")
-		if err != nil {
-			panic(err)
+// globals returns a symbol table of the modules global
+// variables, which is the outermost scope of every function.
+func globals(mod *ir.Module) *ir.SymbolTable {
+	stab := ir.NewSymbolTable(mod.IDSource(), nil)
+	for _, instr := range mod.Global.Instr {
+		if instr.Kind != ir.LocalInstr {
+			continue
 		}
-		fmt.Println(string(json))
-	*/
-
-	for _, instr := range fn.Body.Instr {
-		s.resolveInstr(instr)
+		l := instr.Local
+		stab.Register(l.Name.Value, &ir.SymbolValue{
+			Kind:   ir.SymbolKind,
+			Symbol: ir.NewSymbol(l.Name, l.Owned, l.Mutable),
+		})
 	}
-
-	s.pop()
+	return stab
 }
 
 func symResolve(mod *ir.Module) (*ir.Module, []api.CompilerError) {
-	srp := &symResolvePass{mod, []api.CompilerError{}, nil, nil}
+	srp := &symResolvePass{mod, []api.CompilerError{}, []*ir.SymbolTable{}}
+	srp.push(globals(mod))
 
 	for _, impl := range mod.Impls {
 		for _, method := range impl.Methods {
@@ -0,0 +1,25 @@
+package middle
+
+import (
+	"testing"
+
+	"github.com/krug-lang/caasper/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveGlobals(t *testing.T) {
+	mod := buildModule(t, `let limit i32 = 10;
+fn main() i32 {
+	return limit;
+}`)
+	_, errs := symResolve(mod)
+	assert.Empty(t, errs)
+
+	mod = buildModule(t, `fn main() i32 {
+	return limit;
+}`)
+	_, errs = symResolve(mod)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, api.NewUnresolvedSymbol("").ErrorCode, errs[0].ErrorCode)
+	}
+}
@@ -0,0 +1,174 @@
+package middle
+
+import (
+	"github.com/krug-lang/caasper/api"
+	"github.com/krug-lang/caasper/ir"
+)
+
+/*
+	this pass checks the types of the values that are
+	given to locals, assignments, calls and returns
+	against the types that they are given to, e.g.
+
+	let x i32 = "hi"; // a str is not an i32
+
+	the types of the values are worked out first with
+	type inference, see InferTypes.
+*/
+
+type typeChecker struct {
+	errs  []api.CompilerError
+	types *ir.TypeMap
+}
+
+func (t *typeChecker) error(err api.CompilerError) {
+	t.errs = append(t.errs, err)
+}
+
+// expect checks that the value can be given to something of
+// type to, the error is reported at the value if it has a span
+// and at the given span if not, e.g. for literals.
+func (t *typeChecker) expect(v *ir.Value, to *ir.Type, span []int) {
+	if to == nil || to.Kind == ir.VoidKind {
+		return
+	}
+
+	from := t.types.TypeOf(v)
+	if ir.Assignable(v, from, to) {
+		return
+	}
+
+	if s := spanOf(v); s != nil {
+		span = s
+	}
+	if lit, ok := ir.IntegerLiteral(v); ok && to.Kind == ir.IntegerKind {
+		t.error(api.NewConstantOverflow(lit.RawValue.String(), to.String(), span...))
+		return
+	}
+	t.error(api.NewTypeMismatch(to.String(), from.String(), span...))
+}
+
+func (t *typeChecker) checkLocal(l *ir.Local) {
+	if l.Val != nil {
+		t.expect(l.Val, l.Type, l.Name.Span)
+	}
+}
+
+func (t *typeChecker) checkAssign(a *ir.Assign) {
+	// compound assignments are checked
+	// as operators in the conversion check.
+	if a.Op != "=" {
+		return
+	}
+	t.expect(a.RHand, t.types.TypeOf(a.LHand), spanOf(a.LHand))
+}
+
+func (t *typeChecker) checkReturn(fn *ir.Function, ret *ir.Return) {
+	name := fn.Name.Value
+	void := fn.ReturnType == nil || fn.ReturnType.Kind == ir.VoidKind
+
+	switch {
+	case ret.Val == nil && !void:
+		t.error(api.NewMissingReturnValue(name, fn.ReturnType.String(), ret.Span...))
+	case ret.Val != nil && void:
+		t.error(api.NewUnexpectedReturnValue(name, ret.Span...))
+	case ret.Val != nil:
+		t.expect(ret.Val, fn.ReturnType, ret.Span)
+	}
+}
+
+// callee returns the name of the function being called, e.g.
+// bar in foo.bar(x), and the span to report errors at.
+func callee(left *ir.Value) (string, []int) {
+	switch left.Kind {
+	case ir.IdentifierValue:
+		return left.Identifier.Name.Value, left.Identifier.Name.Span
+	case ir.PathValue:
+		last := left.Path.Values[len(left.Path.Values)-1]
+		return callee(last)
+	}
+	return "", spanOf(left)
+}
+
+func (t *typeChecker) checkCall(call *ir.Call) {
+	name, span := callee(call.Left)
+
+	typ := t.types.TypeOf(call.Left)
+	if typ == nil {
+		// calls to C functions aren't checked.
+		return
+	}
+	if typ.Kind != ir.FunctionKind {
+		t.error(api.NewNotCallable(name, typ.String(), span...))
+		return
+	}
+
+	fn := typ.Function
+	params := fn.Param.Order
+
+	// the first param of a method is the receiver, this
+	// isn't given as an argument when it's called on a value.
+	if fn.Receiver != nil && call.Left.Kind == ir.PathValue && len(params) > 0 {
+		params = params[1:]
+	}
+
+	if len(params) != len(call.Params) {
+		t.error(api.NewArgumentCountError(name, len(params), len(call.Params), span...))
+		return
+	}
+
+	for i, p := range params {
+		t.expect(call.Params[i], fn.Param.Get(p.Value).Type, span)
+	}
+}
+
+func (t *typeChecker) checkStructure(st *ir.Structure) {
+	for _, name := range st.Fields.Order {
+		if field := st.Fields.Get(name.Value); field.Val != nil {
+			t.expect(field.Val, field.Type, field.Name.Span)
+		}
+	}
+}
+
+func (t *typeChecker) enter(c *ir.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *ir.Structure:
+		t.checkStructure(n)
+
+	case *ir.Instruction:
+		switch n.Kind {
+		case ir.LocalInstr:
+			t.checkLocal(n.Local)
+		case ir.AssignInstr:
+			t.checkAssign(n.Assign)
+		case ir.ReturnInstr:
+			if fn := c.Function(); fn != nil {
+				t.checkReturn(fn, n.Return)
+			}
+		}
+
+	case *ir.Value:
+		switch n.Kind {
+		case ir.AssignValue:
+			t.checkAssign(n.Assign)
+		case ir.CallValue:
+			t.checkCall(n.Call)
+		}
+	}
+	return true
+}
+
+// TypeCheck checks the types of the values given to locals,
+// assignments, calls and returns in the given module. the
+// errors from inferring the types are also returned.
+func TypeCheck(mod *ir.Module) []api.CompilerError {
+	types, errs := InferTypes(mod)
+
+	t := &typeChecker{
+		errs:  errs,
+		types: types,
+	}
+
+	ir.Walk(mod, t.enter, nil)
+	return t.errs
+}
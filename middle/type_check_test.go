@@ -0,0 +1,81 @@
+package middle
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/krug-lang/caasper/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// each mismatch is reported once, by the type check.
+func TestTypeMismatchReportedOnce(t *testing.T) {
+	srcs := []string{
+		`fn main() { let x i32 = "hi"; }`,
+		`fn main() i32 { return "no"; }`,
+		`fn main() { mut x i32 = 0; x = "hi"; }`,
+		`fn main() { mut s = "hi"; s = 5; }`,
+		`fn foo(a i32) {} fn main() { foo("hi"); }`,
+	}
+	for _, src := range srcs {
+		mod := buildModule(t, src)
+		assert.Empty(t, ConversionCheck(mod), src)
+
+		errs := TypeCheck(mod)
+		if assert.Len(t, errs, 1, src) {
+			assert.Equal(t, api.NewTypeMismatch("", "").ErrorCode, errs[0].ErrorCode, src)
+		}
+	}
+}
+
+func TestTypeCheckErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		code int
+		at   string
+	}{
+		{`fn foo(a i32) {} fn main() { foo(1, 2); }`, api.NewArgumentCountError("", 0, 0).ErrorCode, "foo(1"},
+		{`fn foo(a i32) {} fn main() { foo(); }`, api.NewArgumentCountError("", 0, 0).ErrorCode, "foo()"},
+		{`fn main() i32 { return; }`, api.NewMissingReturnValue("", "").ErrorCode, "return"},
+		{`fn main() { return 1; }`, api.NewUnexpectedReturnValue("").ErrorCode, "return"},
+		{`fn main() { let x = 1; x(); }`, api.NewNotCallable("", "").ErrorCode, "x()"},
+	}
+
+	for _, test := range tests {
+		errs := TypeCheck(buildModule(t, test.src))
+		if assert.Len(t, errs, 1, test.src) {
+			assert.Equal(t, test.code, errs[0].ErrorCode, test.src)
+			if assert.NotEmpty(t, errs[0].CodeContext, test.src) {
+				assert.Equal(t, strings.Index(test.src, test.at), errs[0].CodeContext[0], test.src)
+			}
+		}
+	}
+}
+
+func TestTypeCheckMethodReceiver(t *testing.T) {
+	// the receiver of a method called on a
+	// value isn't counted as an argument.
+	mod := buildModule(t, `type Point = struct { x i32, };
+impl Point {
+	fn scale(self, by i32) i32 { return self.x * by; }
+}
+fn main() {
+	let p = :Point{1};
+	let a = p.scale(2);
+}`)
+	assert.Empty(t, TypeCheck(mod))
+
+	src := `type Point = struct { x i32, };
+impl Point {
+	fn scale(self, by i32) i32 { return self.x * by; }
+}
+fn main() {
+	let p = :Point{1};
+	let a = p.scale(p, 2);
+}`
+	errs := TypeCheck(buildModule(t, src))
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, api.NewArgumentCountError("", 0, 0).ErrorCode, errs[0].ErrorCode)
+		assert.Equal(t, strings.Index(src, "scale(p"), errs[0].CodeContext[0])
+	}
+}
@@ -117,6 +117,9 @@ func (t *typeResolvePass) resolveType(typ *ir.Type) *ir.Type {
 	case ir.ArrayKind:
 		return t.resolveType(typ.ArrayType.Base)
 
+	case ir.SliceKind:
+		return t.resolveType(typ.Slice.Base)
+
 	case ir.StructKind:
 		return t.resolveStructure(typ.Structure)
 
@@ -129,7 +132,6 @@ func (t *typeResolvePass) resolveType(typ *ir.Type) *ir.Type {
 
 	default:
 		panic(fmt.Sprintf("unhandled type %s", reflect.TypeOf(typ)))
-		return nil
 	}
 }
 
@@ -138,66 +140,42 @@ func (t *typeResolvePass) resolveAlloca(a *ir.Alloca) {
 }
 
 func (t *typeResolvePass) resolveLocal(l *ir.Local) {
+	// the type is inferred later on.
+	if l.Type == nil {
+		return
+	}
 	t.resolveType(l.Type)
 }
 
-func (t *typeResolvePass) resolveInstr(i *ir.Instruction) {
-	switch i.Kind {
-	case ir.AllocaInstr:
-		t.resolveAlloca(i.Alloca)
-	case ir.LocalInstr:
-		t.resolveLocal(i.Local)
-	case ir.WhileLoopInstr:
-		t.resolveBlock(i.WhileLoop.Body)
-	case ir.LoopInstr:
-		t.resolveBlock(i.Loop.Body)
-
-	case ir.IfStatementInstr:
-		instr := i.IfStatement
-		t.resolveBlock(instr.True)
-		for _, e := range instr.ElseIf {
-			t.resolveBlock(e.Body)
-		}
-		if instr.Else != nil {
-			t.resolveBlock(instr.Else)
-		}
+func (t *typeResolvePass) enter(c *ir.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *ir.Block:
+		t.push(n.Stab)
 
-	case ir.PathValue:
-		// i think this may have to go into
-		// a later pass.
-		// first pass resolves top level decls
-		// then the second pass resolves the
-		// func level types?
-		// t.resolvePath(instr)
-
-	case ir.ReturnInstr:
-		return
-	case ir.BreakInstr:
-		return
-	case ir.NextInstr:
-		return
-
-	// FIXME
-	case ir.CallValue:
-		return
-
-	case ir.AssignInstr:
-		return
-
-	case ir.BlockInstr:
-		t.resolveBlock(i.Block)
+	case *ir.Instruction:
+		switch n.Kind {
+		case ir.AllocaInstr:
+			t.resolveAlloca(n.Alloca)
+		case ir.LocalInstr:
+			t.resolveLocal(n.Local)
+		}
 
-	default:
-		panic(fmt.Sprintf("unhandled instruction %s", reflect.TypeOf(i)))
+	// i think paths may have to go into
+	// a later pass.
+	// first pass resolves top level decls
+	// then the second pass resolves the
+	// func level types?
+	case *ir.Value:
+		return false
 	}
+	return true
 }
 
-func (t *typeResolvePass) resolveBlock(b *ir.Block) {
-	t.push(b.Stab)
-	for _, instr := range b.Instr {
-		t.resolveInstr(instr)
+func (t *typeResolvePass) leave(c *ir.Cursor) bool {
+	if _, ok := c.Node().(*ir.Block); ok {
+		t.pop()
 	}
-	t.pop()
+	return true
 }
 
 func (t *typeResolvePass) resolveStructure(st *ir.Structure) *ir.Type {
@@ -235,7 +213,7 @@ func (t *typeResolvePass) resolveFunc(fn *ir.Function) {
 
 	t.push(fn.Stab)
 	for _, instr := range fn.Body.Instr {
-		t.resolveInstr(instr)
+		ir.Walk(instr, t.enter, t.leave)
 	}
 	t.pop()
 }
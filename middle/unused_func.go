@@ -66,84 +66,11 @@ func (v *visitor) resolveCall(call *ir.Call) {
 	}
 }
 
-func (v *visitor) visitValue(expr *ir.Value) {
-	switch expr.Kind {
-	case ir.CallValue:
-		v.resolveCall(expr.Call)
-
-	case ir.BinaryExpressionValue:
-		v.visitValue(expr.BinaryExpression.LHand)
-		v.visitValue(expr.BinaryExpression.RHand)
-
-	case ir.FloatingValueValue:
-		fallthrough
-	case ir.StringValueValue:
-		fallthrough
-	case ir.CharacterValueValue:
-		fallthrough
-	case ir.IdentifierValue:
-		fallthrough
-	case ir.IntegerValueValue:
-		return
-
-	default:
-		v.err(api.NewUnimplementedError("unused_func", fmt.Sprintf("visitValue:%s", expr.Kind)))
-	}
-}
-
-func (v *visitor) visitLocal(local *ir.Local) {
-	if val := local.Val; val != nil {
-		v.visitValue(val)
-	}
-}
-
-func (v *visitor) visitIf(instr *ir.IfStatement) {
-	v.visitValue(instr.Cond)
-
-	for _, elif := range instr.ElseIf {
-		v.visitValue(elif.Cond)
-		v.visitBlock(elif.Body)
-	}
-
-	if els := instr.Else; els != nil {
-		v.visitBlock(els)
-	}
-}
-
-func (v *visitor) visitInstr(instr *ir.Instruction) {
-	switch instr.Kind {
-	case ir.ExpressionInstr:
-		v.visitValue(instr.ExpressionStatement)
-	case ir.LocalInstr:
-		v.visitLocal(instr.Local)
-
-	case ir.ReturnInstr:
-		if ret := instr.Return.Val; ret != nil {
-			v.visitValue(ret)
-		}
-	case ir.BlockInstr:
-		v.visitBlock(instr.Block)
-	case ir.LoopInstr:
-		v.visitBlock(instr.Loop.Body)
-	case ir.IfStatementInstr:
-		v.visitIf(instr.IfStatement)
-
-	default:
-		v.err(api.NewUnimplementedError("unused_func", fmt.Sprintf("visitInstr:%s", instr.Kind)))
-	}
-}
-
-func (v *visitor) visitBlock(b *ir.Block) {
-	for _, stat := range b.Instr {
-		v.visitInstr(stat)
-	}
-	for _, stat := range b.DeferStack {
-		if stat.Block != nil {
-			v.visitBlock(stat.Block)
-		} else if stat.Stat != nil {
-			v.visitInstr(stat.Stat)
-		}
+func (v *visitor) visit(n ir.Node) bool {
+	if val, ok := n.(*ir.Value); ok && val.Kind == ir.CallValue {
+		v.resolveCall(val.Call)
 	}
+	return true
 }
 
 func calculateFunctionUsage(g *functionGraph, mod *ir.Module) []api.CompilerError {
@@ -151,7 +78,7 @@ func calculateFunctionUsage(g *functionGraph, mod *ir.Module) []api.CompilerErro
 
 	for _, fn := range mod.Functions {
 		v.currFunc = fn
-		v.visitBlock(fn.Body)
+		ir.Inspect(fn, v.visit)
 	}
 
 	return v.errs
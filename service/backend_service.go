@@ -6,6 +6,7 @@ import (
 	"github.com/krug-lang/caasper/back"
 	"github.com/krug-lang/caasper/entity"
 	"github.com/krug-lang/caasper/ir"
+	"github.com/krug-lang/caasper/middle"
 	"net/http"
 )
 
@@ -20,15 +21,43 @@ func Gen(c *gin.Context) {
 		panic(err)
 	}
 
+	if malformed(c, &irMod) {
+		return
+	}
+
+	// constants are evaluated, code that can never run is
+	// removed, and the locals without a type are given one
+	// before any code is generated. these passes rewrite the
+	// module so it's verified again after each of them.
+	errors := middle.FoldConstants(&irMod)
+	if malformed(c, &irMod, errors...) {
+		return
+	}
+
+	errors = append(errors, middle.EliminateDeadCode(&irMod)...)
+	if malformed(c, &irMod, errors...) {
+		return
+	}
+
+	typeMap, inferErrors := middle.InferTypes(&irMod)
+	errors = append(errors, inferErrors...)
+	if malformed(c, &irMod, errors...) {
+		return
+	}
+
 	// for now we just return the
 	// bytes for one big old c file.
-	monoFile, errors := back.Codegen(&irMod, codeGenReq.TabSize, codeGenReq.Minify)
+	monoFile, genErrors := back.Codegen(&irMod, typeMap, codeGenReq.TabSize, codeGenReq.Minify, codeGenReq.BoundsCheck)
+	errors = append(errors, genErrors...)
 
+	// the link flags are passed back to the driver
+	// so that they can be given to the c compiler.
 	type generatedCode struct {
-		Code string `json:"code"`
+		Code  string   `json:"code"`
+		Flags []string `json:"flags"`
 	}
 
-	genCode := generatedCode{monoFile}
+	genCode := generatedCode{monoFile, irMod.LinkFlags}
 	genCodeResp, err := jsoniter.Marshal(&genCode)
 	if err != nil {
 		panic(err)
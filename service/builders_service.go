@@ -20,26 +20,68 @@ import (
 		check function params
 */
 
+// BuildType infers the type of every value in the
+// given module and returns the type map.
 func BuildType(c *gin.Context) {
 	var buildTypeMapReq entity.BuildTypeMapRequest
 	if err := c.BindJSON(&buildTypeMapReq); err != nil {
 		panic(err)
 	}
 
-	var payload struct {
-		ScopeMap *ir.ScopeMap
-		Module   *ir.Module
+	var irMod ir.Module
+	if err := jsoniter.Unmarshal([]byte(buildTypeMapReq.IRModule), &irMod); err != nil {
+		panic(err)
+	}
+
+	if malformed(c, &irMod) {
+		return
 	}
 
-	typedMod, errs := middle.DeclType(payload.ScopeMap, payload.Module)
+	typeMap, errs := middle.InferTypes(&irMod)
 
-	jsonTypedMod, err := jsoniter.MarshalIndent(typedMod, "", "  ")
+	jsonTypeMap, err := jsoniter.MarshalIndent(typeMap, "", "  ")
 	if err != nil {
 		panic(err)
 	}
 
 	resp := entity.KrugResponse{
-		Data:   string(jsonTypedMod),
+		Data:   string(jsonTypeMap),
+		Errors: errs,
+	}
+
+	c.JSON(http.StatusOK, &resp)
+}
+
+// Hover returns the symbol written at the given offset in the
+// source with its type, data is empty if there is no symbol.
+func Hover(c *gin.Context) {
+	var hoverReq entity.HoverRequest
+	if err := c.BindJSON(&hoverReq); err != nil {
+		panic(err)
+	}
+
+	var irMod ir.Module
+	if err := jsoniter.Unmarshal([]byte(hoverReq.IRModule), &irMod); err != nil {
+		panic(err)
+	}
+
+	if malformed(c, &irMod) {
+		return
+	}
+
+	typeMap, errs := middle.InferTypes(&irMod)
+
+	var data string
+	if sym, ok := typeMap.SymbolAt(hoverReq.Offset); ok {
+		jsonSymbol, err := jsoniter.MarshalIndent(sym, "", "  ")
+		if err != nil {
+			panic(err)
+		}
+		data = string(jsonSymbol)
+	}
+
+	resp := entity.KrugResponse{
+		Data:   data,
 		Errors: errs,
 	}
 
@@ -61,6 +103,10 @@ func BuildScope(c *gin.Context) {
 		panic(err)
 	}
 
+	if malformed(c, &irMod) {
+		return
+	}
+
 	scopeMap, errs := middle.BuildScope(&irMod)
 
 	jsonScopeMap, err := jsoniter.MarshalIndent(scopeMap, "", "  ")
@@ -87,6 +133,10 @@ func BuildScopeDict(c *gin.Context) {
 		panic(err)
 	}
 
+	if malformed(c, &irMod) {
+		return
+	}
+
 	scopeDict, errs := middle.BuildScopeDict(&irMod)
 
 	jsonScopeDict, err := jsoniter.MarshalIndent(scopeDict, "", "  ")
@@ -102,3 +152,37 @@ func BuildScopeDict(c *gin.Context) {
 	c.JSON(http.StatusOK, &resp)
 }
 
+// ConstantFold evaluates the constant expressions in
+// the given module and returns the folded module.
+func ConstantFold(c *gin.Context) {
+	var req entity.ConstantFoldRequest
+	if err := c.BindJSON(&req); err != nil {
+		panic(err)
+	}
+
+	var irMod ir.Module
+	if err := jsoniter.Unmarshal([]byte(req.IRModule), &irMod); err != nil {
+		panic(err)
+	}
+
+	if malformed(c, &irMod) {
+		return
+	}
+
+	errs := middle.FoldConstants(&irMod)
+	if malformed(c, &irMod, errs...) {
+		return
+	}
+
+	jsonIrModule, err := jsoniter.MarshalIndent(&irMod, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	resp := entity.KrugResponse{
+		Data:   string(jsonIrModule),
+		Errors: errs,
+	}
+
+	c.JSON(http.StatusOK, &resp)
+}
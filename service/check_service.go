@@ -0,0 +1,173 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/krug-lang/caasper/entity"
+	"github.com/krug-lang/caasper/ir"
+	"github.com/krug-lang/caasper/middle"
+)
+
+// ConversionCheck checks the operator conversions
+// and explicit casts in the given module.
+func ConversionCheck(c *gin.Context) {
+	var req entity.ConversionCheckRequest
+	if err := c.BindJSON(&req); err != nil {
+		panic(err)
+	}
+
+	var irMod ir.Module
+	if err := jsoniter.Unmarshal([]byte(req.IRModule), &irMod); err != nil {
+		panic(err)
+	}
+
+	if malformed(c, &irMod) {
+		return
+	}
+
+	errs := middle.ConversionCheck(&irMod)
+
+	resp := entity.KrugResponse{
+		Data:   "",
+		Errors: errs,
+	}
+
+	c.JSON(http.StatusOK, &resp)
+}
+
+// MethodCheck resolves and checks the method
+// calls in the given module.
+func MethodCheck(c *gin.Context) {
+	var req entity.MethodCheckRequest
+	if err := c.BindJSON(&req); err != nil {
+		panic(err)
+	}
+
+	var irMod ir.Module
+	if err := jsoniter.Unmarshal([]byte(req.IRModule), &irMod); err != nil {
+		panic(err)
+	}
+
+	if malformed(c, &irMod) {
+		return
+	}
+
+	errs := middle.MethodCheck(&irMod)
+
+	resp := entity.KrugResponse{
+		Data:   "",
+		Errors: errs,
+	}
+
+	c.JSON(http.StatusOK, &resp)
+}
+
+// LoopCheck checks the break and next
+// statements in the given module.
+func LoopCheck(c *gin.Context) {
+	var req entity.LoopCheckRequest
+	if err := c.BindJSON(&req); err != nil {
+		panic(err)
+	}
+
+	var irMod ir.Module
+	if err := jsoniter.Unmarshal([]byte(req.IRModule), &irMod); err != nil {
+		panic(err)
+	}
+
+	if malformed(c, &irMod) {
+		return
+	}
+
+	errs := middle.LoopCheck(&irMod)
+
+	resp := entity.KrugResponse{
+		Data:   "",
+		Errors: errs,
+	}
+
+	c.JSON(http.StatusOK, &resp)
+}
+
+// DeferCheck checks the deferred
+// code in the given module.
+func DeferCheck(c *gin.Context) {
+	var req entity.DeferCheckRequest
+	if err := c.BindJSON(&req); err != nil {
+		panic(err)
+	}
+
+	var irMod ir.Module
+	if err := jsoniter.Unmarshal([]byte(req.IRModule), &irMod); err != nil {
+		panic(err)
+	}
+
+	if malformed(c, &irMod) {
+		return
+	}
+
+	errs := middle.DeferCheck(&irMod)
+
+	resp := entity.KrugResponse{
+		Data:   "",
+		Errors: errs,
+	}
+
+	c.JSON(http.StatusOK, &resp)
+}
+
+// JumpCheck checks the labels and
+// jumps in the given module.
+func JumpCheck(c *gin.Context) {
+	var req entity.JumpCheckRequest
+	if err := c.BindJSON(&req); err != nil {
+		panic(err)
+	}
+
+	var irMod ir.Module
+	if err := jsoniter.Unmarshal([]byte(req.IRModule), &irMod); err != nil {
+		panic(err)
+	}
+
+	if malformed(c, &irMod) {
+		return
+	}
+
+	errs := middle.JumpCheck(&irMod)
+
+	resp := entity.KrugResponse{
+		Data:   "",
+		Errors: errs,
+	}
+
+	c.JSON(http.StatusOK, &resp)
+}
+
+// TypeCheck checks the types of the values given to
+// locals, assignments, calls and returns in the given module.
+func TypeCheck(c *gin.Context) {
+	var req entity.TypeCheckRequest
+	if err := c.BindJSON(&req); err != nil {
+		panic(err)
+	}
+
+	var irMod ir.Module
+	if err := jsoniter.Unmarshal([]byte(req.IRModule), &irMod); err != nil {
+		panic(err)
+	}
+
+	if malformed(c, &irMod) {
+		return
+	}
+
+	errs := middle.TypeCheck(&irMod)
+
+	resp := entity.KrugResponse{
+		Data:   "",
+		Errors: errs,
+	}
+
+	c.JSON(http.StatusOK, &resp)
+}
@@ -22,6 +22,10 @@ func UnusedFunctions(c *gin.Context) {
 		panic(err)
 	}
 
+	if malformed(c, &irMod) {
+		return
+	}
+
 	var scopeDict ir.ScopeDict
 	if err := jsoniter.Unmarshal([]byte(req.ScopeMap), &scopeDict); err != nil {
 		panic(err)
@@ -37,3 +41,37 @@ func UnusedFunctions(c *gin.Context) {
 	c.JSON(http.StatusOK, &resp)
 }
 
+// DeadCode removes the code that can never run from
+// the given module, and warns about each piece removed.
+func DeadCode(c *gin.Context) {
+	var req entity.DeadCodeRequest
+	if err := c.BindJSON(&req); err != nil {
+		panic(err)
+	}
+
+	var irMod ir.Module
+	if err := jsoniter.Unmarshal([]byte(req.IRModule), &irMod); err != nil {
+		panic(err)
+	}
+
+	if malformed(c, &irMod) {
+		return
+	}
+
+	errs := middle.EliminateDeadCode(&irMod)
+	if malformed(c, &irMod, errs...) {
+		return
+	}
+
+	jsonIrModule, err := jsoniter.MarshalIndent(&irMod, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	resp := entity.KrugResponse{
+		Data:   string(jsonIrModule),
+		Errors: errs,
+	}
+
+	c.JSON(http.StatusOK, &resp)
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"github.com/gin-gonic/gin"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/krug-lang/caasper/api"
 	"github.com/krug-lang/caasper/entity"
 	"github.com/krug-lang/caasper/front"
 	"github.com/krug-lang/caasper/ir"
@@ -23,7 +24,10 @@ func Build(c *gin.Context) {
 		panic(err)
 	}
 
-	irModule, errors := ir.Build(trees)
+	irModule, errors := ir.Build(trees, irBuildReq.Cfg)
+	if VerifyIR {
+		errors = append(errors, ir.Verify(irModule)...)
+	}
 
 	jsonIrModule, err := jsoniter.MarshalIndent(irModule, "", "  ")
 	if err != nil {
@@ -37,3 +41,91 @@ func Build(c *gin.Context) {
 	c.JSON(http.StatusOK, &resp)
 }
 
+
+// Print writes the given ir module in
+// its textual form.
+func Print(c *gin.Context) {
+	var req entity.IRPrintRequest
+	if err := c.BindJSON(&req); err != nil {
+		panic(err)
+	}
+
+	var irMod ir.Module
+	if err := jsoniter.Unmarshal([]byte(req.IRModule), &irMod); err != nil {
+		panic(err)
+	}
+
+	resp := entity.KrugResponse{
+		Data:   ir.Print(&irMod),
+		Errors: []api.CompilerError{},
+	}
+	c.JSON(http.StatusOK, &resp)
+}
+
+// ParseText reads a module from its
+// textual form.
+func ParseText(c *gin.Context) {
+	var req entity.IRParseRequest
+	if err := c.BindJSON(&req); err != nil {
+		panic(err)
+	}
+
+	irModule, errors := ir.ParseText(req.Source)
+
+	jsonIrModule, err := jsoniter.MarshalIndent(irModule, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	resp := entity.KrugResponse{
+		Data:   string(jsonIrModule),
+		Errors: errors,
+	}
+	c.JSON(http.StatusOK, &resp)
+}
+
+// Verify checks that the given ir module
+// is well formed.
+func Verify(c *gin.Context) {
+	var req entity.IRVerifyRequest
+	if err := c.BindJSON(&req); err != nil {
+		panic(err)
+	}
+
+	var irMod ir.Module
+	if err := jsoniter.Unmarshal([]byte(req.IRModule), &irMod); err != nil {
+		panic(err)
+	}
+
+	resp := entity.KrugResponse{
+		Data:   "",
+		Errors: ir.Verify(&irMod),
+	}
+	c.JSON(http.StatusOK, &resp)
+}
+
+// VerifyIR is a debug mode where every pass verifies the
+// module it is given before running, so that a pass that
+// leaves the module malformed is caught by the next one.
+var VerifyIR = false
+
+// malformed verifies the module if VerifyIR is set, and
+// responds with the violations if there are any. the errors
+// of any passes that already ran are responded with first.
+func malformed(c *gin.Context, irMod *ir.Module, passErrs ...api.CompilerError) bool {
+	if !VerifyIR {
+		return false
+	}
+
+	errs := ir.Verify(irMod)
+	if len(errs) == 0 {
+		return false
+	}
+
+	resp := entity.KrugResponse{
+		Data:   "",
+		Errors: append(passErrs, errs...),
+	}
+	c.JSON(http.StatusOK, &resp)
+	return true
+}